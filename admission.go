@@ -0,0 +1,204 @@
+package ssss
+
+// Admission is the decision of an admission policy about an item of an
+// untracked label when all counters are in use
+type Admission int
+
+const (
+	// Reject drops the item
+	Reject Admission = iota
+	// Replace evicts the minimum counter and gives the label an empty
+	// counter with the item
+	Replace
+	// Inherit gives the label the minimum counter with its set, as in
+	// Space-Saving, so its cardinality is an upper bound
+	Inherit
+)
+
+// AdmissionPolicy decides which labels take over the unpinned counter with
+// the minimum cardinality once all counters are in use. Each sketch calls
+// NewAdmitter once, so policies can keep state per sketch. Like the Hasher,
+// the policy is not part of the encodings: decoded sketches use the default
+// policy until Config.AdmissionPolicy is set again.
+type AdmissionPolicy interface {
+	NewAdmitter() Admitter
+}
+
+// Admitter makes the admission decisions of one sketch
+type Admitter interface {
+	Admit(c *AdmissionCandidate) Admission
+}
+
+// AdmissionCandidate describes an item of an untracked label
+type AdmissionCandidate struct {
+	Label any
+	Item  any
+	// Estimate is the trailing-zeros estimate of the cardinality of the
+	// label from the item, see the default TrailingZerosPolicy
+	Estimate uint64
+	// MinCardinality is the cardinality of the counter that would be evicted
+	MinCardinality uint64
+
+	seed uint64
+	// key is set with Config.KeyedHashing, see Config.tweakedKey
+	key   [2]uint64
+	keyed bool
+}
+
+// LabelHash returns a seeded 64-bit hash of the label. With
+// Config.KeyedHashing it is a SipHash keyed by HashKey instead.
+func (c *AdmissionCandidate) LabelHash() uint64 {
+	if c.keyed {
+		return sipHash24(c.key[0], c.key[1], itemText(c.Label))
+	}
+	return murmurHash64A(itemText(c.Label), c.seed)
+}
+
+// Hash returns a seeded 64-bit hash of the label and item, for policies that
+// decide at random. Decisions drawn from it are repeatable for an item. With
+// Config.KeyedHashing it is a SipHash keyed by HashKey instead, so that
+// items cannot be crafted to be admitted.
+func (c *AdmissionCandidate) Hash() uint64 {
+	if c.keyed {
+		return keyedPairHash(c.key, c.Label, c.Item)
+	}
+	return murmurHash64A(itemText(c.Label), murmurHash64A(itemText(c.Item), c.seed))
+}
+
+// TrailingZerosPolicy admits a label when the trailing-zeros estimate of its
+// cardinality exceeds the minimum cardinality. It is the default policy.
+type TrailingZerosPolicy struct{}
+
+// NewAdmitter returns the policy itself, which has no state
+func (p TrailingZerosPolicy) NewAdmitter() Admitter {
+	return p
+}
+
+// Admit replaces the minimum counter if the estimate exceeds its cardinality
+func (TrailingZerosPolicy) Admit(c *AdmissionCandidate) Admission {
+	if c.Estimate > c.MinCardinality {
+		return Replace
+	}
+	return Reject
+}
+
+// InheritancePolicy always admits the label, which inherits the set of the
+// evicted label as in deterministic Space-Saving Sets. Every label is
+// tracked from its first item, at the cost of overestimating the labels
+// that took over a counter by up to the cardinality they inherited.
+type InheritancePolicy struct{}
+
+// NewAdmitter returns the policy itself, which has no state
+func (p InheritancePolicy) NewAdmitter() Admitter {
+	return p
+}
+
+// Admit always lets the label inherit the minimum counter
+func (InheritancePolicy) Admit(*AdmissionCandidate) Admission {
+	return Inherit
+}
+
+// ProportionalPolicy replaces the minimum counter with probability
+// Estimate/MinCardinality, capped at 1, drawn from AdmissionCandidate.Hash
+type ProportionalPolicy struct{}
+
+// NewAdmitter returns the policy itself, which has no state
+func (p ProportionalPolicy) NewAdmitter() Admitter {
+	return p
+}
+
+// Admit replaces the minimum counter with a probability proportional to the
+// estimate
+func (ProportionalPolicy) Admit(c *AdmissionCandidate) Admission {
+	if c.Estimate >= c.MinCardinality {
+		return Replace
+	}
+
+	// Uniform in [0, 1) from the top 53 bits of the hash
+	u := float64(c.Hash()>>11) / (1 << 53)
+	if u*float64(c.MinCardinality) < float64(c.Estimate) {
+		return Replace
+	}
+	return Reject
+}
+
+// Default size of the doorkeeper of DoorkeeperPolicy
+const defaultDoorkeeperBits = 1 << 16
+
+// DoorkeeperPolicy filters out labels seen only once, like the doorkeeper of
+// TinyLFU: the first item of an untracked label only records the label in a
+// Bloom filter of Bits bits, and later items are left to the Next policy.
+// The filter holds the last Bits/8 labels it recorded, at least one, and is
+// cleared before recording another, so labels must recur within that window.
+// Bits defaults to 2^16 and Next to TrailingZerosPolicy.
+type DoorkeeperPolicy struct {
+	Bits int
+	Next AdmissionPolicy
+}
+
+// NewAdmitter creates an empty doorkeeper for a sketch
+func (p DoorkeeperPolicy) NewAdmitter() Admitter {
+	bits := p.Bits
+	if bits <= 0 {
+		bits = defaultDoorkeeperBits
+	}
+
+	next := p.Next
+	if next == nil {
+		next = TrailingZerosPolicy{}
+	}
+
+	limit := bits / 8
+	if limit < 1 {
+		limit = 1
+	}
+
+	return &doorkeeper{
+		words: make([]uint64, (bits+63)/64),
+		limit: limit,
+		next:  next.NewAdmitter(),
+	}
+}
+
+// doorkeeper is the state of DoorkeeperPolicy for one sketch
+type doorkeeper struct {
+	words []uint64
+	// added counts the labels recorded since the filter was last cleared
+	added int
+	limit int
+	next  Admitter
+}
+
+// Admit records labels seen for the first time and defers the others
+func (d *doorkeeper) Admit(c *AdmissionCandidate) Admission {
+	hash := c.LabelHash()
+	numBits := uint64(len(d.words) * 64)
+
+	// Two probes by double hashing
+	bit1 := hash % numBits
+	bit2 := (hash>>32 | hash<<32) % numBits
+	if d.test(bit1) && d.test(bit2) {
+		return d.next.Admit(c)
+	}
+
+	// Clear a full filter before recording, so the label can recur
+	if d.added >= d.limit {
+		for i := range d.words {
+			d.words[i] = 0
+		}
+		d.added = 0
+	}
+
+	d.set(bit1)
+	d.set(bit2)
+	d.added++
+	return Reject
+}
+
+func (d *doorkeeper) test(bit uint64) bool {
+	return d.words[bit/64]&(1<<(bit%64)) != 0
+}
+
+func (d *doorkeeper) set(bit uint64) {
+	d.words[bit/64] |= 1 << (bit % 64)
+}
@@ -0,0 +1,284 @@
+package ssss
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestAdmissionPolicies(t *testing.T) {
+	newConfig := func(t *testing.T, maxNumCounters int, policy AdmissionPolicy) *Config {
+		config, err := NewConfigWithOptions(maxNumCounters,
+			WithPrecision(8),
+			WithSeeds([]uint64{0, 1, 2, 3}),
+			WithHLLSeeds([]uint64{8, 9, 10, 11, 12, 13, 14, 15}),
+			WithAdmissionPolicy(policy),
+		)
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+		return config
+	}
+
+	t.Run("Trailing Zeros", func(t *testing.T) {
+		admitter := TrailingZerosPolicy{}.NewAdmitter()
+
+		if decision := admitter.Admit(&AdmissionCandidate{Estimate: 9, MinCardinality: 8}); decision != Replace {
+			t.Errorf("Expected an estimate above the minimum to be admitted, got %v", decision)
+		}
+
+		if decision := admitter.Admit(&AdmissionCandidate{Estimate: 8, MinCardinality: 8}); decision != Reject {
+			t.Errorf("Expected an estimate at the minimum to be rejected, got %v", decision)
+		}
+	})
+
+	t.Run("Inheritance Admits Every Label", func(t *testing.T) {
+		sketch := NewHLLSamplingSpaceSavingSets[int, uint64](newConfig(t, 2, InheritancePolicy{}))
+		for i := uint64(0); i < 10; i++ {
+			sketch.Insert(1, i)
+		}
+		sketch.Insert(2, 100)
+
+		// Label 3 takes over the counter of label 2 with its item
+		sketch.Insert(3, 200)
+		if sketch.Cardinality(3) != 2 {
+			t.Errorf("Expected label 3 to have the inherited item and its own, got %d", sketch.Cardinality(3))
+		}
+
+		if _, tracked := sketch.counters[2]; tracked {
+			t.Error("Expected label 2 to be evicted")
+		}
+	})
+
+	t.Run("Proportional Replacement", func(t *testing.T) {
+		admitter := ProportionalPolicy{}.NewAdmitter()
+
+		if decision := admitter.Admit(&AdmissionCandidate{Estimate: 8, MinCardinality: 8}); decision != Replace {
+			t.Errorf("Expected an estimate at the minimum to be admitted, got %v", decision)
+		}
+
+		// An estimate of a quarter of the minimum is admitted a quarter of
+		// the time
+		admitted := 0
+		for i := 0; i < 10000; i++ {
+			candidate := &AdmissionCandidate{Label: i, Item: i, Estimate: 2, MinCardinality: 8}
+			if admitter.Admit(candidate) == Replace {
+				admitted++
+			}
+		}
+
+		if admitted < 2300 || admitted > 2700 {
+			t.Errorf("Expected about 2500 admissions, got %d", admitted)
+		}
+	})
+
+	t.Run("Doorkeeper Rejects First Sightings", func(t *testing.T) {
+		admitter := DoorkeeperPolicy{Bits: 1024, Next: InheritancePolicy{}}.NewAdmitter()
+		candidate := &AdmissionCandidate{Label: "new", Item: 1, Estimate: 1 << 20}
+
+		if decision := admitter.Admit(candidate); decision != Reject {
+			t.Errorf("Expected the first sighting to be rejected, got %v", decision)
+		}
+
+		if decision := admitter.Admit(candidate); decision != Inherit {
+			t.Errorf("Expected the second sighting to be left to the next policy, got %v", decision)
+		}
+
+		// Recording more labels than the filter holds clears it
+		for i := 0; i <= 1024/8; i++ {
+			admitter.Admit(&AdmissionCandidate{Label: i})
+		}
+
+		if decision := admitter.Admit(candidate); decision != Reject {
+			t.Errorf("Expected the label to be forgotten, got %v", decision)
+		}
+	})
+
+	t.Run("Doorkeeper Passes Labels Recurring In The Window", func(t *testing.T) {
+		for _, bits := range []int{4, 64, 1024} {
+			admitter := DoorkeeperPolicy{Bits: bits, Next: InheritancePolicy{}}.NewAdmitter()
+
+			// Fill the window, so that every label recorded next clears the
+			// filter first
+			for i := 0; i < bits/8; i++ {
+				admitter.Admit(&AdmissionCandidate{Label: i})
+			}
+
+			for i := 0; i < 10; i++ {
+				candidate := &AdmissionCandidate{Label: fmt.Sprintf("recurring-%d", i), Item: 1, Estimate: 1 << 20}
+				admitter.Admit(candidate)
+
+				if decision := admitter.Admit(candidate); decision != Inherit {
+					t.Errorf("Bits %d: expected the recurring %v to be left to the next policy, got %v", bits, candidate.Label, decision)
+				}
+			}
+		}
+	})
+
+	t.Run("Pinned Counters Are Kept", func(t *testing.T) {
+		sketch := NewHLLSamplingSpaceSavingSets[int, uint64](newConfig(t, 1, InheritancePolicy{}))
+		if err := sketch.Pin(1); err != nil {
+			t.Fatalf("Failed to pin label: %v", err)
+		}
+
+		sketch.Insert(2, 1)
+		if !sketch.Pinned(1) || sketch.Cardinality(2) != 0 {
+			t.Error("Expected the pinned counter to be kept")
+		}
+	})
+
+	t.Run("Eviction Heap Tracks The Minimum", func(t *testing.T) {
+		policies := map[string]AdmissionPolicy{
+			"trailing zeros": nil,
+			"inheritance":    InheritancePolicy{},
+			"proportional":   ProportionalPolicy{},
+			"doorkeeper":     DoorkeeperPolicy{Bits: 1 << 10},
+		}
+
+		for name, policy := range policies {
+			rng := rand.New(rand.NewSource(3))
+			sketch := NewHLLSamplingSpaceSavingSets[int, uint64](newConfig(t, 20, policy))
+			other := NewHLLSamplingSpaceSavingSets[int, uint64](newConfig(t, 20, policy))
+
+			for i := 0; i < 20000; i++ {
+				label := int(rng.ExpFloat64() * 30)
+				sketch.Insert(label, rng.Uint64())
+
+				// Changes outside of Insert drop the heap
+				switch i % 2500 {
+				case 500:
+					other.Insert(label, rng.Uint64())
+					if err := sketch.Merge(other); err != nil {
+						t.Fatalf("Failed to merge sketches: %v", err)
+					}
+				case 1000:
+					if err := sketch.Pin(label); err != nil {
+						t.Fatalf("Failed to pin label: %v", err)
+					}
+				case 1500:
+					sketch.Unpin(label)
+				case 2000:
+					sketch.ResetLabel(label)
+				}
+
+				if !sketch.heaped {
+					continue
+				}
+				if _, want := sketch.minCounter(); sketch.candidates.Len() > 0 &&
+					sketch.candidates[0].counter.Cardinality() != want {
+					t.Fatalf("Expected minimum cardinality %d with the %s policy, got %d",
+						want, name, sketch.candidates[0].counter.Cardinality())
+				}
+				if sketch.candidates.Len() != len(sketch.counters)-len(sketch.pinned) {
+					t.Fatalf("Expected %d eviction candidates with the %s policy, got %d",
+						len(sketch.counters)-len(sketch.pinned), name, sketch.candidates.Len())
+				}
+				for index, candidate := range sketch.candidates {
+					if sketch.counters[candidate.label] != candidate.counter || candidate.counter.index != index {
+						t.Fatalf("Heap entry %d of label %d is out of date with the %s policy", index, candidate.label, name)
+					}
+				}
+			}
+		}
+	})
+}
+
+// admissionEvent is an insert of the admission benchmarks
+type admissionEvent struct {
+	label int
+	item  uint64
+}
+
+// zipfStream returns a stream of events whose labels follow a Zipf
+// distribution, with the number of distinct items of each label
+func zipfStream(numEvents int, numLabels int) ([]admissionEvent, map[int]uint64) {
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, uint64(numLabels-1))
+
+	events := make([]admissionEvent, numEvents)
+	seen := make(map[admissionEvent]struct{})
+	distinct := make(map[int]uint64)
+	for i := range events {
+		label := int(zipf.Uint64())
+		item := (uint64(label)<<32 | uint64(r.Intn(1<<16))) * 0x9e3779b97f4a7c15
+		events[i] = admissionEvent{label, item}
+
+		if _, exists := seen[events[i]]; !exists {
+			seen[events[i]] = struct{}{}
+			distinct[label]++
+		}
+	}
+
+	return events, distinct
+}
+
+// BenchmarkAdmissionPolicies compares the admission policies on a Zipf
+// stream. Next to the insert time, it reports the recall of the true top 20
+// labels by distinct items and the mean relative error of their estimates.
+func BenchmarkAdmissionPolicies(b *testing.B) {
+	const k = 20
+	events, distinct := zipfStream(200000, 20000)
+
+	var labels []int
+	for label := range distinct {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return distinct[labels[i]] > distinct[labels[j]]
+	})
+	top := make(map[int]bool, k)
+	for _, label := range labels[:k] {
+		top[label] = true
+	}
+
+	policies := []struct {
+		name   string
+		policy AdmissionPolicy
+	}{
+		{"TrailingZeros", nil},
+		{"Inheritance", InheritancePolicy{}},
+		{"Proportional", ProportionalPolicy{}},
+		{"Doorkeeper", DoorkeeperPolicy{}},
+	}
+
+	for _, p := range policies {
+		b.Run(p.name, func(b *testing.B) {
+			config, err := NewConfigWithOptions(100,
+				WithPrecision(10),
+				WithMasterSeed("benchmark"),
+				WithAdmissionPolicy(p.policy),
+			)
+			if err != nil {
+				b.Fatalf("Failed to create config: %v", err)
+			}
+
+			// Accuracy after one pass over the stream
+			sketch := NewHLLSamplingSpaceSavingSets[int, uint64](config)
+			for _, e := range events {
+				sketch.Insert(e.label, e.item)
+			}
+
+			found := 0
+			var totalError float64
+			for _, entry := range sketch.Top(k) {
+				if top[entry.Label] {
+					found++
+				}
+			}
+			for label := range top {
+				totalError += relativeError(sketch.Cardinality(label), distinct[label])
+			}
+
+			sketch = NewHLLSamplingSpaceSavingSets[int, uint64](config)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e := events[i%len(events)]
+				sketch.Insert(e.label, e.item)
+			}
+
+			b.ReportMetric(float64(found)/k, "recall")
+			b.ReportMetric(totalError/k, "relerr")
+		})
+	}
+}
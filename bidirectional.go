@@ -0,0 +1,165 @@
+package ssss
+
+import (
+	"sort"
+)
+
+// BidirectionalSketch tracks heavy distinct hitters in both directions: the
+// labels with the most distinct items, and the items spread across the most
+// distinct labels. For example, with destination ports as labels and source
+// IPs as items, the reverse direction finds sources scanning many ports.
+//
+// Both directions are SamplingSpaceSavingSets sketches built from the same
+// configuration, so they share seeds and hashing.
+type BidirectionalSketch[L comparable, T comparable] struct {
+	forward *SamplingSpaceSavingSets[L, T]
+	reverse *SamplingSpaceSavingSets[T, L]
+}
+
+// NewBidirectionalSketch creates a new BidirectionalSketch
+func NewBidirectionalSketch[L comparable, T comparable](config *Config) *BidirectionalSketch[L, T] {
+	return &BidirectionalSketch[L, T]{
+		forward: NewHLLSamplingSpaceSavingSets[L, T](config),
+		reverse: NewHLLSamplingSpaceSavingSets[T, L](config),
+	}
+}
+
+// Insert records that an item was seen with a label
+func (b *BidirectionalSketch[L, T]) Insert(label L, item T) {
+	b.forward.Insert(label, item)
+	b.reverse.Insert(item, label)
+}
+
+// Merge combines this sketch with another one built from a compatible
+// configuration
+func (b *BidirectionalSketch[L, T]) Merge(other *BidirectionalSketch[L, T]) error {
+	if other == nil {
+		return &IncompatibleSketchError{Reason: "cannot merge with a nil sketch"}
+	}
+
+	if err := b.forward.Merge(other.forward); err != nil {
+		return err
+	}
+	return b.reverse.Merge(other.reverse)
+}
+
+// Clear resets the sketch to its initial state
+func (b *BidirectionalSketch[L, T]) Clear() {
+	b.forward.Clear()
+	b.reverse.Clear()
+}
+
+// Forward returns the sketch of distinct items per label
+func (b *BidirectionalSketch[L, T]) Forward() *SamplingSpaceSavingSets[L, T] {
+	return b.forward
+}
+
+// Reverse returns the sketch of distinct labels per item
+func (b *BidirectionalSketch[L, T]) Reverse() *SamplingSpaceSavingSets[T, L] {
+	return b.reverse
+}
+
+// Cardinality returns the estimated number of distinct items of a label
+func (b *BidirectionalSketch[L, T]) Cardinality(label L) uint64 {
+	return b.forward.Cardinality(label)
+}
+
+// FanOut returns the estimated number of distinct labels of an item
+func (b *BidirectionalSketch[L, T]) FanOut(item T) uint64 {
+	return b.reverse.Cardinality(item)
+}
+
+// TopLabels returns the k labels with the most distinct items
+func (b *BidirectionalSketch[L, T]) TopLabels(k int) []LabelCount[L] {
+	return b.forward.Top(k)
+}
+
+// TopItems returns the k items with the most distinct labels
+func (b *BidirectionalSketch[L, T]) TopItems(k int) []LabelCount[T] {
+	return b.reverse.Top(k)
+}
+
+// SuperSpreaders returns the tracked items whose estimated number of distinct
+// labels exceeds threshold, by descending fan-out
+func (b *BidirectionalSketch[L, T]) SuperSpreaders(threshold uint64) []LabelCount[T] {
+	var entries []LabelCount[T]
+	for item, counter := range b.reverse.counters {
+		if fanOut := counter.Cardinality(); fanOut > threshold {
+			entries = append(entries, LabelCount[T]{Label: item, Count: fanOut})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Count > entries[j].Count
+	})
+
+	return entries
+}
+
+// SuperSpreaderDetector reports items as soon as their fan-out, the estimated
+// number of distinct labels they were seen with, exceeds a threshold. Each
+// item is reported once. Items evicted from the sketch are forgotten when the
+// record of reported items fills up, and can then be reported again.
+type SuperSpreaderDetector[L comparable, T comparable] struct {
+	sketch    *BidirectionalSketch[L, T]
+	threshold uint64
+	reported  map[T]struct{}
+}
+
+// NewSuperSpreaderDetector creates a detector for items whose fan-out exceeds
+// threshold
+func NewSuperSpreaderDetector[L comparable, T comparable](
+	config *Config,
+	threshold uint64,
+) *SuperSpreaderDetector[L, T] {
+	return &SuperSpreaderDetector[L, T]{
+		sketch:    NewBidirectionalSketch[L, T](config),
+		threshold: threshold,
+		reported:  make(map[T]struct{}),
+	}
+}
+
+// Insert records that an item was seen with a label. It returns true, along
+// with the fan-out of the item, the first time the fan-out exceeds the
+// threshold.
+func (d *SuperSpreaderDetector[L, T]) Insert(label L, item T) (uint64, bool) {
+	d.sketch.Insert(label, item)
+
+	counter, tracked := d.sketch.reverse.counters[item]
+	if !tracked {
+		return 0, false
+	}
+
+	fanOut := counter.Cardinality()
+	if fanOut <= d.threshold {
+		return fanOut, false
+	}
+
+	if _, reported := d.reported[item]; reported {
+		return fanOut, false
+	}
+
+	// Forget items that were evicted since they were reported, which keeps
+	// the record bounded by the number of counters
+	if len(d.reported) >= d.sketch.reverse.config.MaxNumCounters {
+		for reportedItem := range d.reported {
+			if _, tracked := d.sketch.reverse.counters[reportedItem]; !tracked {
+				delete(d.reported, reportedItem)
+			}
+		}
+	}
+
+	d.reported[item] = struct{}{}
+	return fanOut, true
+}
+
+// Sketch returns the underlying bidirectional sketch
+func (d *SuperSpreaderDetector[L, T]) Sketch() *BidirectionalSketch[L, T] {
+	return d.sketch
+}
+
+// Clear resets the detector, including the record of reported items
+func (d *SuperSpreaderDetector[L, T]) Clear() {
+	d.sketch.Clear()
+	d.reported = make(map[T]struct{})
+}
@@ -0,0 +1,121 @@
+package ssss
+
+import (
+	"testing"
+)
+
+func TestBidirectionalSketch(t *testing.T) {
+	// newConfig creates a configuration shared by both directions
+	newConfig := func(t *testing.T) *Config {
+		t.Helper()
+
+		hllConfig, err := NewHLLConfig(256, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(10, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		return config
+	}
+
+	// scan inserts traffic where source 1 scans 500 ports and sources 100
+	// to 199 each hit the same 3 ports
+	scan := func(insert func(port, source int)) {
+		for port := 0; port < 500; port++ {
+			insert(port, 1)
+		}
+		for source := 100; source < 200; source++ {
+			for port := 80; port < 83; port++ {
+				insert(port, source)
+			}
+		}
+	}
+
+	t.Run("Both Directions", func(t *testing.T) {
+		sketch := NewBidirectionalSketch[int, int](newConfig(t))
+		scan(sketch.Insert)
+
+		top := sketch.TopItems(1)
+		if len(top) != 1 || top[0].Label != 1 {
+			t.Fatalf("Expected source 1 to have the largest fan-out, got %v", top)
+		}
+
+		if relativeError(sketch.FanOut(1), 500) > 0.2 {
+			t.Errorf("Expected fan-out close to 500, got %d", sketch.FanOut(1))
+		}
+
+		// Ports 80 to 82 are hit by the 100 sources and the scanner
+		for _, entry := range sketch.TopLabels(3) {
+			if entry.Label < 80 || entry.Label > 82 {
+				t.Errorf("Expected ports 80 to 82 at the top, got %v", entry)
+			}
+		}
+	})
+
+	t.Run("Super Spreaders", func(t *testing.T) {
+		sketch := NewBidirectionalSketch[int, int](newConfig(t))
+		scan(sketch.Insert)
+
+		spreaders := sketch.SuperSpreaders(50)
+		if len(spreaders) != 1 || spreaders[0].Label != 1 {
+			t.Errorf("Expected source 1 as the only super spreader, got %v", spreaders)
+		}
+
+		if len(sketch.SuperSpreaders(1000)) != 0 {
+			t.Error("Expected no super spreaders above 1000")
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		config := newConfig(t)
+		sketch1 := NewBidirectionalSketch[int, int](config)
+		sketch2 := NewBidirectionalSketch[int, int](config)
+
+		// The scanner is split across both sketches
+		for port := 0; port < 500; port++ {
+			if port%2 == 0 {
+				sketch1.Insert(port, 1)
+			} else {
+				sketch2.Insert(port, 1)
+			}
+		}
+
+		if err := sketch1.Merge(sketch2); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		if relativeError(sketch1.FanOut(1), 500) > 0.2 {
+			t.Errorf("Expected fan-out close to 500 after merge, got %d", sketch1.FanOut(1))
+		}
+
+		if err := sketch1.Merge(nil); err == nil {
+			t.Error("Expected error merging a nil sketch")
+		}
+	})
+
+	t.Run("Detector", func(t *testing.T) {
+		detector := NewSuperSpreaderDetector[int, int](newConfig(t), 50)
+
+		var reports []int
+		scan(func(port, source int) {
+			if fanOut, crossed := detector.Insert(port, source); crossed {
+				if fanOut <= 50 {
+					t.Errorf("Reported fan-out %d below the threshold", fanOut)
+				}
+				reports = append(reports, source)
+			}
+		})
+
+		if len(reports) != 1 || reports[0] != 1 {
+			t.Errorf("Expected source 1 to be reported once, got %v", reports)
+		}
+
+		detector.Clear()
+		if len(detector.Sketch().SuperSpreaders(0)) != 0 {
+			t.Error("Expected an empty sketch after Clear")
+		}
+	})
+}
@@ -4,6 +4,14 @@ package ssss
 type CachedSketch[T comparable] struct {
 	sketch      CardinalitySketch[T]
 	cardinality uint64
+	// frequency and frequencyError are the event count of the label and its
+	// Space-Saving error, maintained by SamplingSpaceSavingSets when
+	// Config.TrackFrequencies is set
+	frequency      uint64
+	frequencyError uint64
+	// index is the position of the counter in the eviction heap of
+	// SamplingSpaceSavingSets, or -1 when it is pinned
+	index int
 }
 
 // NewCachedSketch creates a new cached sketch
@@ -22,12 +30,11 @@ func (c *CachedSketch[T]) Insert(item T) {
 
 // Merge combines this sketch with another sketch of the same type
 func (c *CachedSketch[T]) Merge(other CardinalitySketch[T]) error {
-	otherCached, ok := other.(*CachedSketch[T])
-	if !ok {
-		return c.sketch.Merge(other)
+	if otherCached, ok := other.(*CachedSketch[T]); ok {
+		other = otherCached.sketch
 	}
 
-	err := c.sketch.Merge(otherCached.sketch)
+	err := c.sketch.Merge(other)
 	if err != nil {
 		return err
 	}
@@ -40,6 +47,8 @@ func (c *CachedSketch[T]) Merge(other CardinalitySketch[T]) error {
 func (c *CachedSketch[T]) Clear() {
 	c.sketch.Clear()
 	c.cardinality = 0
+	c.frequency = 0
+	c.frequencyError = 0
 }
 
 // Cardinality returns the cached cardinality value
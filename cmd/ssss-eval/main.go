@@ -0,0 +1,276 @@
+// Command ssss-eval measures the accuracy and throughput of
+// SamplingSpaceSavingSets on synthetic workloads with known ground truth.
+//
+// It runs every combination of workload, admission policy, number of
+// counters and HLL precision, and writes one row per run:
+//
+//	ssss-eval -workloads zipf,adversarial -counters 50,100,200,400 -format csv
+//
+// Sweeping the number of counters produces the series of error and recall
+// against sketch size, to plot with any tool that reads CSV.
+//
+// Traces recorded with eval.TraceWriter replace the synthetic workloads:
+//
+//	ssss-eval -traces prod.trace -counters 100,1000 -precision 8,10,12
+//
+// The other settings of ssss.NewConfigWithOptions apply to every run, to
+// measure their cost in size and throughput:
+//
+//	ssss-eval -totals -frequencies -subset-sample 1024 -hash-key 0x1234,0x5678
+//	ssss-eval -hasher datasketches -seeds 1,2,3,4 -hll-seeds 5,6
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sawmills/go-ssss"
+	"github.com/sawmills/go-ssss/eval"
+)
+
+// options are the command-line flags
+type options struct {
+	workloads, traces, policies, counters, precisions string
+	events, labels, items                             int
+	exponent                                          float64
+	heavy, k                                          int
+	seed                                              int64
+	format                                            string
+
+	// Settings of options.go applied to every run
+	seeds, hllSeeds string
+	hasher          string
+	totals          bool
+	frequencies     bool
+	subsetSample    int
+	hashKey         string
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.workloads, "workloads", "zipf,uniform,adversarial", "comma-separated workloads: zipf, uniform, adversarial")
+	flag.StringVar(&o.traces, "traces", "", "comma-separated trace files to replay instead of the workloads")
+	flag.StringVar(&o.policies, "policies", "trailing-zeros", "comma-separated admission policies: trailing-zeros, inheritance, proportional, doorkeeper")
+	flag.StringVar(&o.counters, "counters", "50,100,200,400", "comma-separated numbers of counters")
+	flag.StringVar(&o.precisions, "precision", "10", "comma-separated HLL precisions")
+	flag.IntVar(&o.events, "events", 1000000, "number of events per workload")
+	flag.IntVar(&o.labels, "labels", 100000, "number of labels of the zipf and uniform workloads")
+	flag.IntVar(&o.items, "items", 10000, "number of items per label of the zipf and uniform workloads")
+	flag.Float64Var(&o.exponent, "exponent", 1.1, "exponent of the zipf workload, greater than 1")
+	flag.IntVar(&o.heavy, "heavy", 20, "number of heavy labels of the adversarial workload")
+	flag.IntVar(&o.k, "k", 20, "number of top labels to compare")
+	flag.Int64Var(&o.seed, "seed", 1, "seed of the workloads and master seed of the sketches")
+	flag.StringVar(&o.format, "format", "markdown", "output format: markdown or csv")
+	flag.StringVar(&o.seeds, "seeds", "", "comma-separated sampling seeds, instead of those derived from -seed")
+	flag.StringVar(&o.hllSeeds, "hll-seeds", "", "comma-separated HLL seeds, instead of those derived from -seed")
+	flag.StringVar(&o.hasher, "hasher", "", "hasher of the cardinality sketches: redis, datasketches or postgres")
+	flag.BoolVar(&o.totals, "totals", false, "track the distinct items and labels of the whole sketch")
+	flag.BoolVar(&o.frequencies, "frequencies", false, "count the events of each label")
+	flag.IntVar(&o.subsetSample, "subset-sample", 0, "size of the sample of label and item pairs for subset queries")
+	flag.StringVar(&o.hashKey, "hash-key", "", "two comma-separated 64-bit keys to hash with keyed SipHash")
+	flag.Parse()
+
+	if err := run(o); err != nil {
+		fmt.Fprintln(os.Stderr, "ssss-eval:", err)
+		os.Exit(1)
+	}
+}
+
+func run(o options) error {
+	write := eval.WriteMarkdown
+	switch o.format {
+	case "markdown":
+	case "csv":
+		write = eval.WriteCSV
+	default:
+		return fmt.Errorf("unknown format %q", o.format)
+	}
+
+	counters, err := parseInts(o.counters)
+	if err != nil {
+		return fmt.Errorf("invalid counters: %w", err)
+	}
+
+	precisions, err := parseInts(o.precisions)
+	if err != nil {
+		return fmt.Errorf("invalid precision: %w", err)
+	}
+
+	opts, err := sketchOptions(o)
+	if err != nil {
+		return err
+	}
+
+	var settings []eval.Setting
+	for _, name := range strings.Split(o.policies, ",") {
+		policy, exists := eval.Policies[name]
+		if !exists {
+			return fmt.Errorf("unknown policy %q", name)
+		}
+
+		for _, maxNumCounters := range counters {
+			for _, precision := range precisions {
+				setting := eval.Setting{
+					Policy:          name,
+					MaxNumCounters:  maxNumCounters,
+					HLLPrecision:    precision,
+					AdmissionPolicy: policy,
+					MasterSeed:      strconv.FormatInt(o.seed, 10),
+					Options:         opts,
+				}
+
+				// DataSketches hashes depend on the precision
+				if o.hasher == "datasketches" {
+					setting.Options = append(opts[:len(opts):len(opts)],
+						ssss.WithHasher(ssss.DataSketchesHasher{LgK: precision}))
+				}
+				settings = append(settings, setting)
+			}
+		}
+	}
+
+	var results []eval.Result
+	runSettings := func(workload *eval.Workload) error {
+		for _, setting := range settings {
+			result, err := eval.Run(workload, setting, o.k)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	}
+
+	if o.traces != "" {
+		for _, path := range strings.Split(o.traces, ",") {
+			workload, err := readTrace(path)
+			if err != nil {
+				return err
+			}
+			if err := runSettings(workload); err != nil {
+				return err
+			}
+		}
+		return write(os.Stdout, results)
+	}
+
+	for _, name := range strings.Split(o.workloads, ",") {
+		var workload *eval.Workload
+		switch name {
+		case "zipf":
+			workload, err = eval.Zipf(o.events, o.labels, o.exponent, o.items, o.seed)
+		case "uniform":
+			workload, err = eval.Uniform(o.events, o.labels, o.items, o.seed)
+		case "adversarial":
+			workload, err = eval.Adversarial(o.events, o.heavy, o.seed)
+		default:
+			err = fmt.Errorf("unknown workload %q", name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := runSettings(workload); err != nil {
+			return err
+		}
+	}
+
+	return write(os.Stdout, results)
+}
+
+// sketchOptions returns the options of options.go set by the flags, except
+// the DataSketches hasher, which depends on the precision of each run
+func sketchOptions(o options) ([]ssss.Option, error) {
+	var opts []ssss.Option
+
+	if o.seeds != "" {
+		seeds, err := parseUint64s(o.seeds)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seeds: %w", err)
+		}
+		opts = append(opts, ssss.WithSeeds(seeds))
+	}
+
+	if o.hllSeeds != "" {
+		seeds, err := parseUint64s(o.hllSeeds)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HLL seeds: %w", err)
+		}
+		opts = append(opts, ssss.WithHLLSeeds(seeds))
+	}
+
+	switch o.hasher {
+	case "", "datasketches":
+	case "redis":
+		opts = append(opts, ssss.WithHasher(ssss.RedisHasher{}))
+	case "postgres":
+		opts = append(opts, ssss.WithHasher(ssss.PostgresHasher{}))
+	default:
+		return nil, fmt.Errorf("unknown hasher %q", o.hasher)
+	}
+
+	if o.totals {
+		opts = append(opts, ssss.WithTotals())
+	}
+	if o.frequencies {
+		opts = append(opts, ssss.WithFrequencies())
+	}
+	if o.subsetSample != 0 {
+		opts = append(opts, ssss.WithSubsetSample(o.subsetSample))
+	}
+
+	if o.hashKey != "" {
+		key, err := parseUint64s(o.hashKey)
+		if err != nil || len(key) != 2 {
+			return nil, fmt.Errorf("invalid hash key %q: expected two comma-separated 64-bit numbers", o.hashKey)
+		}
+		opts = append(opts, ssss.WithKeyedHashing(key[0], key[1]))
+	}
+
+	return opts, nil
+}
+
+// readTrace reads a trace file into a workload named after the file
+func readTrace(path string) (*eval.Workload, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	workload, err := eval.ReadTrace(f, filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return workload, nil
+}
+
+// parseUint64s parses a comma-separated list of unsigned 64-bit integers
+func parseUint64s(list string) ([]uint64, error) {
+	var values []uint64
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.ParseUint(strings.TrimSpace(field), 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// parseInts parses a comma-separated list of integers
+func parseInts(list string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
@@ -2,6 +2,8 @@ package ssss
 
 import (
 	"errors"
+	"fmt"
+	"hash/fnv"
 )
 
 // Config represents the configuration for a SamplingSpaceSavingSets sketch
@@ -12,6 +14,30 @@ type Config struct {
 	Seeds []uint64
 	// CardinalitySketchConfig is the configuration for the cardinality sketch
 	CardinalitySketchConfig *HLLConfig
+	// TrackTotals maintains a sketch of all items and a sketch of all labels
+	// next to the counters, see TotalDistinctItems and DistinctLabels
+	TrackTotals bool
+	// TrackFrequencies counts the events of each tracked label next to its
+	// distinct items, see Frequency and TopStats
+	TrackFrequencies bool
+	// SubsetSampleSize is the number of (label, item) pairs sampled to
+	// estimate the distinct items of subsets of labels, see EstimateSubset.
+	// 0 disables the sample.
+	SubsetSampleSize int
+	// KeyedHashing hashes items with SipHash-2-4 keyed by HashKey for the
+	// trailing-zeros estimate of untracked labels, the hashes of
+	// AdmissionCandidate and the subset sample, so items cannot be crafted
+	// to win admission without knowing the key. WithKeyedHashing also keys
+	// the cardinality sketches, see SipHasher.
+	KeyedHashing bool
+	// HashKey is the secret 128-bit key of KeyedHashing. Like
+	// AdmissionPolicy it is not part of the encodings or the fingerprint:
+	// decoded sketches need it again, see SetHashKey.
+	HashKey [2]uint64
+	// AdmissionPolicy decides which labels take over a counter once all
+	// counters are in use. Nil means TrailingZerosPolicy. It is not part of
+	// the encodings or the fingerprint, and merges ignore it.
+	AdmissionPolicy AdmissionPolicy
 }
 
 // NewConfig creates a new configuration for a SamplingSpaceSavingSets sketch
@@ -20,21 +46,126 @@ func NewConfig(
 	cardinalitySketchConfig *HLLConfig,
 	seeds []uint64,
 ) (*Config, error) {
-	if maxNumCounters == 0 {
-		return nil, errors.New("max number of counters must be greater than zero")
-	}
-
 	// If no seeds are provided, generate random ones
 	if seeds == nil {
-		seeds = make([]uint64, 4)
-		for i := range seeds {
-			seeds[i] = secureRandomInt()
+		var err error
+		if seeds, err = randomSeeds(numSeeds); err != nil {
+			return nil, err
 		}
 	}
 
-	return &Config{
+	config := &Config{
 		MaxNumCounters:          maxNumCounters,
 		Seeds:                   seeds,
 		CardinalitySketchConfig: cardinalitySketchConfig,
-	}, nil
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate checks that sketches can be built with the configuration: there
+// is at least one counter, a subset sample size that is not negative and a
+// valid cardinality sketch configuration, and a HashKey with KeyedHashing.
+// The sampling seeds may be empty. Its errors match ErrInvalidConfig.
+func (c *Config) Validate() error {
+	return invalidConfig(c.validate())
+}
+
+func (c *Config) validate() error {
+	if c.MaxNumCounters <= 0 {
+		return fmt.Errorf("max number of counters must be greater than zero, got %d", c.MaxNumCounters)
+	}
+
+	if c.SubsetSampleSize < 0 {
+		return fmt.Errorf("subset sample size must not be negative, got %d", c.SubsetSampleSize)
+	}
+
+	if c.CardinalitySketchConfig == nil {
+		return errors.New("missing cardinality sketch config")
+	}
+
+	if err := c.CardinalitySketchConfig.validate(); err != nil {
+		return fmt.Errorf("cardinality sketch config: %w", err)
+	}
+
+	// The key is checked last, so decoders can check everything else
+	if c.KeyedHashing && c.HashKey == ([2]uint64{}) {
+		return errMissingHashKey
+	}
+
+	return nil
+}
+
+// Fingerprint identifies the settings that sketches must share to be merged:
+// the sampling seeds, the HyperLogLog configuration, the tracking flags and
+// KeyedHashing. MaxNumCounters and SubsetSampleSize are left out, since sketches of
+// different capacities can be merged, and so are hashers that cannot be
+// encoded and the secret HashKey. The fingerprint is the 64-bit FNV-1a hash
+// of these settings in their binary encoding, so it is stable across
+// processes and platforms.
+func (c *Config) Fingerprint() uint64 {
+	var e encoder
+	e.seeds(c.Seeds)
+	if c.CardinalitySketchConfig != nil {
+		e.hllSettings(c.CardinalitySketchConfig)
+	}
+	e.uvarint(configFlags(c))
+
+	// The hasher came with version 4 and is only added when it is not the
+	// default, so that the fingerprints of earlier data still match
+	if c.CardinalitySketchConfig != nil {
+		if kind, err := hasherKind(c.CardinalitySketchConfig); err == nil && kind != hasherDefault {
+			e.uvarint(kind)
+		}
+	}
+
+	h := fnv.New64a()
+	h.Write(e.buf)
+	return h.Sum64()
+}
+
+// checkFingerprint compares a fingerprint shipped with a decoded
+// configuration, where 0 means none was shipped
+func (c *Config) checkFingerprint(fingerprint uint64) error {
+	if fingerprint != 0 && fingerprint != c.Fingerprint() {
+		return fmt.Errorf("config fingerprint mismatch: expected %x, got %x", fingerprint, c.Fingerprint())
+	}
+	return nil
+}
+
+// CheckCompatible checks that sketches with the other configuration can be
+// merged into sketches with this one: the sampling seeds, the tracking flags,
+// KeyedHashing with its HashKey and the HyperLogLog configuration must match,
+// see HLLConfig.CheckCompatible.
+// MaxNumCounters may differ, and so may SubsetSampleSize as long as both or
+// neither sketch keeps a subset sample. It returns a *ConfigMismatchError
+// naming the first difference.
+func (c *Config) CheckCompatible(other *Config) error {
+	switch {
+	case !equalSeeds(c.Seeds, other.Seeds):
+		return &ConfigMismatchError{Field: "Seeds"}
+	case c.TrackTotals != other.TrackTotals:
+		return &ConfigMismatchError{Field: "TrackTotals"}
+	case c.TrackFrequencies != other.TrackFrequencies:
+		return &ConfigMismatchError{Field: "TrackFrequencies"}
+	case (c.SubsetSampleSize > 0) != (other.SubsetSampleSize > 0):
+		return &ConfigMismatchError{Field: "SubsetSampleSize"}
+	case c.KeyedHashing != other.KeyedHashing:
+		return &ConfigMismatchError{Field: "KeyedHashing"}
+	case c.KeyedHashing && c.HashKey != other.HashKey:
+		return &ConfigMismatchError{Field: "HashKey"}
+	}
+
+	if err := c.CardinalitySketchConfig.CheckCompatible(other.CardinalitySketchConfig); err != nil {
+		var mismatch *ConfigMismatchError
+		if errors.As(err, &mismatch) {
+			return &ConfigMismatchError{Field: "CardinalitySketchConfig." + mismatch.Field}
+		}
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,479 @@
+package ssss
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// DataSketchesHLLType is the target type of an Apache DataSketches HLL
+// sketch, which sets the number of bits used per register in its image
+type DataSketchesHLLType int
+
+// DataSketches HLL target types
+const (
+	// DataSketchesHLL4 stores registers as 4-bit offsets from the minimum
+	// register, with larger values kept in an auxiliary exception table
+	DataSketchesHLL4 DataSketchesHLLType = iota
+	// DataSketchesHLL6 stores registers in 6 bits
+	DataSketchesHLL6
+	// DataSketchesHLL8 stores registers in a byte each
+	DataSketchesHLL8
+)
+
+// DataSketches HLL parameters, see PreambleUtil.java in datasketches-java
+const (
+	dsHLLMinLgK = 4
+	dsHLLMaxLgK = 21
+	dsHLLSeed   = 9001
+
+	dsSerVer      = 1
+	dsFamilyID    = 7
+	dsListPreInts = 2
+	dsSetPreInts  = 3
+	dsHLLPreInts  = 10
+
+	dsHLLByteArrStart = 40
+	dsKeyBits26       = 26
+	dsKeyMask26       = 1<<dsKeyBits26 - 1
+	dsAuxToken        = 15
+
+	// Flags
+	dsBigEndianFlag  = 1
+	dsEmptyFlag      = 4
+	dsCompactFlag    = 8
+	dsOutOfOrderFlag = 16
+
+	// Modes
+	dsModeList = 0
+	dsModeSet  = 1
+	dsModeHLL  = 2
+)
+
+// dsLgAuxArrInts is the initial log2 size of the HLL_4 exception table by lgK
+var dsLgAuxArrInts = [...]int{0, 2, 2, 2, 2, 2, 2, 3, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 11, 12, 13}
+
+// DataSketchesHasher hashes items the way DataSketches HllSketch.update does:
+// MurmurHash3 x64 128 with seed 9001 over integers as 8 little-endian bytes,
+// floats as their canonical IEEE 754 bits, strings as UTF-8 and byte slices
+// as they are. Other items are hashed as their %v formatting, like strings.
+//
+// The hash holds the register index DataSketches takes from the first half
+// of the MurmurHash3 output in its low LgK bits, and the register value it
+// takes from the second half above them. LgK must be the log2 of the number
+// of registers of the sketch. Unlike DataSketches, empty strings and byte
+// slices are not ignored.
+type DataSketchesHasher struct {
+	LgK int
+}
+
+// Hash returns the DataSketches hash of an item
+func (d DataSketchesHasher) Hash(item any) uint64 {
+	h0, h1 := murmurHash3x64128(dataSketchesItemBytes(item), dsHLLSeed)
+
+	// DataSketches uses the low bits of the first half as the register index
+	// and one more than the leading zeros of the second half, at most 63, as
+	// the value, like the coupons of HllSketch.update in datasketches-java
+	value := bits.LeadingZeros64(h1) + 1
+	if value > 63 {
+		value = 63
+	}
+
+	mask := uint64(1)<<uint(d.LgK) - 1
+	return h0&mask | uint64(value)<<uint(d.LgK)
+}
+
+// registerValue returns the value DataSketches stores for a hash
+func (DataSketchesHasher) registerValue(hash uint64, registerBits uint) uint8 {
+	return uint8(hash >> registerBits)
+}
+
+// maxRegisterValue returns the largest value Hash stores above the index
+func (DataSketchesHasher) maxRegisterValue(registerBits uint) uint8 {
+	return 63
+}
+
+// dataSketchesItemBytes returns the bytes DataSketches hashes for an item
+func dataSketchesItemBytes(item any) []byte {
+	var n uint64
+	switch v := item.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	case int:
+		n = uint64(v)
+	case int8:
+		n = uint64(v)
+	case int16:
+		n = uint64(v)
+	case int32:
+		n = uint64(v)
+	case int64:
+		n = uint64(v)
+	case uint:
+		n = uint64(v)
+	case uint8:
+		n = uint64(v)
+	case uint16:
+		n = uint64(v)
+	case uint32:
+		n = uint64(v)
+	case uint64:
+		n = v
+	case float32:
+		n = dataSketchesDoubleBits(float64(v))
+	case float64:
+		n = dataSketchesDoubleBits(v)
+	default:
+		return []byte(fmt.Sprint(item))
+	}
+
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	return b[:]
+}
+
+// dataSketchesDoubleBits canonicalizes a double like HllSketch.update(double):
+// -0.0 is hashed as 0.0 and all NaNs as Java's canonical NaN
+func dataSketchesDoubleBits(v float64) uint64 {
+	switch {
+	case v == 0:
+		return 0
+	case math.IsNaN(v):
+		return 0x7ff8000000000000
+	}
+	return math.Float64bits(v)
+}
+
+// NewDataSketchesHLLConfig creates a HyperLogLog configuration with 2^lgK
+// registers whose sketches are register-compatible with DataSketches HLL
+// sketches of the same lgK
+func NewDataSketchesHLLConfig(lgK int) (*HLLConfig, error) {
+	if lgK < dsHLLMinLgK || lgK > dsHLLMaxLgK {
+		return nil, errors.New("lgK must be between 4 and 21 for DataSketches")
+	}
+
+	return newHLLConfig(1<<uint(lgK), []uint64{}, DataSketchesHasher{LgK: lgK})
+}
+
+// HyperLogLogFromDataSketches decodes a serialized DataSketches HLL sketch of
+// any mode and target type into a sketch with NewDataSketchesHLLConfig
+func HyperLogLogFromDataSketches[T comparable](data []byte) (*HyperLogLog[T], error) {
+	if len(data) < 8 {
+		return nil, errors.New("truncated DataSketches preamble")
+	}
+
+	config, err := NewDataSketchesHLLConfig(int(data[3]))
+	if err != nil {
+		return nil, err
+	}
+
+	h := NewHyperLogLog[T](config)
+	if err := h.UnmarshalDataSketches(data); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// UnmarshalDataSketches replaces the registers of the sketch with those of a
+// serialized DataSketches HLL sketch in the LIST, SET or HLL mode, compact or
+// updatable. The image must have as many registers as the sketch, and the
+// sketch should use DataSketchesHasher for later insertions to be consistent
+// with DataSketches.
+func (h *HyperLogLog[T]) UnmarshalDataSketches(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("truncated DataSketches preamble")
+	}
+
+	preInts, serVer, familyID := data[0], data[1], data[2]
+	lgK, lgArr, flags, mode := int(data[3]), int(data[4]), data[5], data[7]
+
+	switch {
+	case familyID != dsFamilyID:
+		return errors.New("not a DataSketches HLL sketch")
+	case serVer != dsSerVer:
+		return fmt.Errorf("unsupported DataSketches serialization version %d", serVer)
+	case flags&dsBigEndianFlag != 0:
+		return errors.New("big-endian DataSketches images are not supported")
+	case lgK < dsHLLMinLgK || lgK > dsHLLMaxLgK:
+		return errors.New("lgK must be between 4 and 21 for DataSketches")
+	case lgArr > dsKeyBits26:
+		return errors.New("invalid DataSketches array size")
+	case h.config.NumRegisters != 1<<uint(lgK):
+		return fmt.Errorf("sketch must have %d registers for this DataSketches image", 1<<uint(lgK))
+	}
+
+	compact := flags&dsCompactFlag != 0
+	registers := make([]byte, h.config.NumRegisters)
+
+	switch mode & 3 {
+	case dsModeList:
+		if preInts != dsListPreInts {
+			return errors.New("invalid DataSketches LIST preamble")
+		}
+		if flags&dsEmptyFlag == 0 {
+			count := int(data[6])
+			if !compact {
+				count = 1 << uint(lgArr)
+			}
+			if err := dataSketchesCoupons(registers, data[8:], count); err != nil {
+				return err
+			}
+		}
+
+	case dsModeSet:
+		if preInts != dsSetPreInts || len(data) < 12 {
+			return errors.New("invalid DataSketches SET preamble")
+		}
+		count := int(binary.LittleEndian.Uint32(data[8:]))
+		if !compact {
+			count = 1 << uint(lgArr)
+		}
+		if err := dataSketchesCoupons(registers, data[12:], count); err != nil {
+			return err
+		}
+
+	case dsModeHLL:
+		if preInts != dsHLLPreInts || len(data) < dsHLLByteArrStart {
+			return errors.New("invalid DataSketches HLL preamble")
+		}
+		if err := dataSketchesHLLRegisters(registers, data, DataSketchesHLLType(mode>>2&3)); err != nil {
+			return err
+		}
+
+	default:
+		return errors.New("unsupported DataSketches HLL mode")
+	}
+
+	copy(h.registers, registers)
+	h.recompute()
+	return nil
+}
+
+// dataSketchesCoupons applies count coupons from body to the registers.
+// Empty coupons in updatable images are zero and skipped.
+func dataSketchesCoupons(registers []byte, body []byte, count int) error {
+	if len(body) != count*4 {
+		return errors.New("invalid DataSketches coupon array length")
+	}
+
+	for i := 0; i < count; i++ {
+		coupon := binary.LittleEndian.Uint32(body[i*4:])
+		if coupon == 0 {
+			continue
+		}
+		if err := dataSketchesSetRegister(registers, coupon&dsKeyMask26&uint32(len(registers)-1), coupon>>dsKeyBits26); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dataSketchesHLLRegisters reads the registers of an HLL mode image
+func dataSketchesHLLRegisters(registers []byte, data []byte, hllType DataSketchesHLLType) error {
+	body := data[dsHLLByteArrStart:]
+	arrBytes := dataSketchesArrBytes(len(registers), hllType)
+	if len(body) < arrBytes {
+		return errors.New("truncated DataSketches HLL array")
+	}
+
+	switch hllType {
+	case DataSketchesHLL8:
+		if len(body) != arrBytes {
+			return errors.New("invalid DataSketches HLL array length")
+		}
+		copy(registers, body)
+
+	case DataSketchesHLL6:
+		if len(body) != arrBytes {
+			return errors.New("invalid DataSketches HLL array length")
+		}
+		for i := range registers {
+			registers[i] = redisDenseGet(body, i)
+		}
+
+	case DataSketchesHLL4:
+		curMin := data[6]
+		auxCount := int(binary.LittleEndian.Uint32(data[36:]))
+		aux := body[arrBytes:]
+		if data[5]&dsCompactFlag == 0 {
+			auxCount = 0
+			if len(aux) > 0 {
+				auxCount = 1 << uint(data[4])
+			}
+		}
+		if len(aux) != auxCount*4 {
+			return errors.New("invalid DataSketches HLL_4 exception table length")
+		}
+
+		for i := range registers {
+			nibble := body[i/2] >> (uint(i%2) * 4) & 0x0f
+			if nibble == dsAuxToken {
+				continue
+			}
+			registers[i] = curMin + nibble
+		}
+
+		for i := 0; i < auxCount; i++ {
+			pair := binary.LittleEndian.Uint32(aux[i*4:])
+			if pair == 0 {
+				continue
+			}
+			slot := pair & dsKeyMask26
+			if int(slot) >= len(registers) || body[slot/2]>>((slot%2)*4)&0x0f != dsAuxToken {
+				return errors.New("invalid DataSketches HLL_4 exception")
+			}
+			if err := dataSketchesSetRegister(registers, slot, pair>>dsKeyBits26); err != nil {
+				return err
+			}
+		}
+
+		for i := range registers {
+			if body[i/2]>>(uint(i%2)*4)&0x0f == dsAuxToken && registers[i] == 0 {
+				return errors.New("missing DataSketches HLL_4 exception")
+			}
+		}
+
+	default:
+		return errors.New("unsupported DataSketches HLL type")
+	}
+
+	for _, value := range registers {
+		if value > 63 {
+			return errors.New("register value out of range for DataSketches")
+		}
+	}
+	return nil
+}
+
+// dataSketchesSetRegister raises a register to a decoded value
+func dataSketchesSetRegister(registers []byte, slot uint32, value uint32) error {
+	if value == 0 || value > 63 {
+		return errors.New("register value out of range for DataSketches")
+	}
+	if byte(value) > registers[slot] {
+		registers[slot] = byte(value)
+	}
+	return nil
+}
+
+// dataSketchesArrBytes returns the size of the register array of an image
+func dataSketchesArrBytes(numRegisters int, hllType DataSketchesHLLType) int {
+	switch hllType {
+	case DataSketchesHLL4:
+		return numRegisters / 2
+	case DataSketchesHLL6:
+		return numRegisters*3/4 + 1
+	}
+	return numRegisters
+}
+
+// MarshalDataSketches encodes the sketch as a compact DataSketches HLL image
+// of the given target type. Sketches without any insertions are written as
+// an empty LIST mode image and all others in the HLL mode. The image is
+// flagged out of order, so DataSketches estimates it from its registers
+// rather than from the historical inverse probability accumulator, which
+// this sketch does not track.
+func (h *HyperLogLog[T]) MarshalDataSketches(hllType DataSketchesHLLType) ([]byte, error) {
+	lgK := bits.Len(uint(h.config.NumRegisters)) - 1
+	if lgK < dsHLLMinLgK || lgK > dsHLLMaxLgK {
+		return nil, errors.New("sketch must have between 2^4 and 2^21 registers for DataSketches")
+	}
+	if hllType < DataSketchesHLL4 || hllType > DataSketchesHLL8 {
+		return nil, errors.New("unsupported DataSketches HLL type")
+	}
+
+	curMin := byte(63)
+	for _, value := range h.registers {
+		if value > 63 {
+			return nil, errors.New("register value out of range for DataSketches")
+		}
+		if value < curMin {
+			curMin = value
+		}
+	}
+
+	if h.numZeroRegisters == h.config.NumRegisters {
+		return []byte{
+			dsListPreInts, dsSerVer, dsFamilyID, byte(lgK), 3,
+			dsEmptyFlag | dsCompactFlag, 0, byte(hllType)<<2 | dsModeList,
+		}, nil
+	}
+
+	if hllType != DataSketchesHLL4 {
+		curMin = 0
+	}
+
+	var kxq0, kxq1 float64
+	var numAtCurMin uint32
+	var aux []uint32
+	for i, value := range h.registers {
+		if value < 32 {
+			kxq0 += math.Ldexp(1, -int(value))
+		} else {
+			kxq1 += math.Ldexp(1, -int(value))
+		}
+		if value == curMin {
+			numAtCurMin++
+		}
+		if hllType == DataSketchesHLL4 && value-curMin >= dsAuxToken {
+			aux = append(aux, uint32(value)<<dsKeyBits26|uint32(i))
+		}
+	}
+
+	arrBytes := dataSketchesArrBytes(h.config.NumRegisters, hllType)
+	data := make([]byte, dsHLLByteArrStart+arrBytes+len(aux)*4)
+
+	data[0] = dsHLLPreInts
+	data[1] = dsSerVer
+	data[2] = dsFamilyID
+	data[3] = byte(lgK)
+	data[5] = dsCompactFlag | dsOutOfOrderFlag
+	data[6] = curMin
+	data[7] = byte(hllType)<<2 | dsModeHLL
+	binary.LittleEndian.PutUint64(data[16:], math.Float64bits(kxq0))
+	binary.LittleEndian.PutUint64(data[24:], math.Float64bits(kxq1))
+	binary.LittleEndian.PutUint32(data[32:], numAtCurMin)
+
+	body := data[dsHLLByteArrStart:]
+	switch hllType {
+	case DataSketchesHLL8:
+		copy(body, h.registers)
+
+	case DataSketchesHLL6:
+		for i, value := range h.registers {
+			redisDenseSet(body, i, value)
+		}
+
+	case DataSketchesHLL4:
+		data[4] = byte(dataSketchesLgAuxArr(lgK, len(aux)))
+		binary.LittleEndian.PutUint32(data[36:], uint32(len(aux)))
+
+		for i, value := range h.registers {
+			nibble := value - curMin
+			if nibble > dsAuxToken {
+				nibble = dsAuxToken
+			}
+			body[i/2] |= nibble << (uint(i%2) * 4)
+		}
+
+		for i, pair := range aux {
+			binary.LittleEndian.PutUint32(body[arrBytes+i*4:], pair)
+		}
+	}
+
+	return data, nil
+}
+
+// dataSketchesLgAuxArr returns the log2 size of the HLL_4 exception table
+// DataSketches allocates for count exceptions
+func dataSketchesLgAuxArr(lgK int, count int) int {
+	lgArr := dsLgAuxArrInts[lgK]
+	for 4*count > 3*(1<<uint(lgArr)) {
+		lgArr++
+	}
+	return lgArr
+}
@@ -0,0 +1,337 @@
+package ssss
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// dataSketchesImage is an image listed in testdata/datasketches/manifest.txt,
+// written by Generate.java after updating a sketch with the longs 0 to n-1
+type dataSketchesImage struct {
+	name    string
+	hllType DataSketchesHLLType
+	lgK     int
+	mode    string
+	compact bool
+	n       int64
+}
+
+// readDataSketchesManifest reads the images written by Generate.java
+func readDataSketchesManifest(t *testing.T) []dataSketchesImage {
+	t.Helper()
+
+	types := map[string]DataSketchesHLLType{
+		"hll4": DataSketchesHLL4,
+		"hll6": DataSketchesHLL6,
+		"hll8": DataSketchesHLL8,
+	}
+
+	var images []dataSketchesImage
+	for _, line := range strings.Split(strings.TrimSpace(string(readFixture(t, "datasketches/manifest.txt"))), "\n") {
+		var image dataSketchesImage
+		if _, err := fmt.Sscan(line, &image.name, &image.n); err != nil {
+			t.Fatalf("Invalid manifest line %q: %v", line, err)
+		}
+
+		// hll4-lgk12-aux-compact.sk.gz
+		parts := strings.Split(strings.TrimSuffix(image.name, ".sk.gz"), "-")
+		hllType, ok := types[parts[0]]
+		if len(parts) != 4 || !ok {
+			t.Fatalf("Invalid image name %q", image.name)
+		}
+		if _, err := fmt.Sscanf(parts[1], "lgk%d", &image.lgK); err != nil {
+			t.Fatalf("Invalid image name %q: %v", image.name, err)
+		}
+
+		image.hllType = hllType
+		image.mode = parts[2]
+		image.compact = parts[3] == "compact"
+		images = append(images, image)
+	}
+	return images
+}
+
+// readGzipFixture reads a gzip compressed file from the testdata directory
+func readGzipFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	r, err := gzip.NewReader(bytes.NewReader(readFixture(t, name)))
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", name, err)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	return data
+}
+
+// newDataSketchesList builds a compact LIST mode image with lgK 12 and the
+// given coupons
+func newDataSketchesList(coupons ...uint32) []byte {
+	data := []byte{dsListPreInts, dsSerVer, dsFamilyID, 12, 3, dsCompactFlag, byte(len(coupons)), dsModeList}
+	for _, coupon := range coupons {
+		data = append(data, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(data[len(data)-4:], coupon)
+	}
+	return data
+}
+
+func TestDataSketchesHyperLogLog(t *testing.T) {
+	// newSketch creates a sketch with DataSketches compatible hashing
+	newSketch := func(t *testing.T, lgK int) *HyperLogLog[int64] {
+		t.Helper()
+
+		config, err := NewDataSketchesHLLConfig(lgK)
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+		return NewHyperLogLog[int64](config)
+	}
+
+	// The vectors are the published outputs of MurmurHash3_x64_128 with seed 0
+	t.Run("MurmurHash3", func(t *testing.T) {
+		vectors := map[string][2]uint64{
+			"":      {0, 0},
+			"hello": {0xcbd8a7b341bd9b02, 0x5b1e906a48ae1d19},
+			"The quick brown fox jumps over the lazy dog": {0xe34bbc7bbc071b6c, 0x7a433ca9c49a9347},
+		}
+
+		for input, expected := range vectors {
+			if h0, h1 := murmurHash3x64128([]byte(input), 0); h0 != expected[0] || h1 != expected[1] {
+				t.Errorf("Expected hash %x for %q, got %x", expected, input, [2]uint64{h0, h1})
+			}
+		}
+	})
+
+	t.Run("Register Values", func(t *testing.T) {
+		// DataSketches caps values at 63 rather than at the bits left after
+		// the register index
+		hll := newSketch(t, 21)
+		hll.insertHash(5 | 60<<21)
+
+		if hll.registers[5] != 60 {
+			t.Errorf("Expected register value 60, got %d", hll.registers[5])
+		}
+	})
+
+	// The images in testdata/datasketches are written by the datasketches-java
+	// release recorded in VERSION, see generate.sh
+	t.Run("Java Images", func(t *testing.T) {
+		if _, err := os.Stat(filepath.Join("testdata", "datasketches", "VERSION")); err != nil {
+			t.Skip("No DataSketches images, run testdata/datasketches/generate.sh")
+		}
+
+		for _, image := range readDataSketchesManifest(t) {
+			data := readGzipFixture(t, "datasketches/"+image.name)
+
+			decoded, err := HyperLogLogFromDataSketches[int64](data)
+			if err != nil {
+				t.Errorf("Failed to decode %s: %v", image.name, err)
+				continue
+			}
+
+			hll := newSketch(t, image.lgK)
+			for i := int64(0); i < image.n; i++ {
+				hll.Insert(i)
+			}
+
+			if !bytes.Equal(decoded.registers, hll.registers) {
+				t.Errorf("Registers of %s differ from %d updates", image.name, image.n)
+				continue
+			}
+
+			if image.mode == "list" || image.mode == "set" || !image.compact {
+				continue
+			}
+
+			// The register arrays of compact HLL images are reproduced. Only
+			// the order of HLL_4 exceptions may differ.
+			encoded, err := hll.MarshalDataSketches(image.hllType)
+			if err != nil {
+				t.Fatalf("Failed to encode HLL: %v", err)
+			}
+
+			arrEnd := dsHLLByteArrStart + dataSketchesArrBytes(len(hll.registers), image.hllType)
+			if encoded[6] != data[6] || !bytes.Equal(encoded[dsHLLByteArrStart:arrEnd], data[dsHLLByteArrStart:arrEnd]) {
+				t.Errorf("Register array differs from %s", image.name)
+			}
+
+			if !bytes.Equal(sortedPairs(encoded[arrEnd:]), sortedPairs(data[arrEnd:])) {
+				t.Errorf("HLL_4 exceptions differ from %s", image.name)
+			}
+		}
+	})
+
+	t.Run("HLL Round Trip", func(t *testing.T) {
+		hll := newSketch(t, 10)
+		for i := int64(0); i < 10000; i++ {
+			hll.Insert(i)
+		}
+
+		for _, hllType := range []DataSketchesHLLType{DataSketchesHLL4, DataSketchesHLL6, DataSketchesHLL8} {
+			data, err := hll.MarshalDataSketches(hllType)
+			if err != nil {
+				t.Fatalf("Failed to encode HLL: %v", err)
+			}
+
+			if len(data) < dsHLLByteArrStart+dataSketchesArrBytes(1<<10, hllType) {
+				t.Fatalf("Image of type %d is too short: %d bytes", hllType, len(data))
+			}
+
+			decoded, err := HyperLogLogFromDataSketches[int64](data)
+			if err != nil {
+				t.Fatalf("Failed to decode HLL: %v", err)
+			}
+
+			if !bytes.Equal(decoded.registers, hll.registers) {
+				t.Errorf("Registers differ after round trip of type %d", hllType)
+			}
+		}
+
+		if relativeError(hll.Cardinality(), 10000) > 0.1 {
+			t.Errorf("Expected cardinality close to 10000, got %d", hll.Cardinality())
+		}
+	})
+
+	t.Run("HLL_4 Exceptions", func(t *testing.T) {
+		hll := newSketch(t, 4)
+		copy(hll.registers, []byte{5, 5, 25, 6, 30, 5, 7, 5, 50, 12, 19, 20, 5, 8, 9, 63})
+		hll.recompute()
+
+		data, err := hll.MarshalDataSketches(DataSketchesHLL4)
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		// Registers at least 15 above the minimum of 5 live in the exception table
+		if data[6] != 5 || binary.LittleEndian.Uint32(data[36:]) != 5 {
+			t.Errorf("Expected minimum 5 and 5 exceptions, got %d and %d", data[6], binary.LittleEndian.Uint32(data[36:]))
+		}
+
+		decoded, err := HyperLogLogFromDataSketches[int64](data)
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		if !bytes.Equal(decoded.registers, hll.registers) {
+			t.Errorf("Expected registers %v, got %v", hll.registers, decoded.registers)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		data, err := newSketch(t, 12).MarshalDataSketches(DataSketchesHLL8)
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		expected := []byte{dsListPreInts, dsSerVer, dsFamilyID, 12, 3, dsEmptyFlag | dsCompactFlag, 0, 2 << 2}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("Expected empty LIST image %x, got %x", expected, data)
+		}
+
+		decoded, err := HyperLogLogFromDataSketches[int64](data)
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		if decoded.Cardinality() != 0 {
+			t.Errorf("Expected cardinality 0, got %d", decoded.Cardinality())
+		}
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		list := newDataSketchesList(1<<26|7, 2<<26|100, 3<<26|4000)
+
+		hll := newSketch(t, 4)
+		copy(hll.registers, []byte{5, 5, 25, 6, 30, 5, 7, 5, 50, 12, 19, 20, 5, 8, 9, 63})
+		hll.recompute()
+
+		hll8, err := hll.MarshalDataSketches(DataSketchesHLL8)
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		hll4, err := hll.MarshalDataSketches(DataSketchesHLL4)
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		// withByte returns a copy of data with one byte replaced
+		withByte := func(data []byte, i int, b byte) []byte {
+			data = append([]byte(nil), data...)
+			data[i] = b
+			return data
+		}
+
+		inputs := map[string][]byte{
+			"short":           list[:4],
+			"bad family":      withByte(list, 2, 3),
+			"bad version":     withByte(list, 1, 2),
+			"big endian":      withByte(list, 5, 9),
+			"bad lgK":         withByte(list, 3, 30),
+			"bad preamble":    withByte(list, 0, 3),
+			"short list":      list[:len(list)-1],
+			"zero value":      withByte(list, 11, 0),
+			"short array":     hll8[:len(hll8)-1],
+			"long array":      append(append([]byte(nil), hll8...), 0),
+			"bad value":       withByte(hll8, 40, 64),
+			"missing aux":     withByte(hll4, 36, 0),
+			"aux not flagged": withByte(hll4, 41, 0x10),
+		}
+
+		for name, input := range inputs {
+			if _, err := HyperLogLogFromDataSketches[int64](input); err == nil {
+				t.Errorf("Expected error decoding %s input", name)
+			}
+		}
+
+		if err := newSketch(t, 10).UnmarshalDataSketches(list); err == nil {
+			t.Error("Expected error decoding an image with a different lgK")
+		}
+
+		// Configurations with fewer than 16 registers can only be built by hand
+		if _, err := NewHLLConfig(8, []uint64{8, 9, 10, 11, 12, 13, 14, 15}); err == nil {
+			t.Error("Expected error creating a config with fewer than 16 registers")
+		}
+
+		config := &HLLConfig{NumRegisters: 8, Alpha: 0.673, Seeds: []uint64{8, 9}}
+		if _, err := NewHyperLogLog[int64](config).MarshalDataSketches(DataSketchesHLL8); err == nil {
+			t.Error("Expected error encoding a sketch with fewer than 16 registers")
+		}
+
+		// A hasher with another lgK would index past the registers
+		config = &HLLConfig{NumRegisters: 1 << 10, Alpha: hllAlpha(1 << 10), Hasher: DataSketchesHasher{LgK: 12}}
+		if err := config.Validate(); err == nil {
+			t.Error("Expected error validating a DataSketchesHasher with LgK 12 for 1024 registers")
+		}
+	})
+}
+
+// sortedPairs returns the 4-byte exception pairs of an HLL_4 image in
+// increasing order
+func sortedPairs(aux []byte) []byte {
+	pairs := make([]uint32, 0, len(aux)/4)
+	for i := 0; i+4 <= len(aux); i += 4 {
+		pairs = append(pairs, binary.LittleEndian.Uint32(aux[i:]))
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i] < pairs[j]
+	})
+
+	sorted := make([]byte, len(pairs)*4)
+	for i, pair := range pairs {
+		binary.LittleEndian.PutUint32(sorted[i*4:], pair)
+	}
+	return sorted
+}
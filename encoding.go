@@ -0,0 +1,776 @@
+package ssss
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// encodingVersion is the version of the binary, JSON and protobuf encodings.
+// Version 2 changed register values from leading zeros to trailing zeros,
+// see insertHash, so registers of version 1 cannot be decoded. Version 3
+// added configuration flags, the totals of Config.TrackTotals and the event
+// counts of Config.TrackFrequencies to the binary encoding; version 2 data
+// can still be decoded. Version 4 added the Hasher of HLL configurations to
+// all encodings; earlier data is decoded with the default hasher. Version 5
+// added the configuration fingerprint to the binary encoding, which the JSON
+// and protobuf encodings already carried.
+const encodingVersion = 5
+
+// minEncodingVersion is the oldest version whose registers can be decoded
+const minEncodingVersion = 2
+
+// checkVersion checks that data of the given encoding version can be decoded.
+// JSON and protobuf data written before version 2 has version 0.
+func checkVersion(version uint64) error {
+	if version < minEncodingVersion {
+		return fmt.Errorf("unsupported version %d: register values changed in version %d",
+			version, minEncodingVersion)
+	}
+	if version > encodingVersion {
+		return fmt.Errorf("unsupported version %d", version)
+	}
+	return nil
+}
+
+// Configuration flags of the binary encoding
+const (
+	configFlagTrackTotals = 1 << iota
+	configFlagTrackFrequencies
+	configFlagSubsetSample
+	configFlagKeyedHashing
+)
+
+// Hashers of HLL configurations in the encodings. Other hashers cannot be
+// encoded, since decoders would not know how to restore them.
+const (
+	hasherDefault = iota
+	hasherRedis
+	hasherDataSketches
+	hasherPostgres
+	hasherSipHash
+)
+
+// hasherNames are the names of the hashers in the JSON encoding
+var hasherNames = [...]string{
+	hasherDefault:      "",
+	hasherRedis:        "redis",
+	hasherDataSketches: "datasketches",
+	hasherPostgres:     "postgres",
+	hasherSipHash:      "siphash",
+}
+
+// hasherKind returns the kind of the Hasher of a configuration in the encodings
+func hasherKind(c *HLLConfig) (uint64, error) {
+	switch h := c.Hasher.(type) {
+	case nil:
+		return hasherDefault, nil
+	case RedisHasher:
+		return hasherRedis, nil
+	case DataSketchesHasher:
+		if 1<<uint(h.LgK) != c.NumRegisters {
+			return 0, fmt.Errorf("cannot encode a DataSketchesHasher with LgK %d for %d registers", h.LgK, c.NumRegisters)
+		}
+		return hasherDataSketches, nil
+	case PostgresHasher:
+		return hasherPostgres, nil
+	case SipHasher:
+		// The key is secret and never encoded
+		return hasherSipHash, nil
+	}
+	return 0, fmt.Errorf("cannot encode hasher of type %T", c.Hasher)
+}
+
+// decodedHasher restores the Hasher of a decoded configuration
+func decodedHasher(kind uint64, c *HLLConfig) (Hasher, error) {
+	switch kind {
+	case hasherDefault:
+		return nil, nil
+	case hasherRedis:
+		return RedisHasher{}, nil
+	case hasherDataSketches:
+		return DataSketchesHasher{LgK: bits.TrailingZeros(uint(c.NumRegisters))}, nil
+	case hasherPostgres:
+		return PostgresHasher{}, nil
+	case hasherSipHash:
+		// The key was not encoded and must be set again, see SetHashKey
+		return SipHasher{}, nil
+	}
+	return nil, fmt.Errorf("unknown hasher %d", kind)
+}
+
+// MarshalBinary encodes the HyperLogLog configuration
+func (c *HLLConfig) MarshalBinary() ([]byte, error) {
+	var e encoder
+	e.byte(encodingVersion)
+	e.hllConfig(c)
+	return e.finish()
+}
+
+// UnmarshalBinary decodes a HyperLogLog configuration produced by MarshalBinary
+func (c *HLLConfig) UnmarshalBinary(data []byte) error {
+	d := decoder{buf: data}
+	d.version()
+	config := d.hllConfig()
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*c = *config
+	return nil
+}
+
+// MarshalBinary encodes the sketch together with its configuration
+func (h *HyperLogLog[T]) MarshalBinary() ([]byte, error) {
+	var e encoder
+	e.byte(encodingVersion)
+	e.hllConfig(h.config)
+	e.raw(h.registers)
+	return e.finish()
+}
+
+// UnmarshalBinary decodes a sketch produced by MarshalBinary
+func (h *HyperLogLog[T]) UnmarshalBinary(data []byte) error {
+	d := decoder{buf: data}
+	d.version()
+	config := d.hllConfig()
+	registers := d.registers(config)
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*h = *NewHyperLogLog[T](config)
+	copy(h.registers, registers)
+	h.recompute()
+	return nil
+}
+
+// MarshalBinary encodes the configuration
+func (c *Config) MarshalBinary() ([]byte, error) {
+	var e encoder
+	e.byte(encodingVersion)
+	e.config(c)
+	return e.finish()
+}
+
+// UnmarshalBinary decodes a configuration produced by MarshalBinary
+func (c *Config) UnmarshalBinary(data []byte) error {
+	d := decoder{buf: data}
+	d.version()
+	config := d.config()
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	*c = *config
+	return nil
+}
+
+// MarshalBinary encodes the sketch together with its configuration.
+// Labels are stored in their text form, see marshalLabel.
+func (s *SamplingSpaceSavingSets[L, T]) MarshalBinary() ([]byte, error) {
+	var e encoder
+	e.byte(encodingVersion)
+	e.config(s.config)
+	e.uvarint(s.threshold)
+	e.uvarint(uint64(len(s.counters)))
+
+	// Encode the counters in label order so equal sketches encode identically
+	type entry struct {
+		text      []byte
+		registers []byte
+		counter   *CachedSketch[T]
+	}
+
+	entries := make([]entry, 0, len(s.counters))
+	for label, counter := range s.counters {
+		text, err := marshalLabel(label)
+		if err != nil {
+			return nil, err
+		}
+
+		hll, ok := counter.sketch.(*HyperLogLog[T])
+		if !ok {
+			return nil, errors.New("can only encode counters backed by HyperLogLog")
+		}
+
+		entries = append(entries, entry{text: text, registers: hll.registers, counter: counter})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].text, entries[j].text) < 0
+	})
+
+	for _, entry := range entries {
+		e.bytes(entry.text)
+		e.raw(entry.registers)
+		if s.config.TrackFrequencies {
+			e.uvarint(entry.counter.frequency)
+			e.uvarint(entry.counter.frequencyError)
+		}
+	}
+
+	if s.items != nil {
+		e.raw(s.items.registers)
+		e.raw(s.labels.registers)
+	}
+
+	if s.sample != nil {
+		hashes, texts, err := s.sample.entries()
+		if err != nil {
+			return nil, err
+		}
+
+		e.uint64(s.sample.threshold)
+		e.uvarint(uint64(len(hashes)))
+		for i, hash := range hashes {
+			e.uint64(hash)
+			e.bytes(texts[i])
+		}
+	}
+
+	return e.finish()
+}
+
+// UnmarshalBinary decodes a sketch produced by MarshalBinary, replacing the
+// configuration and contents of s. The threshold is recomputed from the
+// counters. On error s is left unchanged.
+func (s *SamplingSpaceSavingSets[L, T]) UnmarshalBinary(data []byte) error {
+	d := decoder{buf: data}
+	d.version()
+	config := d.config()
+	// The threshold is recomputed from the counters, which a crafted one
+	// could contradict
+	_ = d.uvarint()
+	numCounters := d.uvarint()
+	if d.err != nil {
+		return d.err
+	}
+
+	if numCounters > uint64(config.MaxNumCounters) {
+		d.fail("more counters than MaxNumCounters")
+	}
+
+	// The number of counters is only trusted once they are decoded
+	counters := make(map[L]*CachedSketch[T])
+	for i := uint64(0); i < numCounters && d.err == nil; i++ {
+		text := d.bytes()
+		registers := d.registers(config.CardinalitySketchConfig)
+		var frequency, frequencyError uint64
+		if config.TrackFrequencies {
+			frequency = d.uvarint()
+			frequencyError = d.uvarint()
+		}
+		if d.err != nil {
+			break
+		}
+
+		label, err := unmarshalLabel[L](text)
+		if err != nil {
+			return err
+		}
+
+		if _, exists := counters[label]; exists {
+			return fmt.Errorf("duplicate label %q", text)
+		}
+
+		hll := NewHyperLogLog[T](config.CardinalitySketchConfig)
+		copy(hll.registers, registers)
+		hll.recompute()
+
+		counter := NewCachedSketch[T](hll)
+		counter.cardinality = hll.Cardinality()
+		counter.frequency = frequency
+		counter.frequencyError = frequencyError
+		counters[label] = counter
+	}
+
+	var items, labels []byte
+	if d.err == nil && config.TrackTotals {
+		items = d.registers(config.CardinalitySketchConfig)
+		labels = d.registers(config.CardinalitySketchConfig)
+	}
+
+	var sampleThreshold uint64
+	var hashes []uint64
+	var texts [][]byte
+	if d.err == nil && config.SubsetSampleSize > 0 {
+		sampleThreshold = d.uint64()
+		numPairs := d.uvarint()
+		if d.err == nil && numPairs > uint64(config.SubsetSampleSize) {
+			d.fail("more sampled pairs than SubsetSampleSize")
+		}
+		for i := uint64(0); i < numPairs && d.err == nil; i++ {
+			hashes = append(hashes, d.uint64())
+			texts = append(texts, d.bytes())
+		}
+	}
+
+	if err := d.finish(); err != nil {
+		return err
+	}
+
+	decoded := newSamplingSpaceSavingSets[L, T](config, 0)
+	decoded.counters = counters
+	decoded.updateThreshold()
+
+	if decoded.items != nil {
+		if err := decoded.setTotals(items, labels); err != nil {
+			return err
+		}
+	}
+
+	if decoded.sample != nil {
+		if err := decoded.sample.set(sampleThreshold, hashes, texts); err != nil {
+			return err
+		}
+	}
+
+	*s = *decoded
+	return nil
+}
+
+// setTotals replaces the registers of the totals of a sketch created with
+// Config.TrackTotals
+func (s *SamplingSpaceSavingSets[L, T]) setTotals(items, labels []byte) error {
+	for _, registers := range [][]byte{items, labels} {
+		if err := s.config.CardinalitySketchConfig.checkRegisters(registers); err != nil {
+			return fmt.Errorf("invalid totals: %w", err)
+		}
+	}
+
+	copy(s.items.registers, items)
+	s.items.recompute()
+	copy(s.labels.registers, labels)
+	s.labels.recompute()
+	return nil
+}
+
+// recompute rebuilds the derived register statistics after the registers
+// have been overwritten
+func (h *HyperLogLog[T]) recompute() {
+	h.numZeroRegisters = 0
+	h.zInv = 0
+
+	for _, register := range h.registers {
+		if register == 0 {
+			h.numZeroRegisters++
+		}
+
+		h.zInv += math.Pow(2.0, -float64(register))
+	}
+}
+
+// decodedHLLConfig checks and assembles a HyperLogLog configuration read from
+// any of the encodings, with the Hasher of the given kind
+func decodedHLLConfig(numRegisters int, alpha float64, seeds []uint64, kind uint64) (*HLLConfig, error) {
+	config := &HLLConfig{
+		NumRegisters: numRegisters,
+		Alpha:        alpha,
+		Seeds:        seeds,
+	}
+
+	if err := config.validateRegisters(); err != nil {
+		return nil, invalidConfig(err)
+	}
+
+	hasher, err := decodedHasher(kind, config)
+	if err != nil {
+		return nil, invalidConfig(err)
+	}
+	config.Hasher = hasher
+
+	if err := validateDecoded(config.validate()); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// validateDecoded turns the result of validating a decoded configuration into
+// an error matching ErrInvalidConfig, except for a missing hash key, which
+// is never encoded
+func validateDecoded(err error) error {
+	if errors.Is(err, errMissingHashKey) {
+		return nil
+	}
+	return invalidConfig(err)
+}
+
+// marshalLabel converts a label to its text form. Labels implementing
+// encoding.TextMarshaler use it, otherwise strings, booleans and numeric
+// kinds are formatted with strconv.
+func marshalLabel(label any) ([]byte, error) {
+	if m, ok := label.(encoding.TextMarshaler); ok {
+		return m.MarshalText()
+	}
+
+	v := reflect.ValueOf(label)
+	switch v.Kind() {
+	case reflect.String:
+		return []byte(v.String()), nil
+	case reflect.Bool:
+		return strconv.AppendBool(nil, v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(nil, v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(nil, v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.AppendFloat(nil, v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+
+	return nil, fmt.Errorf("cannot encode label of type %T", label)
+}
+
+// unmarshalLabel parses a label from the text form produced by marshalLabel
+func unmarshalLabel[L comparable](text []byte) (L, error) {
+	var label L
+	if u, ok := any(&label).(encoding.TextUnmarshaler); ok {
+		err := u.UnmarshalText(text)
+		return label, err
+	}
+
+	v := reflect.ValueOf(&label).Elem()
+	s := string(text)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return label, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return label, err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return label, err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return label, err
+		}
+		v.SetFloat(f)
+	default:
+		return label, fmt.Errorf("cannot decode label of type %T", label)
+	}
+
+	return label, nil
+}
+
+// encoder appends values to a byte slice. The first error is kept and
+// returned by finish.
+type encoder struct {
+	buf []byte
+	err error
+}
+
+func (e *encoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *encoder) finish() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.buf, nil
+}
+
+func (e *encoder) byte(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+func (e *encoder) raw(b []byte) {
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	e.raw(b[:n])
+}
+
+func (e *encoder) uint64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	e.raw(b[:])
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.raw(b)
+}
+
+func (e *encoder) seeds(seeds []uint64) {
+	e.uvarint(uint64(len(seeds)))
+	for _, seed := range seeds {
+		e.uint64(seed)
+	}
+}
+
+func (e *encoder) hllConfig(c *HLLConfig) {
+	kind, err := hasherKind(c)
+	if err != nil {
+		e.fail(err)
+	}
+
+	e.hllSettings(c)
+	e.uvarint(kind)
+}
+
+// hllSettings writes the fields of a HyperLogLog configuration before version 4
+func (e *encoder) hllSettings(c *HLLConfig) {
+	e.uvarint(uint64(c.NumRegisters))
+	e.uint64(math.Float64bits(c.Alpha))
+	e.seeds(c.Seeds)
+}
+
+func (e *encoder) config(c *Config) {
+	e.uvarint(uint64(c.MaxNumCounters))
+	e.seeds(c.Seeds)
+	e.hllConfig(c.CardinalitySketchConfig)
+	e.uvarint(configFlags(c))
+	if c.SubsetSampleSize > 0 {
+		e.uvarint(uint64(c.SubsetSampleSize))
+	}
+	e.uint64(c.Fingerprint())
+}
+
+// configFlags returns the configuration flags of the binary encoding
+func configFlags(c *Config) uint64 {
+	var flags uint64
+	if c.TrackTotals {
+		flags |= configFlagTrackTotals
+	}
+	if c.TrackFrequencies {
+		flags |= configFlagTrackFrequencies
+	}
+	if c.SubsetSampleSize > 0 {
+		flags |= configFlagSubsetSample
+	}
+	if c.KeyedHashing {
+		flags |= configFlagKeyedHashing
+	}
+	return flags
+}
+
+// decoder reads values from a byte slice. The first error is kept and all
+// subsequent reads return zero values, so callers check err once at the end.
+type decoder struct {
+	buf []byte
+	err error
+	// ver is the format version read by version
+	ver byte
+}
+
+func (d *decoder) fail(msg string) {
+	if d.err == nil {
+		d.err = errors.New("invalid encoding: " + msg)
+	}
+}
+
+// failWith records an error found in the decoded values, which stays
+// available to errors.Is and errors.As
+func (d *decoder) failWith(err error) {
+	if d.err == nil {
+		d.err = fmt.Errorf("invalid encoding: %w", err)
+	}
+}
+
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.buf) != 0 {
+		d.fail("trailing data")
+	}
+	return d.err
+}
+
+func (d *decoder) version() {
+	d.ver = d.byte()
+	if d.err == nil {
+		if err := checkVersion(uint64(d.ver)); err != nil {
+			d.fail(err.Error())
+		}
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) < 1 {
+		d.fail("unexpected end of data")
+		return 0
+	}
+
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) raw(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.buf)) < n {
+		d.fail("unexpected end of data")
+		return nil
+	}
+
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail("malformed varint")
+		return 0
+	}
+
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	b := d.raw(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (d *decoder) bytes() []byte {
+	return d.raw(d.uvarint())
+}
+
+// int reads a uvarint that must fit in a positive int
+func (d *decoder) int() int {
+	v := d.uvarint()
+	if v > math.MaxInt32 {
+		d.fail("value out of range")
+		return 0
+	}
+	return int(v)
+}
+
+func (d *decoder) seeds() []uint64 {
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.buf))/8 {
+		d.fail("unexpected end of data")
+		return nil
+	}
+
+	seeds := make([]uint64, n)
+	for i := range seeds {
+		seeds[i] = d.uint64()
+	}
+	return seeds
+}
+
+func (d *decoder) hllConfig() *HLLConfig {
+	numRegisters := d.int()
+	alpha := math.Float64frombits(d.uint64())
+	seeds := d.seeds()
+	var kind uint64
+	if d.ver >= 4 {
+		kind = d.uvarint()
+	}
+	if d.err != nil {
+		return nil
+	}
+
+	config, err := decodedHLLConfig(numRegisters, alpha, seeds, kind)
+	if err != nil {
+		d.failWith(err)
+	}
+	return config
+}
+
+func (d *decoder) config() *Config {
+	maxNumCounters := d.int()
+	seeds := d.seeds()
+	hllConfig := d.hllConfig()
+
+	var flags uint64
+	if d.ver >= 3 {
+		flags = d.uvarint()
+	}
+
+	var subsetSampleSize int
+	if flags&configFlagSubsetSample != 0 {
+		subsetSampleSize = d.int()
+		if d.err == nil && subsetSampleSize == 0 {
+			d.fail("empty subset sample size")
+		}
+	}
+
+	var fingerprint uint64
+	if d.ver >= 5 {
+		fingerprint = d.uint64()
+	}
+	if d.err != nil {
+		return nil
+	}
+
+	if flags&^(configFlagTrackTotals|configFlagTrackFrequencies|configFlagSubsetSample|configFlagKeyedHashing) != 0 {
+		d.fail("unknown config flags")
+		return nil
+	}
+
+	config := &Config{
+		MaxNumCounters:          maxNumCounters,
+		Seeds:                   seeds,
+		CardinalitySketchConfig: hllConfig,
+		TrackTotals:             flags&configFlagTrackTotals != 0,
+		TrackFrequencies:        flags&configFlagTrackFrequencies != 0,
+		SubsetSampleSize:        subsetSampleSize,
+		KeyedHashing:            flags&configFlagKeyedHashing != 0,
+	}
+	if err := validateDecoded(config.validate()); err != nil {
+		d.failWith(err)
+		return nil
+	}
+	if err := config.checkFingerprint(fingerprint); err != nil {
+		d.failWith(err)
+		return nil
+	}
+	return config
+}
+
+// registers reads the registers of a HyperLogLog sketch with the given configuration
+func (d *decoder) registers(c *HLLConfig) []byte {
+	if d.err != nil {
+		return nil
+	}
+
+	registers := d.raw(uint64(c.NumRegisters))
+	if d.err == nil {
+		if err := c.checkRegisters(registers); err != nil {
+			d.fail(err.Error())
+			return nil
+		}
+	}
+	return registers
+}
@@ -0,0 +1,723 @@
+package ssss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/sawmills/go-ssss/ssssproto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBinaryEncoding(t *testing.T) {
+	t.Run("HyperLogLog Round Trip", func(t *testing.T) {
+		config, err := NewHLLConfig(512, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		hll := NewHyperLogLog[uint64](config)
+		for i := uint64(0); i < 1000; i++ {
+			hll.Insert(i)
+		}
+
+		data, err := hll.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal HLL: %v", err)
+		}
+
+		var decoded HyperLogLog[uint64]
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Failed to unmarshal HLL: %v", err)
+		}
+
+		if !bytes.Equal(decoded.registers, hll.registers) {
+			t.Error("Registers differ after round trip")
+		}
+
+		if decoded.Cardinality() != hll.Cardinality() {
+			t.Errorf("Expected cardinality %d after round trip, got %d",
+				hll.Cardinality(), decoded.Cardinality())
+		}
+
+		if decoded.config.Alpha != config.Alpha || decoded.config.Seeds[1] != config.Seeds[1] {
+			t.Error("Config differs after round trip")
+		}
+	})
+
+	t.Run("Sketch Round Trip", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(512, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(5, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[rune, uint64](config)
+		for label := 'a'; label <= 'h'; label++ {
+			for i := uint64(0); i < uint64(label-'a'+1)*50; i++ {
+				sketch.Insert(label, i)
+			}
+		}
+
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		decoded := new(SamplingSpaceSavingSets[rune, uint64])
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Failed to unmarshal sketch: %v", err)
+		}
+
+		// The threshold is recomputed from the decoded counters
+		if _, minCardinality := sketch.minCounter(); decoded.threshold != minCardinality {
+			t.Errorf("Expected threshold %d, got %d", minCardinality, decoded.threshold)
+		}
+
+		expected := sketch.Top(5)
+		actual := decoded.Top(5)
+		if len(actual) != len(expected) {
+			t.Fatalf("Expected %d labels, got %d", len(expected), len(actual))
+		}
+
+		for i := range expected {
+			if actual[i] != expected[i] {
+				t.Errorf("Expected %v at position %d, got %v", expected[i], i, actual[i])
+			}
+		}
+
+		// Encoding must be deterministic. The decoded threshold differs
+		// from the original, so compare two encodings of the decoded sketch.
+		data, err = decoded.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal decoded sketch: %v", err)
+		}
+		again, err := decoded.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal decoded sketch: %v", err)
+		}
+		if !bytes.Equal(again, data) {
+			t.Error("Re-encoding a decoded sketch produced different bytes")
+		}
+
+		// Decoded sketches remain mergeable with the original
+		if err := decoded.Merge(sketch); err != nil {
+			t.Errorf("Failed to merge decoded sketch: %v", err)
+		}
+	})
+
+	t.Run("Labels Decode As Text", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(64, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(5, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[int, uint64](config)
+		sketch.Insert(-42, 1)
+
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		decoded := new(SamplingSpaceSavingSets[string, uint64])
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Failed to unmarshal sketch: %v", err)
+		}
+
+		if _, exists := decoded.counters["-42"]; !exists {
+			t.Error("Expected integer label to decode as its text form")
+		}
+	})
+
+	t.Run("Capacity Is Not Preallocated", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(64, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(5, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		sketch.Insert("a", 1)
+
+		// A posted sketch may claim any capacity. Sizing the counters by it
+		// would allocate gigabytes.
+		sketch.config.MaxNumCounters = 1 << 28
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		decoded := new(SamplingSpaceSavingSets[string, uint64])
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Failed to unmarshal sketch: %v", err)
+		}
+
+		decoded.Clear()
+		decoded.Insert("b", 2)
+		if decoded.Cardinality("b") != 1 {
+			t.Errorf("Expected cardinality 1, got %d", decoded.Cardinality("b"))
+		}
+	})
+
+	t.Run("Totals Round Trip", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(256, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(3, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		config.TrackTotals = true
+
+		sketch := NewHLLSamplingSpaceSavingSets[int, uint64](config)
+		for i := uint64(0); i < 1000; i++ {
+			sketch.Insert(int(i%20), i)
+		}
+
+		binary, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		fromBinary := new(SamplingSpaceSavingSets[int, uint64])
+		if err := fromBinary.UnmarshalBinary(binary); err != nil {
+			t.Fatalf("Failed to unmarshal sketch: %v", err)
+		}
+
+		jsonData, err := sketch.MarshalJSON()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch as JSON: %v", err)
+		}
+
+		fromJSON := new(SamplingSpaceSavingSets[int, uint64])
+		if err := fromJSON.UnmarshalJSON(jsonData); err != nil {
+			t.Fatalf("Failed to unmarshal JSON sketch: %v", err)
+		}
+
+		p, err := sketch.ToProto()
+		if err != nil {
+			t.Fatalf("Failed to convert sketch to protobuf: %v", err)
+		}
+
+		fromProto, err := SamplingSpaceSavingSetsFromProto[int, uint64](p)
+		if err != nil {
+			t.Fatalf("Failed to convert sketch from protobuf: %v", err)
+		}
+
+		for name, decoded := range map[string]*SamplingSpaceSavingSets[int, uint64]{
+			"binary": fromBinary, "JSON": fromJSON, "protobuf": fromProto,
+		} {
+			if !decoded.config.TrackTotals ||
+				decoded.TotalDistinctItems() != sketch.TotalDistinctItems() ||
+				decoded.DistinctLabels() != sketch.DistinctLabels() {
+				t.Errorf("Totals differ after %s round trip", name)
+			}
+		}
+
+		// Truncating the totals is detected
+		if err := fromBinary.UnmarshalBinary(binary[:len(binary)-1]); err == nil {
+			t.Error("Expected error decoding truncated totals")
+		}
+	})
+
+	t.Run("Frequencies Round Trip", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(256, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(3, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		config.TrackFrequencies = true
+
+		sketch := NewHLLSamplingSpaceSavingSets[int, uint64](config)
+		for i := uint64(0); i < 1000; i++ {
+			sketch.Insert(int(i%20), i)
+		}
+
+		binary, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		fromBinary := new(SamplingSpaceSavingSets[int, uint64])
+		if err := fromBinary.UnmarshalBinary(binary); err != nil {
+			t.Fatalf("Failed to unmarshal sketch: %v", err)
+		}
+
+		jsonData, err := sketch.MarshalJSON()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch as JSON: %v", err)
+		}
+
+		fromJSON := new(SamplingSpaceSavingSets[int, uint64])
+		if err := fromJSON.UnmarshalJSON(jsonData); err != nil {
+			t.Fatalf("Failed to unmarshal JSON sketch: %v", err)
+		}
+
+		p, err := sketch.ToProto()
+		if err != nil {
+			t.Fatalf("Failed to convert sketch to protobuf: %v", err)
+		}
+
+		fromProto, err := SamplingSpaceSavingSetsFromProto[int, uint64](p)
+		if err != nil {
+			t.Fatalf("Failed to convert sketch from protobuf: %v", err)
+		}
+
+		for name, decoded := range map[string]*SamplingSpaceSavingSets[int, uint64]{
+			"binary": fromBinary, "JSON": fromJSON, "protobuf": fromProto,
+		} {
+			if !decoded.config.TrackFrequencies {
+				t.Errorf("TrackFrequencies lost after %s round trip", name)
+			}
+
+			for label := range sketch.counters {
+				frequency, frequencyError := sketch.Frequency(label)
+				decodedFrequency, decodedError := decoded.Frequency(label)
+				if decodedFrequency != frequency || decodedError != frequencyError {
+					t.Errorf("Frequency of label %d differs after %s round trip", label, name)
+				}
+			}
+		}
+	})
+
+	t.Run("Subset Sample Round Trip", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(256, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(3, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		config.SubsetSampleSize = 50
+
+		sketch := NewHLLSamplingSpaceSavingSets[int, uint64](config)
+		for i := uint64(0); i < 1000; i++ {
+			sketch.Insert(int(i%20), i)
+		}
+
+		binary, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		fromBinary := new(SamplingSpaceSavingSets[int, uint64])
+		if err := fromBinary.UnmarshalBinary(binary); err != nil {
+			t.Fatalf("Failed to unmarshal sketch: %v", err)
+		}
+
+		jsonData, err := sketch.MarshalJSON()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch as JSON: %v", err)
+		}
+
+		fromJSON := new(SamplingSpaceSavingSets[int, uint64])
+		if err := fromJSON.UnmarshalJSON(jsonData); err != nil {
+			t.Fatalf("Failed to unmarshal JSON sketch: %v", err)
+		}
+
+		p, err := sketch.ToProto()
+		if err != nil {
+			t.Fatalf("Failed to convert sketch to protobuf: %v", err)
+		}
+
+		protoData, err := proto.Marshal(p)
+		if err != nil {
+			t.Fatalf("Failed to marshal protobuf sketch: %v", err)
+		}
+
+		if err := proto.Unmarshal(protoData, p); err != nil {
+			t.Fatalf("Failed to unmarshal protobuf sketch: %v", err)
+		}
+
+		fromProto, err := SamplingSpaceSavingSetsFromProto[int, uint64](p)
+		if err != nil {
+			t.Fatalf("Failed to convert sketch from protobuf: %v", err)
+		}
+
+		even := func(label int) bool { return label%2 == 0 }
+		want, err := sketch.EstimateSubset(even)
+		if err != nil {
+			t.Fatalf("Failed to estimate subset: %v", err)
+		}
+
+		for name, decoded := range map[string]*SamplingSpaceSavingSets[int, uint64]{
+			"binary": fromBinary, "JSON": fromJSON, "protobuf": fromProto,
+		} {
+			if decoded.config.SubsetSampleSize != 50 {
+				t.Errorf("SubsetSampleSize lost after %s round trip", name)
+			}
+
+			got, err := decoded.EstimateSubset(even)
+			if err != nil {
+				t.Fatalf("Failed to estimate subset after %s round trip: %v", name, err)
+			}
+
+			if got != want {
+				t.Errorf("Subset estimate after %s round trip is %+v, expected %+v", name, got, want)
+			}
+		}
+	})
+
+	t.Run("Failed Decoding Leaves Sketch Unchanged", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(16, []uint64{8, 9})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(2, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		config.SubsetSampleSize = 10
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		sketch.Insert("a", 1)
+
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		// The sample ends with its threshold, the number of pairs and a pair
+		// of a hash and the label "a". A zero threshold puts the pair above it.
+		corrupt := append([]byte(nil), data...)
+		copy(corrupt[len(corrupt)-19:], make([]byte, 8))
+
+		decoded := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		decoded.Insert("b", 1)
+		if err := decoded.UnmarshalBinary(corrupt); err == nil {
+			t.Fatal("Expected error for a sampled pair above the threshold")
+		}
+
+		if top := decoded.Top(2); len(top) != 1 || top[0].Label != "b" {
+			t.Errorf("Expected the sketch to be unchanged after a failed decode, got %v", top)
+		}
+	})
+
+	t.Run("Hashers Round Trip", func(t *testing.T) {
+		dataSketches, err := NewDataSketchesHLLConfig(12)
+		if err != nil {
+			t.Fatalf("Failed to create DataSketches config: %v", err)
+		}
+
+		postgres, err := NewPostgresHLLConfig(12)
+		if err != nil {
+			t.Fatalf("Failed to create PostgreSQL config: %v", err)
+		}
+
+		hllConfigs := map[string]*HLLConfig{
+			"Redis":        NewRedisHLLConfig(),
+			"DataSketches": dataSketches,
+			"PostgreSQL":   postgres,
+		}
+
+		for name, hllConfig := range hllConfigs {
+			config, err := NewConfig(3, hllConfig, []uint64{0, 1, 2, 3})
+			if err != nil {
+				t.Fatalf("Failed to create %s config: %v", name, err)
+			}
+
+			sketch := NewHLLSamplingSpaceSavingSets[string, string](config)
+			sketch.Insert("a", "x")
+
+			for encoding, decoded := range roundTrips(t, sketch) {
+				if decoded.config.CardinalitySketchConfig.Hasher != hllConfig.Hasher {
+					t.Errorf("Expected %s hasher after %s round trip, got %T",
+						name, encoding, decoded.config.CardinalitySketchConfig.Hasher)
+				}
+
+				decoded.Insert("a", "y")
+				decoded.Insert("b", "z")
+				if err := sketch.Merge(decoded); err != nil {
+					t.Errorf("Failed to merge %s sketch after %s round trip: %v", name, encoding, err)
+				}
+			}
+		}
+
+		config, err := NewConfig(3, &HLLConfig{NumRegisters: 16, Alpha: 0.673, Hasher: PostgresHasher{}}, nil)
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+		config.CardinalitySketchConfig.Hasher = customHasher{}
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, string](config)
+		if _, err := sketch.MarshalBinary(); err == nil {
+			t.Error("Expected error encoding a custom hasher")
+		}
+
+		if _, err := sketch.MarshalJSON(); err == nil {
+			t.Error("Expected error encoding a custom hasher as JSON")
+		}
+
+		if _, err := sketch.ToProto(); err == nil {
+			t.Error("Expected error encoding a custom hasher as protobuf")
+		}
+	})
+
+	t.Run("Version 2", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(16, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(3, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		// Version 2 configurations have neither a hasher nor trailing flags
+		var e encoder
+		e.byte(2)
+		e.uvarint(uint64(config.MaxNumCounters))
+		e.seeds(config.Seeds)
+		e.hllSettings(config.CardinalitySketchConfig)
+
+		var decoded Config
+		if err := decoded.UnmarshalBinary(e.buf); err != nil {
+			t.Fatalf("Failed to unmarshal version 2 config: %v", err)
+		}
+
+		if decoded.MaxNumCounters != 3 || decoded.TrackTotals {
+			t.Errorf("Unexpected version 2 config: %+v", decoded)
+		}
+	})
+
+	t.Run("Fingerprint", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(16, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(3, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		data, err := config.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+
+		// The configuration ends with its flags and its fingerprint
+		fingerprint := binary.LittleEndian.Uint64(data[len(data)-8:])
+		if fingerprint != config.Fingerprint() {
+			t.Errorf("Expected fingerprint %x, got %x", config.Fingerprint(), fingerprint)
+		}
+
+		// Flags that do not match the fingerprint are rejected
+		tampered := append([]byte{}, data...)
+		tampered[len(tampered)-9] = configFlagTrackFrequencies
+
+		var decoded Config
+		if err := decoded.UnmarshalBinary(tampered); err == nil {
+			t.Error("Expected error decoding a config that does not match its fingerprint")
+		}
+
+		// Version 4 configurations have no fingerprint
+		old := append([]byte{4}, data[1:len(data)-8]...)
+		if err := decoded.UnmarshalBinary(old); err != nil {
+			t.Fatalf("Failed to unmarshal version 4 config: %v", err)
+		}
+
+		if decoded.Fingerprint() != config.Fingerprint() {
+			t.Errorf("Unexpected version 4 config: %+v", decoded)
+		}
+	})
+
+	t.Run("Registers Out Of Range", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(64, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(5, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		// With 6 index bits the sentinel bounds registers at 64-6+1
+		sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		sketch.Insert("a", 1)
+		hll, _ := sketch.counters["a"].sketch.(*HyperLogLog[uint64])
+		hll.registers[3] = 59
+		roundTrips(t, sketch)
+
+		hll.registers[3] = 60
+
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+		if err := new(SamplingSpaceSavingSets[string, uint64]).UnmarshalBinary(data); err == nil {
+			t.Error("Expected error decoding a register above the maximum")
+		}
+
+		jsonData, err := sketch.MarshalJSON()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch as JSON: %v", err)
+		}
+		if err := new(SamplingSpaceSavingSets[string, uint64]).UnmarshalJSON(jsonData); err == nil {
+			t.Error("Expected error decoding a JSON register above the maximum")
+		}
+
+		p, err := sketch.ToProto()
+		if err != nil {
+			t.Fatalf("Failed to convert sketch to protobuf: %v", err)
+		}
+		if _, err := SamplingSpaceSavingSetsFromProto[string, uint64](p); err == nil {
+			t.Error("Expected error converting a protobuf register above the maximum")
+		}
+
+		hllData, err := hll.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal HLL: %v", err)
+		}
+		if err := new(HyperLogLog[uint64]).UnmarshalBinary(hllData); err == nil {
+			t.Error("Expected error decoding an HLL register above the maximum")
+		}
+	})
+
+	t.Run("Threshold Is Recomputed", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(64, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(2, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		for i := uint64(0); i < 100; i++ {
+			sketch.Insert("a", i)
+			sketch.Insert("b", i%10)
+		}
+
+		// A threshold above every counter would keep all labels out
+		sketch.threshold = 1 << 40
+
+		_, minCardinality := sketch.minCounter()
+		for name, decoded := range roundTrips(t, sketch) {
+			if decoded.threshold != minCardinality {
+				t.Errorf("Expected %s threshold %d, got %d", name, minCardinality, decoded.threshold)
+			}
+		}
+	})
+
+	t.Run("Malformed Input", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(64, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(5, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		sketch.Insert("a", 1)
+
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		inputs := map[string][]byte{
+			"empty":     nil,
+			"version":   append([]byte{99}, data[1:]...),
+			"version 1": append([]byte{1}, data[1:]...),
+			"truncated": data[:len(data)-1],
+			"trailing":  append(append([]byte{}, data...), 0),
+		}
+
+		for name, input := range inputs {
+			decoded := new(SamplingSpaceSavingSets[string, uint64])
+			if err := decoded.UnmarshalBinary(input); err == nil {
+				t.Errorf("Expected error decoding %s input", name)
+			}
+		}
+	})
+}
+
+// customHasher is a Hasher the encodings cannot restore
+type customHasher struct{}
+
+func (customHasher) Hash(item any) uint64 {
+	return uint64(len(itemText(item)))
+}
+
+// roundTrips decodes a sketch from each of its encodings
+func roundTrips[L comparable, T comparable](
+	t *testing.T,
+	sketch *SamplingSpaceSavingSets[L, T],
+) map[string]*SamplingSpaceSavingSets[L, T] {
+	t.Helper()
+
+	binary, err := sketch.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to marshal sketch: %v", err)
+	}
+
+	fromBinary := new(SamplingSpaceSavingSets[L, T])
+	if err := fromBinary.UnmarshalBinary(binary); err != nil {
+		t.Fatalf("Failed to unmarshal sketch: %v", err)
+	}
+
+	jsonData, err := sketch.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal sketch as JSON: %v", err)
+	}
+
+	fromJSON := new(SamplingSpaceSavingSets[L, T])
+	if err := fromJSON.UnmarshalJSON(jsonData); err != nil {
+		t.Fatalf("Failed to unmarshal JSON sketch: %v", err)
+	}
+
+	p, err := sketch.ToProto()
+	if err != nil {
+		t.Fatalf("Failed to convert sketch to protobuf: %v", err)
+	}
+
+	protoData, err := proto.Marshal(p)
+	if err != nil {
+		t.Fatalf("Failed to marshal protobuf sketch: %v", err)
+	}
+
+	var decodedProto ssssproto.SamplingSpaceSavingSets
+	if err := proto.Unmarshal(protoData, &decodedProto); err != nil {
+		t.Fatalf("Failed to unmarshal protobuf sketch: %v", err)
+	}
+
+	fromProto, err := SamplingSpaceSavingSetsFromProto[L, T](&decodedProto)
+	if err != nil {
+		t.Fatalf("Failed to convert sketch from protobuf: %v", err)
+	}
+
+	return map[string]*SamplingSpaceSavingSets[L, T]{
+		"binary": fromBinary, "JSON": fromJSON, "protobuf": fromProto,
+	}
+}
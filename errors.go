@@ -0,0 +1,59 @@
+package ssss
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrConfigMismatch is matched by the errors returned when merging sketches
+// whose configurations are not compatible, see ConfigMismatchError
+var ErrConfigMismatch = errors.New("config mismatch")
+
+// ErrInvalidConfig is matched by the errors returned for configurations that
+// cannot build sketches, by Validate, the constructors and the decoders
+var ErrInvalidConfig = errors.New("invalid config")
+
+// ErrIncompatibleSketch is matched by the errors returned when merging
+// sketches of different types or layouts, or a nil sketch
+var ErrIncompatibleSketch = errors.New("incompatible sketch")
+
+// ConfigMismatchError reports the configuration field that differs between
+// two sketches, such as "Seeds" or "CardinalitySketchConfig.NumRegisters".
+// It matches ErrConfigMismatch with errors.Is.
+type ConfigMismatchError struct {
+	Field string
+}
+
+func (e *ConfigMismatchError) Error() string {
+	return "config mismatch: different " + e.Field
+}
+
+// Is reports whether target is ErrConfigMismatch
+func (e *ConfigMismatchError) Is(target error) bool {
+	return target == ErrConfigMismatch
+}
+
+// IncompatibleSketchError explains why two sketches cannot be merged
+// regardless of their configurations. It matches ErrIncompatibleSketch with
+// errors.Is.
+type IncompatibleSketchError struct {
+	Reason string
+}
+
+func (e *IncompatibleSketchError) Error() string {
+	return "incompatible sketch: " + e.Reason
+}
+
+// Is reports whether target is ErrIncompatibleSketch
+func (e *IncompatibleSketchError) Is(target error) bool {
+	return target == ErrIncompatibleSketch
+}
+
+// invalidConfig marks an error found validating a configuration, so that it
+// matches ErrInvalidConfig with errors.Is
+func invalidConfig(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+}
@@ -0,0 +1,118 @@
+package ssss
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMergeCompatibility(t *testing.T) {
+	// newConfig creates a configuration that tests then modify
+	newConfig := func(t *testing.T) *Config {
+		t.Helper()
+
+		hllConfig, err := NewHLLConfig(256, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(10, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		return config
+	}
+
+	t.Run("Config Mismatches", func(t *testing.T) {
+		changes := map[string]func(c *Config){
+			"Seeds":                                func(c *Config) { c.Seeds = []uint64{0, 1, 2, 4} },
+			"TrackTotals":                          func(c *Config) { c.TrackTotals = true },
+			"TrackFrequencies":                     func(c *Config) { c.TrackFrequencies = true },
+			"CardinalitySketchConfig.NumRegisters": func(c *Config) { c.CardinalitySketchConfig.NumRegisters = 512 },
+			"CardinalitySketchConfig.Alpha":        func(c *Config) { c.CardinalitySketchConfig.Alpha = 0.7 },
+			"CardinalitySketchConfig.Seeds":        func(c *Config) { c.CardinalitySketchConfig.Seeds[1] = 99 },
+			"CardinalitySketchConfig.Hasher":       func(c *Config) { c.CardinalitySketchConfig.Hasher = RedisHasher{} },
+		}
+
+		for field, change := range changes {
+			other := newConfig(t)
+			change(other)
+
+			sketch := NewHLLSamplingSpaceSavingSets[int, int](newConfig(t))
+			err := sketch.Merge(NewHLLSamplingSpaceSavingSets[int, int](other))
+
+			if !errors.Is(err, ErrConfigMismatch) {
+				t.Errorf("Expected ErrConfigMismatch for %s, got %v", field, err)
+				continue
+			}
+
+			var mismatch *ConfigMismatchError
+			if !errors.As(err, &mismatch) || mismatch.Field != field {
+				t.Errorf("Expected mismatch of %s, got %v", field, err)
+			}
+		}
+	})
+
+	t.Run("Different HLL Seeds", func(t *testing.T) {
+		config1 := newConfig(t)
+		config2 := newConfig(t)
+		config2.CardinalitySketchConfig.Seeds = []uint64{8, 10, 10, 11, 12, 13, 14, 15}
+
+		// Seeds[1] changes the hashing, so the registers can't be combined
+		hll := NewHyperLogLog[int](config1.CardinalitySketchConfig)
+		err := hll.Merge(NewHyperLogLog[int](config2.CardinalitySketchConfig))
+
+		var mismatch *ConfigMismatchError
+		if !errors.As(err, &mismatch) || mismatch.Field != "Seeds" {
+			t.Errorf("Expected a mismatch of the HLL seeds, got %v", err)
+		}
+	})
+
+	t.Run("Compatible Configs", func(t *testing.T) {
+		other := newConfig(t)
+		other.MaxNumCounters = 20
+
+		if err := newConfig(t).CheckCompatible(other); err != nil {
+			t.Errorf("Expected configs differing only in capacity to be compatible, got %v", err)
+		}
+
+		hllConfig := NewRedisHLLConfig()
+		if err := hllConfig.CheckCompatible(NewRedisHLLConfig()); err != nil {
+			t.Errorf("Expected equal hashers to be compatible, got %v", err)
+		}
+	})
+
+	t.Run("Incompatible Sketches", func(t *testing.T) {
+		config := newConfig(t)
+
+		hll := NewHyperLogLog[int](config.CardinalitySketchConfig)
+		cached := NewCachedSketch[int](NewHyperLogLog[int](config.CardinalitySketchConfig))
+
+		hierarchical1, err := NewHierarchicalSketch[string, int](config, 2, func(label string) []string { return []string{label} })
+		if err != nil {
+			t.Fatalf("Failed to create hierarchical sketch: %v", err)
+		}
+
+		hierarchical2, err := NewHierarchicalSketch[string, int](config, 3, func(label string) []string { return []string{label} })
+		if err != nil {
+			t.Fatalf("Failed to create hierarchical sketch: %v", err)
+		}
+
+		errs := map[string]error{
+			"HyperLogLog":   hll.Merge(cached),
+			"sketch":        NewHLLSamplingSpaceSavingSets[int, int](config).Merge((*SamplingSpaceSavingSets[int, int])(nil)),
+			"bidirectional": NewBidirectionalSketch[int, int](config).Merge(nil),
+			"hierarchical":  hierarchical1.Merge(hierarchical2),
+		}
+
+		for name, err := range errs {
+			var incompatible *IncompatibleSketchError
+			if !errors.Is(err, ErrIncompatibleSketch) || !errors.As(err, &incompatible) {
+				t.Errorf("Expected ErrIncompatibleSketch merging %s sketches, got %v", name, err)
+			}
+
+			if errors.Is(err, ErrConfigMismatch) {
+				t.Errorf("Expected %s error not to be a config mismatch", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"fmt"
+	"math/bits"
+	"time"
+
+	"github.com/sawmills/go-ssss"
+)
+
+// Setting is a sketch configuration to evaluate
+type Setting struct {
+	// Policy names the admission policy in the results
+	Policy         string
+	MaxNumCounters int
+	// HLLPrecision is the precision of the cardinality sketches, 2^precision
+	// registers
+	HLLPrecision    int
+	AdmissionPolicy ssss.AdmissionPolicy
+	// MasterSeed seeds the sketch, see ssss.WithMasterSeed
+	MasterSeed string
+	// Options are applied after the fields above, for example ssss.WithTotals
+	// or ssss.WithKeyedHashing to measure their cost
+	Options []ssss.Option
+}
+
+// Policies maps the names accepted by the ssss-eval command to the admission
+// policies
+var Policies = map[string]ssss.AdmissionPolicy{
+	"trailing-zeros": ssss.TrailingZerosPolicy{},
+	"inheritance":    ssss.InheritancePolicy{},
+	"proportional":   ssss.ProportionalPolicy{},
+	"doorkeeper":     ssss.DoorkeeperPolicy{},
+}
+
+// Result is the accuracy and speed of a setting on a workload
+type Result struct {
+	Workload       string
+	Policy         string
+	MaxNumCounters int
+	HLLPrecision   int
+	K              int
+	// Precision is the fraction of the reported top k labels that are in
+	// the true top k
+	Precision float64
+	// Recall is the fraction of the true top k labels that are reported
+	Recall float64
+	// MeanRelativeError and MaxRelativeError compare the estimated and true
+	// cardinalities of the true top k labels
+	MeanRelativeError float64
+	MaxRelativeError  float64
+	EventsPerSecond   float64
+	SizeInBytes       int
+}
+
+// Run inserts the events of a workload into a sketch built from the setting
+// and compares its top k labels with the ground truth
+func Run(w *Workload, setting Setting, k int) (Result, error) {
+	opts := append([]ssss.Option{
+		ssss.WithPrecision(setting.HLLPrecision),
+		ssss.WithMasterSeed(setting.MasterSeed),
+		ssss.WithAdmissionPolicy(setting.AdmissionPolicy),
+	}, setting.Options...)
+
+	config, err := ssss.NewConfigWithOptions(setting.MaxNumCounters, opts...)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create config: %w", err)
+	}
+
+	result, err := Replay(w, config, k)
+	if setting.Policy != "" {
+		result.Policy = setting.Policy
+	}
+	return result, err
+}
+
+// Replay inserts the events of a workload, such as a trace read by
+// ReadTrace, into a sketch with the given configuration and compares its top
+// k labels with the ground truth. The Policy of the result names the
+// admission policy if it is one of Policies.
+func Replay(w *Workload, config *ssss.Config, k int) (Result, error) {
+	if k < 1 {
+		return Result{}, fmt.Errorf("k must be positive, got %d", k)
+	}
+	if err := config.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	sketch := ssss.NewHLLSamplingSpaceSavingSets[uint64, uint64](config)
+	start := time.Now()
+	for _, e := range w.Events {
+		sketch.Insert(e.Label, e.Item)
+	}
+	elapsed := time.Since(start)
+
+	truth := w.Top(k)
+	inTruth := make(map[uint64]bool, len(truth))
+	for _, entry := range truth {
+		inTruth[entry.Label] = true
+	}
+
+	reported := sketch.Top(k)
+	found := 0
+	for _, entry := range reported {
+		if inTruth[entry.Label] {
+			found++
+		}
+	}
+
+	result := Result{
+		Workload:       w.Name,
+		Policy:         policyName(config.AdmissionPolicy),
+		MaxNumCounters: config.MaxNumCounters,
+		HLLPrecision:   bits.Len(uint(config.CardinalitySketchConfig.NumRegisters)) - 1,
+		K:              k,
+		SizeInBytes:    sketch.SizeInBytes(),
+	}
+
+	if len(reported) > 0 {
+		result.Precision = float64(found) / float64(len(reported))
+	}
+	if len(truth) > 0 {
+		result.Recall = float64(found) / float64(len(truth))
+	}
+
+	for _, entry := range truth {
+		estimate := float64(sketch.Cardinality(entry.Label))
+		relativeError := (estimate - float64(entry.Count)) / float64(entry.Count)
+		if relativeError < 0 {
+			relativeError = -relativeError
+		}
+
+		result.MeanRelativeError += relativeError / float64(len(truth))
+		if relativeError > result.MaxRelativeError {
+			result.MaxRelativeError = relativeError
+		}
+	}
+
+	if elapsed > 0 {
+		result.EventsPerSecond = float64(len(w.Events)) / elapsed.Seconds()
+	}
+
+	return result, nil
+}
+
+// policyName returns the name of a policy in Policies, or its type
+func policyName(policy ssss.AdmissionPolicy) string {
+	if policy == nil {
+		return "trailing-zeros"
+	}
+
+	for name, p := range Policies {
+		if p == policy {
+			return name
+		}
+	}
+	return fmt.Sprintf("%T", policy)
+}
@@ -0,0 +1,150 @@
+package eval
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sawmills/go-ssss"
+)
+
+func TestEval(t *testing.T) {
+	t.Run("Ground Truth", func(t *testing.T) {
+		workload, err := Uniform(10000, 10, 100, 1)
+		if err != nil {
+			t.Fatalf("Failed to generate workload: %v", err)
+		}
+
+		// Every label draws from 100 items, and sees all of them in about
+		// 1000 events
+		for label, count := range workload.Truth {
+			if count != 100 {
+				t.Errorf("Expected label %d to have 100 distinct items, got %d", label, count)
+			}
+		}
+
+		if len(workload.Events) != 10000 || len(workload.Truth) != 10 {
+			t.Errorf("Expected 10000 events over 10 labels, got %d over %d", len(workload.Events), len(workload.Truth))
+		}
+	})
+
+	t.Run("Invalid Workloads", func(t *testing.T) {
+		if _, err := Zipf(100, 10, 1, 10, 1); err == nil {
+			t.Error("Expected an error for a zipf exponent of 1")
+		}
+
+		if _, err := Adversarial(100, 0, 1); err == nil {
+			t.Error("Expected an error without heavy labels")
+		}
+	})
+
+	t.Run("Finds The Heavy Labels", func(t *testing.T) {
+		zipf, err := Zipf(100000, 10000, 1.2, 1000, 1)
+		if err != nil {
+			t.Fatalf("Failed to generate workload: %v", err)
+		}
+
+		adversarial, err := Adversarial(100000, 10, 1)
+		if err != nil {
+			t.Fatalf("Failed to generate workload: %v", err)
+		}
+
+		// The heavy labels of the adversarial workload are its top labels
+		for _, entry := range adversarial.Top(10) {
+			if entry.Label >= 10 {
+				t.Errorf("Expected only heavy labels in the top 10, got %d", entry.Label)
+			}
+		}
+
+		// Heavy labels arriving after a flood of small labels are evicted
+		// before they grow under the default policy, while inheritance keeps
+		// them from their first item
+		runs := []struct {
+			workload *Workload
+			setting  Setting
+		}{
+			{zipf, Setting{Policy: "trailing-zeros", AdmissionPolicy: ssss.TrailingZerosPolicy{}}},
+			{adversarial, Setting{Policy: "inheritance", AdmissionPolicy: ssss.InheritancePolicy{}}},
+		}
+
+		for _, r := range runs {
+			r.setting.MaxNumCounters = 100
+			r.setting.HLLPrecision = 10
+			result, err := Run(r.workload, r.setting, 10)
+			if err != nil {
+				t.Fatalf("Failed to run workload: %v", err)
+			}
+
+			if result.Recall < 0.9 || result.Precision < 0.9 {
+				t.Errorf("%s: expected a precision and recall of at least 0.9, got %+v", r.workload.Name, result)
+			}
+		}
+
+		result, err := Run(adversarial, Setting{
+			Policy:          "trailing-zeros",
+			MaxNumCounters:  100,
+			HLLPrecision:    10,
+			AdmissionPolicy: ssss.TrailingZerosPolicy{},
+		}, 10)
+		if err != nil {
+			t.Fatalf("Failed to run workload: %v", err)
+		}
+
+		if result.Recall > 0.5 {
+			t.Errorf("Expected the adversarial workload to defeat the default policy, got %+v", result)
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		workload, err := Uniform(10000, 100, 100, 1)
+		if err != nil {
+			t.Fatalf("Failed to generate workload: %v", err)
+		}
+
+		setting := Setting{MaxNumCounters: 20, HLLPrecision: 8, MasterSeed: "1"}
+		plain, err := Run(workload, setting, 10)
+		if err != nil {
+			t.Fatalf("Failed to run workload: %v", err)
+		}
+
+		// Totals and a subset sample are charged to the sketch size
+		setting.Options = []ssss.Option{ssss.WithTotals(), ssss.WithSubsetSample(64)}
+		tracked, err := Run(workload, setting, 10)
+		if err != nil {
+			t.Fatalf("Failed to run workload: %v", err)
+		}
+
+		if tracked.SizeInBytes <= plain.SizeInBytes {
+			t.Errorf("Expected totals and a subset sample to add to %d bytes, got %d", plain.SizeInBytes, tracked.SizeInBytes)
+		}
+
+		setting.Options = []ssss.Option{ssss.WithKeyedHashing(0, 0)}
+		if _, err := Run(workload, setting, 10); err == nil {
+			t.Error("Expected an error for a zero hash key")
+		}
+	})
+
+	t.Run("Reports", func(t *testing.T) {
+		results := []Result{{Workload: "zipf-1.1", Policy: "inheritance", MaxNumCounters: 100, HLLPrecision: 10, K: 20, Recall: 0.95}}
+
+		var csv bytes.Buffer
+		if err := WriteCSV(&csv, results); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[1], "zipf-1.1,inheritance,100,10,20,0.000,0.950,") {
+			t.Errorf("Unexpected CSV: %q", csv.String())
+		}
+
+		var markdown bytes.Buffer
+		if err := WriteMarkdown(&markdown, results); err != nil {
+			t.Fatalf("Failed to write markdown: %v", err)
+		}
+
+		lines = strings.Split(strings.TrimSpace(markdown.String()), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[2], "| zipf-1.1 | inheritance | 100 |") {
+			t.Errorf("Unexpected markdown: %q", markdown.String())
+		}
+	})
+}
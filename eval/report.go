@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// columns are the headers of the reports, one per Result field
+var columns = []string{
+	"workload",
+	"policy",
+	"counters",
+	"hll_precision",
+	"k",
+	"precision",
+	"recall",
+	"mean_rel_error",
+	"max_rel_error",
+	"events_per_sec",
+	"size_bytes",
+}
+
+// row formats a result in the order of columns
+func (r Result) row() []string {
+	return []string{
+		r.Workload,
+		r.Policy,
+		strconv.Itoa(r.MaxNumCounters),
+		strconv.Itoa(r.HLLPrecision),
+		strconv.Itoa(r.K),
+		strconv.FormatFloat(r.Precision, 'f', 3, 64),
+		strconv.FormatFloat(r.Recall, 'f', 3, 64),
+		strconv.FormatFloat(r.MeanRelativeError, 'f', 4, 64),
+		strconv.FormatFloat(r.MaxRelativeError, 'f', 4, 64),
+		strconv.FormatFloat(r.EventsPerSecond, 'f', 0, 64),
+		strconv.Itoa(r.SizeInBytes),
+	}
+}
+
+// WriteCSV writes the results as CSV with a header row, one row per result,
+// for plotting the metrics against the sketch size
+func WriteCSV(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if err := writer.Write(r.row()); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteMarkdown writes the results as a markdown table
+func WriteMarkdown(w io.Writer, results []Result) error {
+	separators := make([]string, len(columns))
+	for i := range separators {
+		separators[i] = "---"
+	}
+
+	lines := []string{
+		"| " + strings.Join(columns, " | ") + " |",
+		"| " + strings.Join(separators, " | ") + " |",
+	}
+	for _, r := range results {
+		lines = append(lines, "| "+strings.Join(r.row(), " | ")+" |")
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
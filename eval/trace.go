@@ -0,0 +1,147 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Trace files start with traceMagic and a version byte, followed by one
+// record per event. A record is the uvarint identifier of the label, then
+// the item hash as 8 little-endian bytes. Labels are numbered in order of
+// first appearance, and the record that introduces a label carries its
+// length as a uvarint and its bytes after the identifier.
+const (
+	traceMagic   = "SSTR"
+	traceVersion = 1
+)
+
+// TraceWriter records a stream of (label, item hash) events to a trace file
+// that ReadTrace can replay. Items are recorded as hashes, so traces of
+// production streams hold no item values; any stable 64-bit hash such as
+// FNV-1a works, and replays insert the hashes as items.
+type TraceWriter struct {
+	w      *bufio.Writer
+	labels map[string]uint64
+	buf    [2 * binary.MaxVarintLen64]byte
+}
+
+// NewTraceWriter writes the trace header to w. Call Flush when done.
+func NewTraceWriter(w io.Writer) (*TraceWriter, error) {
+	t := &TraceWriter{
+		w:      bufio.NewWriter(w),
+		labels: make(map[string]uint64),
+	}
+
+	if _, err := t.w.WriteString(traceMagic); err != nil {
+		return nil, err
+	}
+	if err := t.w.WriteByte(traceVersion); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Record appends an event to the trace
+func (t *TraceWriter) Record(label string, itemHash uint64) error {
+	id, exists := t.labels[label]
+	if !exists {
+		id = uint64(len(t.labels))
+		t.labels[label] = id
+	}
+
+	n := binary.PutUvarint(t.buf[:], id)
+	if !exists {
+		n += binary.PutUvarint(t.buf[n:], uint64(len(label)))
+	}
+	if _, err := t.w.Write(t.buf[:n]); err != nil {
+		return err
+	}
+
+	if !exists {
+		if _, err := t.w.WriteString(label); err != nil {
+			return err
+		}
+	}
+
+	binary.LittleEndian.PutUint64(t.buf[:8], itemHash)
+	_, err := t.w.Write(t.buf[:8])
+	return err
+}
+
+// Flush writes the buffered events to the underlying writer
+func (t *TraceWriter) Flush() error {
+	return t.w.Flush()
+}
+
+// ReadTrace reads a trace file into a workload named name, with the exact
+// number of distinct item hashes of every label as ground truth. The events
+// are held in memory, so Run and Replay measure the insert throughput
+// without reading the file.
+func ReadTrace(r io.Reader, name string) (*Workload, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(traceMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("invalid trace: %w", err)
+	}
+	if string(header[:len(traceMagic)]) != traceMagic {
+		return nil, errors.New("invalid trace: bad magic number")
+	}
+	if version := header[len(traceMagic)]; version != traceVersion {
+		return nil, fmt.Errorf("unsupported trace version %d", version)
+	}
+
+	b := newBuilder(name, 0)
+	var hash [8]byte
+	for {
+		id, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace: %w", err)
+		}
+
+		switch {
+		case id == uint64(len(b.workload.Labels)):
+			label, err := readLabel(br)
+			if err != nil {
+				return nil, err
+			}
+			b.workload.Labels = append(b.workload.Labels, label)
+		case id > uint64(len(b.workload.Labels)):
+			return nil, fmt.Errorf("invalid trace: unknown label %d", id)
+		}
+
+		if _, err := io.ReadFull(br, hash[:]); err != nil {
+			return nil, fmt.Errorf("invalid trace: truncated event: %w", err)
+		}
+		b.event(Event{Label: id, Item: binary.LittleEndian.Uint64(hash[:])})
+	}
+
+	return b.workload, nil
+}
+
+// maxTraceLabelLength bounds the labels of a trace, so corrupted lengths
+// don't allocate unbounded memory
+const maxTraceLabelLength = 1 << 20
+
+// readLabel reads the length and bytes of a label
+func readLabel(br *bufio.Reader) (string, error) {
+	length, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", fmt.Errorf("invalid trace: truncated label: %w", err)
+	}
+	if length > maxTraceLabelLength {
+		return "", fmt.Errorf("invalid trace: label of %d bytes", length)
+	}
+
+	label := make([]byte, length)
+	if _, err := io.ReadFull(br, label); err != nil {
+		return "", fmt.Errorf("invalid trace: truncated label: %w", err)
+	}
+	return string(label), nil
+}
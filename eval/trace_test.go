@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sawmills/go-ssss"
+)
+
+func TestTrace(t *testing.T) {
+	record := func(t *testing.T, events []Event) []byte {
+		var buf bytes.Buffer
+		writer, err := NewTraceWriter(&buf)
+		if err != nil {
+			t.Fatalf("Failed to create trace writer: %v", err)
+		}
+
+		for _, e := range events {
+			if err := writer.Record(fmt.Sprintf("label-%d", e.Label), e.Item); err != nil {
+				t.Fatalf("Failed to record event: %v", err)
+			}
+		}
+
+		if err := writer.Flush(); err != nil {
+			t.Fatalf("Failed to flush trace: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		zipf, err := Zipf(20000, 1000, 1.2, 500, 1)
+		if err != nil {
+			t.Fatalf("Failed to generate workload: %v", err)
+		}
+
+		workload, err := ReadTrace(bytes.NewReader(record(t, zipf.Events)), "zipf.trace")
+		if err != nil {
+			t.Fatalf("Failed to read trace: %v", err)
+		}
+
+		if len(workload.Events) != len(zipf.Events) || len(workload.Labels) != len(zipf.Truth) {
+			t.Fatalf("Expected %d events over %d labels, got %d over %d",
+				len(zipf.Events), len(zipf.Truth), len(workload.Events), len(workload.Labels))
+		}
+
+		// Labels are numbered in order of appearance, with the same ground
+		// truth as the recorded workload
+		for i, e := range workload.Events {
+			label := workload.Labels[e.Label]
+			if label != fmt.Sprintf("label-%d", zipf.Events[i].Label) || e.Item != zipf.Events[i].Item {
+				t.Fatalf("Event %d: expected %+v, got %s %d", i, zipf.Events[i], label, e.Item)
+			}
+		}
+
+		top := workload.Top(5)
+		for i, entry := range zipf.Top(5) {
+			if workload.Labels[top[i].Label] != fmt.Sprintf("label-%d", entry.Label) || top[i].Count != entry.Count {
+				t.Errorf("Expected top label %+v, got %s with %d", entry, workload.Labels[top[i].Label], top[i].Count)
+			}
+		}
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		zipf, err := Zipf(20000, 1000, 1.2, 500, 1)
+		if err != nil {
+			t.Fatalf("Failed to generate workload: %v", err)
+		}
+
+		workload, err := ReadTrace(bytes.NewReader(record(t, zipf.Events)), "zipf.trace")
+		if err != nil {
+			t.Fatalf("Failed to read trace: %v", err)
+		}
+
+		config, err := ssss.NewConfigWithOptions(50, ssss.WithPrecision(8), ssss.WithMasterSeed("trace"))
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		result, err := Replay(workload, config, 5)
+		if err != nil {
+			t.Fatalf("Failed to replay trace: %v", err)
+		}
+
+		if result.Workload != "zipf.trace" || result.Policy != "trailing-zeros" || result.HLLPrecision != 8 {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+
+		if result.Recall < 0.8 {
+			t.Errorf("Expected a recall of at least 0.8, got %+v", result)
+		}
+	})
+
+	t.Run("Invalid Traces", func(t *testing.T) {
+		valid := record(t, []Event{{Label: 1, Item: 10}, {Label: 2, Item: 20}})
+
+		traces := map[string][]byte{
+			"empty":           nil,
+			"bad magic":       append([]byte("XXXX"), valid[4:]...),
+			"bad version":     append([]byte(traceMagic+"\x09"), valid[5:]...),
+			"truncated event": valid[:len(valid)-1],
+			"truncated label": valid[:8],
+			"unknown label":   append([]byte(traceMagic+"\x01"), 5),
+		}
+
+		for name, data := range traces {
+			if _, err := ReadTrace(bytes.NewReader(data), name); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+
+		// A trace without events is valid
+		workload, err := ReadTrace(strings.NewReader(traceMagic+"\x01"), "header only")
+		if err != nil || len(workload.Events) != 0 {
+			t.Errorf("Expected an empty workload, got %v", err)
+		}
+	})
+}
+
+func FuzzReadTrace(f *testing.F) {
+	var buf bytes.Buffer
+	writer, err := NewTraceWriter(&buf)
+	if err != nil {
+		f.Fatalf("Failed to create trace writer: %v", err)
+	}
+	for i := uint64(0); i < 20; i++ {
+		if err := writer.Record(fmt.Sprintf("label-%d", i%3), mix(i)); err != nil {
+			f.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		f.Fatalf("Failed to flush trace: %v", err)
+	}
+	f.Add(buf.Bytes())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		workload, err := ReadTrace(bytes.NewReader(data), "fuzz")
+		if err != nil {
+			return
+		}
+
+		// Every event refers to a known label, and the ground truth counts
+		// at most one item per event
+		var total uint64
+		for _, e := range workload.Events {
+			if e.Label >= uint64(len(workload.Labels)) {
+				t.Fatalf("Event with unknown label %d", e.Label)
+			}
+		}
+		for _, count := range workload.Truth {
+			total += count
+		}
+		if total > uint64(len(workload.Events)) {
+			t.Fatalf("Ground truth has %d items for %d events", total, len(workload.Events))
+		}
+	})
+}
@@ -0,0 +1,183 @@
+// Package eval measures how well SamplingSpaceSavingSets finds the heavy
+// distinct hitters of synthetic or recorded streams with known ground truth.
+//
+// A Workload is a stream of (label, item) events together with the number
+// of distinct items of every label. Run inserts a workload into a sketch
+// built from a Setting and reports the precision and recall of its top k
+// labels, the relative error of their cardinalities and the throughput.
+// TraceWriter records real streams to trace files, which ReadTrace loads as
+// workloads for Replay against any Config.
+// WriteCSV and WriteMarkdown format the results, and the ssss-eval command
+// sweeps workloads and settings from the command line.
+package eval
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Event is a (label, item) pair of a stream
+type Event struct {
+	Label uint64
+	Item  uint64
+}
+
+// Workload is a stream of events with its ground truth
+type Workload struct {
+	Name   string
+	Events []Event
+	// Truth is the number of distinct items of each label
+	Truth map[uint64]uint64
+	// Labels holds the text of the labels of a trace, indexed by the labels
+	// of the events. It is nil for synthetic workloads.
+	Labels []string
+}
+
+// LabelCount is a label with its true number of distinct items
+type LabelCount struct {
+	Label uint64
+	Count uint64
+}
+
+// Top returns the k labels with the most distinct items, by decreasing
+// count and then increasing label
+func (w *Workload) Top(k int) []LabelCount {
+	entries := make([]LabelCount, 0, len(w.Truth))
+	for label, count := range w.Truth {
+		entries = append(entries, LabelCount{Label: label, Count: count})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Label < entries[j].Label
+	})
+
+	if k < len(entries) {
+		entries = entries[:k]
+	}
+	return entries
+}
+
+// builder generates a workload and counts its distinct items
+type builder struct {
+	workload *Workload
+	seen     map[Event]struct{}
+}
+
+func newBuilder(name string, numEvents int) *builder {
+	return &builder{
+		workload: &Workload{
+			Name:   name,
+			Events: make([]Event, 0, numEvents),
+			Truth:  make(map[uint64]uint64),
+		},
+		seen: make(map[Event]struct{}),
+	}
+}
+
+// add appends the j-th item of a label, so items repeat within a label
+func (b *builder) add(label uint64, j uint64) {
+	b.event(Event{Label: label, Item: mix(label<<32 ^ j)})
+}
+
+// event appends an event and updates the ground truth
+func (b *builder) event(e Event) {
+	b.workload.Events = append(b.workload.Events, e)
+
+	if _, exists := b.seen[e]; !exists {
+		b.seen[e] = struct{}{}
+		b.workload.Truth[e.Label]++
+	}
+}
+
+// Zipf generates numEvents events whose labels follow a Zipf distribution
+// with the given exponent, greater than 1, over numLabels labels. Each event
+// draws its item uniformly from itemsPerLabel items of its label, so heavy
+// labels also have many duplicates.
+func Zipf(numEvents, numLabels int, exponent float64, itemsPerLabel int, seed int64) (*Workload, error) {
+	if exponent <= 1 {
+		return nil, fmt.Errorf("zipf exponent must be greater than 1, got %g", exponent)
+	}
+	if numLabels < 1 || itemsPerLabel < 1 {
+		return nil, fmt.Errorf("need at least one label and one item per label")
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(r, exponent, 1, uint64(numLabels-1))
+
+	b := newBuilder(fmt.Sprintf("zipf-%g", exponent), numEvents)
+	for i := 0; i < numEvents; i++ {
+		b.add(zipf.Uint64(), uint64(r.Intn(itemsPerLabel)))
+	}
+	return b.workload, nil
+}
+
+// Uniform generates numEvents events with labels and items drawn uniformly,
+// where the top labels are only slightly heavier than the rest
+func Uniform(numEvents, numLabels, itemsPerLabel int, seed int64) (*Workload, error) {
+	if numLabels < 1 || itemsPerLabel < 1 {
+		return nil, fmt.Errorf("need at least one label and one item per label")
+	}
+
+	r := rand.New(rand.NewSource(seed))
+
+	b := newBuilder("uniform", numEvents)
+	for i := 0; i < numEvents; i++ {
+		b.add(uint64(r.Intn(numLabels)), uint64(r.Intn(itemsPerLabel)))
+	}
+	return b.workload, nil
+}
+
+// Adversarial generates numEvents events where numHeavy heavy labels only
+// appear in the second half of the stream, after the counters have filled
+// with a flood of labels with a few distinct items each, and the flood
+// continues around them. Each heavy label gets about 1% of the events with
+// distinct items. The heavy labels are the first numHeavy labels. Under
+// the default admission policy, a heavy label that takes over a counter with
+// its first item is evicted by the flood before it grows.
+func Adversarial(numEvents, numHeavy int, seed int64) (*Workload, error) {
+	if numHeavy < 1 || numHeavy > 50 {
+		return nil, fmt.Errorf("number of heavy labels must be between 1 and 50, got %d", numHeavy)
+	}
+
+	r := rand.New(rand.NewSource(seed))
+
+	// Flood labels start after the heavy labels and get 1 to 4 items each
+	flood := uint64(numHeavy)
+	nextFloodEvent := func(b *builder) {
+		if r.Intn(4) == 0 {
+			flood++
+		}
+		b.add(flood, uint64(r.Intn(4)))
+	}
+
+	b := newBuilder("adversarial", numEvents)
+	for i := 0; i < numEvents/2; i++ {
+		nextFloodEvent(b)
+	}
+
+	var next uint64
+	for i := numEvents / 2; i < numEvents; i++ {
+		if r.Intn(100) < numHeavy {
+			b.add(uint64(r.Intn(numHeavy)), next)
+			next++
+			continue
+		}
+		nextFloodEvent(b)
+	}
+	return b.workload, nil
+}
+
+// mix is the splitmix64 finalizer, used to spread item identifiers over the
+// 64-bit range
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,324 @@
+package ssss
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sawmills/go-ssss/ssssproto"
+	"google.golang.org/protobuf/proto"
+)
+
+// fuzzSketch returns a sketch with totals, frequencies and a subset sample,
+// so the seed corpus covers every optional part of the encodings
+func fuzzSketch(f *testing.F) *SamplingSpaceSavingSets[string, uint64] {
+	config, err := NewConfigWithOptions(4,
+		WithPrecision(4),
+		WithSeeds([]uint64{0, 1, 2, 3}),
+		WithHLLSeeds([]uint64{8, 9, 10, 11, 12, 13, 14, 15}),
+		WithTotals(),
+		WithFrequencies(),
+		WithSubsetSample(8),
+	)
+	if err != nil {
+		f.Fatalf("Failed to create config: %v", err)
+	}
+
+	sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+	for i := uint64(0); i < 200; i++ {
+		sketch.Insert(string(rune('a'+i%7)), i*0x9e3779b97f4a7c15)
+	}
+	return sketch
+}
+
+// hugeFuzzSketch returns a sketch whose configuration claims 2^28 counters,
+// which decoders must not preallocate
+func hugeFuzzSketch(f *testing.F) *SamplingSpaceSavingSets[string, uint64] {
+	sketch := fuzzSketch(f)
+	sketch.config.MaxNumCounters = 1 << 28
+	return sketch
+}
+
+// fuzzSeedSketches returns the seed sketches of the sketch decoders: valid
+// ones, and ones with a register above the maximum or a threshold that
+// contradicts the counters, which decoders must reject or recompute
+func fuzzSeedSketches(f *testing.F) []*SamplingSpaceSavingSets[string, uint64] {
+	register := fuzzSketch(f)
+	for _, counter := range register.counters {
+		hll, _ := counter.sketch.(*HyperLogLog[uint64])
+		hll.registers[0] = hll.config.maxRegisterValue() + 1
+		break
+	}
+
+	threshold := fuzzSketch(f)
+	threshold.threshold = 1 << 40
+
+	return []*SamplingSpaceSavingSets[string, uint64]{fuzzSketch(f), hugeFuzzSketch(f), register, threshold}
+}
+
+// fuzzHLL returns a HyperLogLog sketch with the given configuration
+func fuzzHLL(config *HLLConfig, numItems int) *HyperLogLog[uint64] {
+	hll := NewHyperLogLog[uint64](config)
+	for i := 0; i < numItems; i++ {
+		hll.Insert(uint64(i) * 0x9e3779b97f4a7c15)
+	}
+	return hll
+}
+
+// checkDecodedSketch uses a decoded sketch, which must not panic, and checks
+// that it holds no more counters than its configuration allows. Encodings
+// don't include the Hasher, so inserts need a valid decoded configuration.
+func checkDecodedSketch(t *testing.T, sketch *SamplingSpaceSavingSets[string, uint64]) {
+	if len(sketch.counters) > sketch.config.MaxNumCounters {
+		t.Fatalf("Decoded %d counters with MaxNumCounters %d", len(sketch.counters), sketch.config.MaxNumCounters)
+	}
+
+	// The hash key of keyed sketches is never encoded
+	if errors.Is(sketch.config.validate(), errMissingHashKey) {
+		if err := sketch.SetHashKey(1, 2); err != nil {
+			t.Fatalf("Failed to set hash key: %v", err)
+		}
+	}
+
+	if err := sketch.config.Validate(); err != nil {
+		t.Fatalf("Decoded an invalid config: %v", err)
+	}
+
+	for _, counter := range sketch.counters {
+		hll, _ := counter.sketch.(*HyperLogLog[uint64])
+		if err := hll.config.checkRegisters(hll.registers); err != nil {
+			t.Fatalf("Decoded invalid registers: %v", err)
+		}
+	}
+
+	if _, minCardinality := sketch.minCounter(); len(sketch.counters) > 0 && sketch.threshold != minCardinality {
+		t.Fatalf("Expected threshold %d from the counters, got %d", minCardinality, sketch.threshold)
+	}
+
+	sketch.Top(10)
+	sketch.Cardinality("fuzz")
+	sketch.Insert("fuzz", 1)
+	if sketch.sample != nil {
+		if _, err := sketch.EstimateSubset(func(string) bool { return true }); err != nil {
+			t.Fatalf("Failed to estimate subset: %v", err)
+		}
+	}
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	for _, sketch := range fuzzSeedSketches(f) {
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			f.Fatalf("Failed to marshal sketch: %v", err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var sketch SamplingSpaceSavingSets[string, uint64]
+		if err := sketch.UnmarshalBinary(data); err != nil {
+			return
+		}
+
+		// Encoding is canonical once decoded
+		encoded, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal decoded sketch: %v", err)
+		}
+
+		var decoded SamplingSpaceSavingSets[string, uint64]
+		if err := decoded.UnmarshalBinary(encoded); err != nil {
+			t.Fatalf("Failed to unmarshal re-encoded sketch: %v", err)
+		}
+
+		reencoded, err := decoded.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal decoded sketch: %v", err)
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatal("Re-encoded sketch differs")
+		}
+
+		checkDecodedSketch(t, &sketch)
+	})
+}
+
+func FuzzHyperLogLogUnmarshalBinary(f *testing.F) {
+	config, err := NewHLLConfig(16, []uint64{8, 9})
+	if err != nil {
+		f.Fatalf("Failed to create HLL config: %v", err)
+	}
+
+	// The second seed has a register above the maximum
+	invalid := fuzzHLL(config, 100)
+	invalid.registers[0] = config.maxRegisterValue() + 1
+
+	for _, hll := range []*HyperLogLog[uint64]{fuzzHLL(config, 100), invalid} {
+		data, err := hll.MarshalBinary()
+		if err != nil {
+			f.Fatalf("Failed to marshal HLL: %v", err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var hll HyperLogLog[uint64]
+		if err := hll.UnmarshalBinary(data); err != nil {
+			return
+		}
+
+		if err := hll.config.checkRegisters(hll.registers); err != nil {
+			t.Fatalf("Decoded invalid registers: %v", err)
+		}
+
+		encoded, err := hll.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal decoded HLL: %v", err)
+		}
+
+		var decoded HyperLogLog[uint64]
+		if err := decoded.UnmarshalBinary(encoded); err != nil {
+			t.Fatalf("Failed to unmarshal re-encoded HLL: %v", err)
+		}
+		if decoded.Cardinality() != hll.Cardinality() {
+			t.Fatalf("Expected cardinality %d, got %d", hll.Cardinality(), decoded.Cardinality())
+		}
+	})
+}
+
+func FuzzUnmarshalJSON(f *testing.F) {
+	for _, sketch := range fuzzSeedSketches(f) {
+		data, err := sketch.MarshalJSON()
+		if err != nil {
+			f.Fatalf("Failed to marshal sketch: %v", err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var sketch SamplingSpaceSavingSets[string, uint64]
+		if err := sketch.UnmarshalJSON(data); err != nil {
+			return
+		}
+
+		encoded, err := sketch.MarshalJSON()
+		if err != nil {
+			t.Fatalf("Failed to marshal decoded sketch: %v", err)
+		}
+
+		var decoded SamplingSpaceSavingSets[string, uint64]
+		if err := decoded.UnmarshalJSON(encoded); err != nil {
+			t.Fatalf("Failed to unmarshal re-encoded sketch: %v", err)
+		}
+
+		checkDecodedSketch(t, &sketch)
+	})
+}
+
+func FuzzSamplingSpaceSavingSetsFromProto(f *testing.F) {
+	for _, sketch := range fuzzSeedSketches(f) {
+		p, err := sketch.ToProto()
+		if err != nil {
+			f.Fatalf("Failed to convert sketch: %v", err)
+		}
+
+		data, err := proto.Marshal(p)
+		if err != nil {
+			f.Fatalf("Failed to marshal protobuf: %v", err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var p ssssproto.SamplingSpaceSavingSets
+		if err := proto.Unmarshal(data, &p); err != nil {
+			return
+		}
+
+		sketch, err := SamplingSpaceSavingSetsFromProto[string, uint64](&p)
+		if err != nil {
+			return
+		}
+
+		if _, err := sketch.ToProto(); err != nil {
+			t.Fatalf("Failed to convert decoded sketch: %v", err)
+		}
+
+		checkDecodedSketch(t, sketch)
+	})
+}
+
+func FuzzUnmarshalRedis(f *testing.F) {
+	for _, numItems := range []int{10, 100000} {
+		data, err := fuzzHLL(NewRedisHLLConfig(), numItems).MarshalRedis()
+		if err != nil {
+			f.Fatalf("Failed to marshal Redis HLL: %v", err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		hll, err := HyperLogLogFromRedis[uint64](data)
+		if err != nil {
+			return
+		}
+
+		hll.Cardinality()
+		if _, err := hll.MarshalRedis(); err != nil {
+			t.Fatalf("Failed to marshal decoded HLL: %v", err)
+		}
+	})
+}
+
+func FuzzUnmarshalDataSketches(f *testing.F) {
+	config, err := NewDataSketchesHLLConfig(4)
+	if err != nil {
+		f.Fatalf("Failed to create HLL config: %v", err)
+	}
+
+	for _, hllType := range []DataSketchesHLLType{DataSketchesHLL4, DataSketchesHLL6, DataSketchesHLL8} {
+		for _, numItems := range []int{3, 1000} {
+			data, err := fuzzHLL(config, numItems).MarshalDataSketches(hllType)
+			if err != nil {
+				f.Fatalf("Failed to marshal DataSketches HLL: %v", err)
+			}
+			f.Add(data)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		hll, err := HyperLogLogFromDataSketches[uint64](data)
+		if err != nil {
+			return
+		}
+
+		hll.Cardinality()
+		if _, err := hll.MarshalDataSketches(DataSketchesHLL8); err != nil {
+			t.Fatalf("Failed to marshal decoded HLL: %v", err)
+		}
+	})
+}
+
+func FuzzUnmarshalPostgres(f *testing.F) {
+	config, err := NewPostgresHLLConfig(4)
+	if err != nil {
+		f.Fatalf("Failed to create HLL config: %v", err)
+	}
+
+	for _, numItems := range []int{3, 1000} {
+		data, err := fuzzHLL(config, numItems).MarshalPostgres(PostgresHLLDefaultRegWidth)
+		if err != nil {
+			f.Fatalf("Failed to marshal Postgres HLL: %v", err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		hll, err := HyperLogLogFromPostgres[uint64](data)
+		if err != nil {
+			return
+		}
+
+		hll.Cardinality()
+	})
+}
@@ -1,3 +1,5 @@
 module github.com/sawmills/go-ssss
 
 go 1.18
+
+require google.golang.org/protobuf v1.33.0
@@ -0,0 +1,290 @@
+package ssss
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// GroupBySketch tracks heavy distinct hitters for combinations of attributes.
+// Records have a value for each of a fixed list of dimensions, and the
+// sketch keeps a SamplingSpaceSavingSets per configured subset of dimensions
+// (a cuboid), labeled by the values of the record for that subset.
+//
+// It is not safe for concurrent use with Insert, Merge or Clear, but queries
+// may run concurrently with each other.
+type GroupBySketch[T comparable] struct {
+	dimensions []string
+	index      map[string]int
+	cuboids    []*cuboid[T]
+	byKey      map[string]*cuboid[T]
+	// buf holds the labels built by Insert
+	buf []byte
+}
+
+// cuboid is the sketch of one subset of dimensions
+type cuboid[T comparable] struct {
+	// dims are the indices of the dimensions of the subset, in record order
+	dims   []int
+	sketch *SamplingSpaceSavingSets[string, T]
+}
+
+// GroupCount is a combination of dimension values and its estimated cardinality
+type GroupCount struct {
+	Values map[string]string
+	Count  uint64
+}
+
+// NewGroupBySketch creates a new GroupBySketch for records with the given
+// dimensions. Each subset is a list of dimension names. The counters of all
+// subsets together are limited to maxTotalCounters, split evenly between the
+// subsets; the MaxNumCounters of config is not used. Neither are TrackTotals
+// and SubsetSampleSize, which would add memory per subset outside the budget
+// for estimates the GroupBySketch does not offer.
+func NewGroupBySketch[T comparable](
+	config *Config,
+	maxTotalCounters int,
+	dimensions []string,
+	subsets [][]string,
+) (*GroupBySketch[T], error) {
+	if len(subsets) == 0 {
+		return nil, errors.New("at least one subset of dimensions is required")
+	}
+
+	if maxTotalCounters < len(subsets) {
+		return nil, errors.New("max total counters must be at least the number of subsets")
+	}
+
+	index := make(map[string]int, len(dimensions))
+	for i, dimension := range dimensions {
+		if _, exists := index[dimension]; exists {
+			return nil, fmt.Errorf("duplicate dimension %q", dimension)
+		}
+		index[dimension] = i
+	}
+
+	g := &GroupBySketch[T]{
+		dimensions: append([]string(nil), dimensions...),
+		index:      index,
+		byKey:      make(map[string]*cuboid[T], len(subsets)),
+	}
+
+	for i, subset := range subsets {
+		dims, err := g.subsetDims(subset)
+		if err != nil {
+			return nil, err
+		}
+
+		key := g.key(dims)
+		if _, exists := g.byKey[key]; exists {
+			return nil, fmt.Errorf("duplicate subset %v", subset)
+		}
+
+		// Split the budget, giving the remainder to the first subsets
+		cuboidConfig := *config
+		cuboidConfig.MaxNumCounters = maxTotalCounters / len(subsets)
+		if i < maxTotalCounters%len(subsets) {
+			cuboidConfig.MaxNumCounters++
+		}
+		cuboidConfig.TrackTotals = false
+		cuboidConfig.SubsetSampleSize = 0
+
+		c := &cuboid[T]{
+			dims:   dims,
+			sketch: NewHLLSamplingSpaceSavingSets[string, T](&cuboidConfig),
+		}
+		g.cuboids = append(g.cuboids, c)
+		g.byKey[key] = c
+	}
+
+	return g, nil
+}
+
+// Insert adds an item to the set of each combination of values of the record.
+// The record holds one value per dimension, in the order of the dimensions.
+func (g *GroupBySketch[T]) Insert(record []string, item T) error {
+	if len(record) != len(g.dimensions) {
+		return fmt.Errorf("expected %d dimension values, got %d", len(g.dimensions), len(record))
+	}
+
+	for _, c := range g.cuboids {
+		g.buf = appendLabel(g.buf[:0], c.dims, record)
+		c.sketch.Insert(string(g.buf), item)
+	}
+	return nil
+}
+
+// Top returns the k combinations of values of a subset of dimensions with the
+// highest cardinality. The subset must be one of the configured subsets, in
+// any order.
+func (g *GroupBySketch[T]) Top(k int, subset []string) ([]GroupCount, error) {
+	c, err := g.cuboid(subset)
+	if err != nil {
+		return nil, err
+	}
+
+	top := c.sketch.Top(k)
+	groups := make([]GroupCount, 0, len(top))
+	for _, entry := range top {
+		values, err := g.values(c.dims, entry.Label)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, GroupCount{Values: values, Count: entry.Count})
+	}
+
+	return groups, nil
+}
+
+// Cardinality returns the estimated cardinality of a combination of values,
+// given by dimension name. The dimensions must be one of the configured
+// subsets.
+func (g *GroupBySketch[T]) Cardinality(values map[string]string) (uint64, error) {
+	subset := make([]string, 0, len(values))
+	for dimension := range values {
+		subset = append(subset, dimension)
+	}
+
+	c, err := g.cuboid(subset)
+	if err != nil {
+		return 0, err
+	}
+
+	record := make([]string, len(g.dimensions))
+	for _, dim := range c.dims {
+		record[dim] = values[g.dimensions[dim]]
+	}
+
+	// Queries may run concurrently, so they do not share the buffer of Insert
+	return c.sketch.Cardinality(string(appendLabel(nil, c.dims, record))), nil
+}
+
+// Subsets returns the configured subsets of dimensions, in record order
+func (g *GroupBySketch[T]) Subsets() [][]string {
+	subsets := make([][]string, len(g.cuboids))
+	for i, c := range g.cuboids {
+		for _, dim := range c.dims {
+			subsets[i] = append(subsets[i], g.dimensions[dim])
+		}
+	}
+	return subsets
+}
+
+// Merge combines this sketch with another one with the same dimensions and
+// subsets
+func (g *GroupBySketch[T]) Merge(other *GroupBySketch[T]) error {
+	if !g.sameLayout(other) {
+		return &IncompatibleSketchError{Reason: "can only merge with a GroupBySketch with the same dimensions and subsets"}
+	}
+
+	for key, c := range g.byKey {
+		if err := c.sketch.Merge(other.byKey[key].sketch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear resets the sketch to its initial state
+func (g *GroupBySketch[T]) Clear() {
+	for _, c := range g.cuboids {
+		c.sketch.Clear()
+	}
+}
+
+// sameLayout reports whether another sketch has the same dimensions and subsets
+func (g *GroupBySketch[T]) sameLayout(other *GroupBySketch[T]) bool {
+	if other == nil || len(other.dimensions) != len(g.dimensions) || len(other.byKey) != len(g.byKey) {
+		return false
+	}
+
+	for i, dimension := range g.dimensions {
+		if other.dimensions[i] != dimension {
+			return false
+		}
+	}
+
+	for key := range g.byKey {
+		if _, exists := other.byKey[key]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// subsetDims resolves dimension names to their sorted indices
+func (g *GroupBySketch[T]) subsetDims(subset []string) ([]int, error) {
+	if len(subset) == 0 {
+		return nil, errors.New("subsets must have at least one dimension")
+	}
+
+	dims := make([]int, 0, len(subset))
+	seen := make(map[int]bool, len(subset))
+	for _, dimension := range subset {
+		dim, exists := g.index[dimension]
+		if !exists {
+			return nil, fmt.Errorf("unknown dimension %q", dimension)
+		}
+		if seen[dim] {
+			return nil, fmt.Errorf("duplicate dimension %q in subset", dimension)
+		}
+		seen[dim] = true
+		dims = append(dims, dim)
+	}
+
+	sort.Ints(dims)
+	return dims, nil
+}
+
+// cuboid finds the sketch of a configured subset
+func (g *GroupBySketch[T]) cuboid(subset []string) (*cuboid[T], error) {
+	dims, err := g.subsetDims(subset)
+	if err != nil {
+		return nil, err
+	}
+
+	c, exists := g.byKey[g.key(dims)]
+	if !exists {
+		return nil, fmt.Errorf("subset %v is not configured", subset)
+	}
+	return c, nil
+}
+
+// key identifies a subset by its dimension indices
+func (g *GroupBySketch[T]) key(dims []int) string {
+	return fmt.Sprint(dims)
+}
+
+// appendLabel appends the composite label of a record for a subset. Each
+// value is prefixed with its length, so any value can be used without
+// escaping.
+func appendLabel(buf []byte, dims []int, record []string) []byte {
+	for _, dim := range dims {
+		buf = appendUvarint(buf, uint64(len(record[dim])))
+		buf = append(buf, record[dim]...)
+	}
+	return buf
+}
+
+// values splits a composite label into the values of its dimensions
+func (g *GroupBySketch[T]) values(dims []int, label string) (map[string]string, error) {
+	values := make(map[string]string, len(dims))
+	rest := []byte(label)
+	for _, dim := range dims {
+		n, size := binary.Uvarint(rest)
+		if size <= 0 || uint64(len(rest)-size) < n {
+			return nil, errors.New("malformed composite label")
+		}
+		values[g.dimensions[dim]] = string(rest[size : size+int(n)])
+		rest = rest[size+int(n):]
+	}
+	return values, nil
+}
+
+// appendUvarint appends the varint encoding of v
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(buf, b[:n]...)
+}
@@ -0,0 +1,260 @@
+package ssss
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestGroupBySketch(t *testing.T) {
+	dimensions := []string{"service", "region", "status"}
+	subsets := [][]string{{"service"}, {"service", "region"}, {"region", "status"}}
+
+	// newSketch creates a sketch with 30 counters across the three subsets
+	newSketch := func(t *testing.T) *GroupBySketch[uint64] {
+		t.Helper()
+
+		hllConfig, err := NewHLLConfig(256, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(1, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch, err := NewGroupBySketch[uint64](config, 30, dimensions, subsets)
+		if err != nil {
+			t.Fatalf("Failed to create group-by sketch: %v", err)
+		}
+		return sketch
+	}
+
+	// traffic inserts 400 users of checkout in eu with status 500, and 50
+	// users for each other combination of 3 services, 3 regions and 2 statuses
+	traffic := func(t *testing.T, sketch *GroupBySketch[uint64]) {
+		user := uint64(0)
+		for _, service := range []string{"checkout", "search", "auth"} {
+			for _, region := range []string{"eu", "us", "ap"} {
+				for _, status := range []string{"200", "500"} {
+					n := 50
+					if service == "checkout" && region == "eu" && status == "500" {
+						n = 400
+					}
+					for i := 0; i < n; i++ {
+						if err := sketch.Insert([]string{service, region, status}, user*0x9e3779b97f4a7c15); err != nil {
+							t.Fatalf("Failed to insert record: %v", err)
+						}
+						user++
+					}
+				}
+			}
+		}
+	}
+
+	t.Run("Top Per Subset", func(t *testing.T) {
+		sketch := newSketch(t)
+		traffic(t, sketch)
+
+		top, err := sketch.Top(1, []string{"service", "region"})
+		if err != nil {
+			t.Fatalf("Failed to get top groups: %v", err)
+		}
+
+		if len(top) != 1 || top[0].Values["service"] != "checkout" || top[0].Values["region"] != "eu" {
+			t.Errorf("Expected checkout in eu at the top, got %v", top)
+		}
+
+		if relativeError(top[0].Count, 450) > 0.2 {
+			t.Errorf("Expected cardinality close to 450, got %d", top[0].Count)
+		}
+
+		// Subsets can be given in any order
+		top, err = sketch.Top(1, []string{"status", "region"})
+		if err != nil {
+			t.Fatalf("Failed to get top groups: %v", err)
+		}
+
+		if len(top) != 1 || top[0].Values["region"] != "eu" || top[0].Values["status"] != "500" {
+			t.Errorf("Expected eu with status 500 at the top, got %v", top)
+		}
+	})
+
+	t.Run("Cardinality", func(t *testing.T) {
+		sketch := newSketch(t)
+		traffic(t, sketch)
+
+		cardinality, err := sketch.Cardinality(map[string]string{"service": "search"})
+		if err != nil {
+			t.Fatalf("Failed to get cardinality: %v", err)
+		}
+
+		if relativeError(cardinality, 300) > 0.2 {
+			t.Errorf("Expected cardinality close to 300, got %d", cardinality)
+		}
+
+		if _, err := sketch.Cardinality(map[string]string{"status": "200"}); err == nil {
+			t.Error("Expected error for a subset that is not configured")
+		}
+	})
+
+	t.Run("Counter Budget", func(t *testing.T) {
+		sketch := newSketch(t)
+
+		// Many distinct combinations compete for the budget
+		for i := 0; i < 1000; i++ {
+			record := []string{fmt.Sprint("s", i%37), fmt.Sprint("r", i%11), fmt.Sprint(i % 7)}
+			if err := sketch.Insert(record, uint64(i)); err != nil {
+				t.Fatalf("Failed to insert record: %v", err)
+			}
+		}
+
+		total := 0
+		for _, c := range sketch.cuboids {
+			total += len(c.sketch.counters)
+		}
+
+		if total > 30 {
+			t.Errorf("Expected at most 30 counters in total, got %d", total)
+		}
+	})
+
+	t.Run("No Totals Or Subset Sample Per Subset", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(16, []uint64{8, 9})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(1, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		config.TrackTotals = true
+		config.SubsetSampleSize = 1000
+
+		sketch, err := NewGroupBySketch[uint64](config, 30, dimensions, subsets)
+		if err != nil {
+			t.Fatalf("Failed to create group-by sketch: %v", err)
+		}
+
+		// They would cost memory per subset outside the counter budget
+		for _, c := range sketch.cuboids {
+			if c.sketch.items != nil || c.sketch.sample != nil {
+				t.Errorf("Expected no totals or subset sample for subset %v", c.dims)
+			}
+		}
+	})
+
+	t.Run("Concurrent Queries", func(t *testing.T) {
+		sketch := newSketch(t)
+		traffic(t, sketch)
+
+		want, err := sketch.Cardinality(map[string]string{"service": "checkout", "region": "eu"})
+		if err != nil {
+			t.Fatalf("Failed to get cardinality: %v", err)
+		}
+
+		// Run with -race to check that queries do not share state
+		var wg sync.WaitGroup
+		errs := make(chan error, 8)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					got, err := sketch.Cardinality(map[string]string{"service": "checkout", "region": "eu"})
+					if err == nil && got != want {
+						err = fmt.Errorf("expected cardinality %d, got %d", want, got)
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Composite Labels", func(t *testing.T) {
+		sketch := newSketch(t)
+
+		// Values are length prefixed, so separators in values are harmless
+		for _, record := range [][]string{{"a,b", "c", "x"}, {"a", "b,c", "x"}} {
+			if err := sketch.Insert(record, 1); err != nil {
+				t.Fatalf("Failed to insert record: %v", err)
+			}
+		}
+
+		top, err := sketch.Top(10, []string{"service", "region"})
+		if err != nil {
+			t.Fatalf("Failed to get top groups: %v", err)
+		}
+
+		if len(top) != 2 {
+			t.Errorf("Expected 2 distinct groups, got %v", top)
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		sketch1 := newSketch(t)
+		sketch2 := newSketch(t)
+		traffic(t, sketch1)
+		traffic(t, sketch2)
+
+		if err := sketch1.Merge(sketch2); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		cardinality, err := sketch1.Cardinality(map[string]string{"service": "checkout", "region": "eu"})
+		if err != nil {
+			t.Fatalf("Failed to get cardinality: %v", err)
+		}
+
+		if relativeError(cardinality, 450) > 0.2 {
+			t.Errorf("Expected cardinality close to 450 after merge, got %d", cardinality)
+		}
+
+		other, err := NewGroupBySketch[uint64](sketch1.cuboids[0].sketch.config, 30, dimensions, subsets[:2])
+		if err != nil {
+			t.Fatalf("Failed to create group-by sketch: %v", err)
+		}
+
+		if err := sketch1.Merge(other); err == nil {
+			t.Error("Expected error merging sketches with different subsets")
+		}
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		sketch := newSketch(t)
+		config := sketch.cuboids[0].sketch.config
+
+		if err := sketch.Insert([]string{"checkout"}, 1); err == nil {
+			t.Error("Expected error inserting a record with missing dimensions")
+		}
+
+		invalid := map[string][][]string{
+			"no subsets":         {},
+			"unknown dimension":  {{"host"}},
+			"empty subset":       {{}},
+			"duplicate subset":   {{"service", "region"}, {"region", "service"}},
+			"repeated dimension": {{"service", "service"}},
+		}
+
+		for name, subsets := range invalid {
+			if _, err := NewGroupBySketch[uint64](config, 30, dimensions, subsets); err == nil {
+				t.Errorf("Expected error creating a sketch with %s", name)
+			}
+		}
+
+		if _, err := NewGroupBySketch[uint64](config, 2, dimensions, subsets); err == nil {
+			t.Error("Expected error with fewer counters than subsets")
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package ssss
+
+import (
+	"errors"
+	"sort"
+)
+
+// HierarchicalSketch tracks heavy distinct hitters at every level of a label
+// hierarchy at once, such as service/endpoint/method paths or IP prefixes of
+// increasing length. It keeps one SamplingSpaceSavingSets sketch per level.
+type HierarchicalSketch[L comparable, T comparable] struct {
+	config    *Config
+	ancestors func(label L) []L
+	levels    []*SamplingSpaceSavingSets[L, T]
+}
+
+// HierarchicalCount is a hierarchical heavy hitter: a label at a level of the
+// hierarchy with its estimated cardinality, and its discounted cardinality
+// which excludes the items of reported descendants
+type HierarchicalCount[L comparable] struct {
+	Label      L
+	Level      int
+	Count      uint64
+	Discounted uint64
+}
+
+// NewHierarchicalSketch creates a new HierarchicalSketch with numLevels
+// levels. The ancestors function returns the path of a label from the most
+// general ancestor at level 0 down to the label itself; entries past
+// numLevels are ignored. It must be consistent along the path, so that the
+// ancestors of an ancestor are a prefix of the path.
+func NewHierarchicalSketch[L comparable, T comparable](
+	config *Config,
+	numLevels int,
+	ancestors func(label L) []L,
+) (*HierarchicalSketch[L, T], error) {
+	if numLevels <= 0 {
+		return nil, errors.New("number of levels must be greater than zero")
+	}
+
+	if ancestors == nil {
+		return nil, errors.New("ancestors function must not be nil")
+	}
+
+	levels := make([]*SamplingSpaceSavingSets[L, T], numLevels)
+	for i := range levels {
+		levels[i] = NewHLLSamplingSpaceSavingSets[L, T](config)
+	}
+
+	return &HierarchicalSketch[L, T]{
+		config:    config,
+		ancestors: ancestors,
+		levels:    levels,
+	}, nil
+}
+
+// Insert adds an item to the set of a label and of each of its ancestors
+func (h *HierarchicalSketch[L, T]) Insert(label L, item T) {
+	for level, ancestor := range h.path(label) {
+		h.levels[level].Insert(ancestor, item)
+	}
+}
+
+// Merge combines this sketch with another one with the same number of levels
+// and a compatible configuration
+func (h *HierarchicalSketch[L, T]) Merge(other *HierarchicalSketch[L, T]) error {
+	if other == nil || len(other.levels) != len(h.levels) {
+		return &IncompatibleSketchError{Reason: "can only merge with a HierarchicalSketch with the same number of levels"}
+	}
+
+	for i, level := range h.levels {
+		if err := level.Merge(other.levels[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear resets the sketch to its initial state
+func (h *HierarchicalSketch[L, T]) Clear() {
+	for _, level := range h.levels {
+		level.Clear()
+	}
+}
+
+// NumLevels returns the number of levels of the hierarchy
+func (h *HierarchicalSketch[L, T]) NumLevels() int {
+	return len(h.levels)
+}
+
+// Level returns the sketch of a level, or nil if the level does not exist
+func (h *HierarchicalSketch[L, T]) Level(level int) *SamplingSpaceSavingSets[L, T] {
+	if level < 0 || level >= len(h.levels) {
+		return nil
+	}
+	return h.levels[level]
+}
+
+// Cardinality returns the estimated cardinality of a label at a level
+func (h *HierarchicalSketch[L, T]) Cardinality(label L, level int) uint64 {
+	if level < 0 || level >= len(h.levels) {
+		return 0
+	}
+	return h.levels[level].Cardinality(label)
+}
+
+// Top returns the k labels of a level with the highest cardinality
+func (h *HierarchicalSketch[L, T]) Top(k int, level int) []LabelCount[L] {
+	if level < 0 || level >= len(h.levels) {
+		return nil
+	}
+	return h.levels[level].Top(k)
+}
+
+// HeavyHitters returns the hierarchical heavy hitters: tracked labels whose
+// discounted cardinality is at least threshold. Levels are processed from the
+// most specific to the most general, and the discounted cardinality of a
+// label is the estimated number of its items not in the set of any reported
+// descendant, computed with HyperLogLog unions. Results are ordered by level,
+// then by descending discounted cardinality. It fails if the sketch of a
+// label cannot be merged into a union.
+func (h *HierarchicalSketch[L, T]) HeavyHitters(threshold uint64) ([]HierarchicalCount[L], error) {
+	type reported struct {
+		path   []L
+		level  int
+		sketch CardinalitySketch[T]
+	}
+
+	var hitters []HierarchicalCount[L]
+	var descendants []reported
+
+	for level := len(h.levels) - 1; level >= 0; level-- {
+		var found []reported
+
+		for label, counter := range h.levels[level].counters {
+			count := counter.Cardinality()
+			discounted := count
+
+			// Union of the reported descendants of the label, and of the
+			// label itself; the difference is what only the label covers
+			covered := NewHyperLogLog[T](h.config.CardinalitySketchConfig)
+			numCovered := 0
+			for _, d := range descendants {
+				if d.level > level && len(d.path) > level && d.path[level] == label {
+					if err := covered.Merge(d.sketch); err != nil {
+						return nil, err
+					}
+					numCovered++
+				}
+			}
+
+			if numCovered > 0 {
+				coveredCardinality := covered.Cardinality()
+				if err := covered.Merge(counter.sketch); err != nil {
+					return nil, err
+				}
+				discounted = 0
+				if total := covered.Cardinality(); total > coveredCardinality {
+					discounted = total - coveredCardinality
+				}
+			}
+
+			if discounted >= threshold {
+				hitters = append(hitters, HierarchicalCount[L]{
+					Label:      label,
+					Level:      level,
+					Count:      count,
+					Discounted: discounted,
+				})
+				found = append(found, reported{
+					path:   h.path(label),
+					level:  level,
+					sketch: counter.sketch,
+				})
+			}
+		}
+
+		descendants = append(descendants, found...)
+	}
+
+	sort.Slice(hitters, func(i, j int) bool {
+		if hitters[i].Level != hitters[j].Level {
+			return hitters[i].Level < hitters[j].Level
+		}
+		return hitters[i].Discounted > hitters[j].Discounted
+	})
+
+	return hitters, nil
+}
+
+// path returns the ancestors of a label, truncated to the number of levels
+func (h *HierarchicalSketch[L, T]) path(label L) []L {
+	path := h.ancestors(label)
+	if len(path) > len(h.levels) {
+		path = path[:len(h.levels)]
+	}
+	return path
+}
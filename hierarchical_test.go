@@ -0,0 +1,156 @@
+package ssss
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// pathAncestors returns the prefixes of a slash separated path
+func pathAncestors(label string) []string {
+	parts := strings.Split(label, "/")
+	ancestors := make([]string, len(parts))
+	for i := range parts {
+		ancestors[i] = strings.Join(parts[:i+1], "/")
+	}
+	return ancestors
+}
+
+func TestHierarchicalSketch(t *testing.T) {
+	// newSketch creates a three level sketch of service/endpoint/method paths
+	newSketch := func(t *testing.T) *HierarchicalSketch[string, uint64] {
+		t.Helper()
+
+		hllConfig, err := NewHLLConfig(1024, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(50, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch, err := NewHierarchicalSketch[string, uint64](config, 3, pathAncestors)
+		if err != nil {
+			t.Fatalf("Failed to create hierarchical sketch: %v", err)
+		}
+		return sketch
+	}
+
+	// traffic inserts 1000 users of checkout/pay/POST, 20 methods of
+	// checkout/cart with 30 users each, and 50 users of search/query/GET
+	traffic := func(sketch *HierarchicalSketch[string, uint64]) {
+		user := uint64(0)
+		users := func(label string, n int) {
+			for i := 0; i < n; i++ {
+				sketch.Insert(label, user*0x9e3779b97f4a7c15)
+				user++
+			}
+		}
+
+		users("checkout/pay/POST", 1000)
+		for i := 0; i < 20; i++ {
+			users(fmt.Sprintf("checkout/cart/M%d", i), 30)
+		}
+		users("search/query/GET", 50)
+	}
+
+	t.Run("Top Per Level", func(t *testing.T) {
+		sketch := newSketch(t)
+		traffic(sketch)
+
+		if top := sketch.Top(1, 0); len(top) != 1 || top[0].Label != "checkout" {
+			t.Errorf("Expected checkout at the top of level 0, got %v", top)
+		}
+
+		if top := sketch.Top(2, 1); len(top) != 2 || top[0].Label != "checkout/pay" || top[1].Label != "checkout/cart" {
+			t.Errorf("Expected checkout/pay then checkout/cart at level 1, got %v", top)
+		}
+
+		if relativeError(sketch.Cardinality("checkout", 0), 1600) > 0.1 {
+			t.Errorf("Expected checkout cardinality close to 1600, got %d", sketch.Cardinality("checkout", 0))
+		}
+
+		if sketch.Top(1, 3) != nil || sketch.Level(-1) != nil {
+			t.Error("Expected nothing for levels out of range")
+		}
+	})
+
+	t.Run("Discounted Heavy Hitters", func(t *testing.T) {
+		sketch := newSketch(t)
+		traffic(sketch)
+
+		hitters, err := sketch.HeavyHitters(300)
+		if err != nil {
+			t.Fatalf("Failed to find heavy hitters: %v", err)
+		}
+
+		// checkout/pay/POST is heavy on its own. checkout/cart is only heavy
+		// as the sum of its methods. checkout/pay and checkout add nothing
+		// beyond their reported descendants.
+		expected := map[string]uint64{
+			"checkout/cart":     600,
+			"checkout/pay/POST": 1000,
+		}
+
+		if len(hitters) != len(expected) {
+			t.Fatalf("Expected %d heavy hitters, got %v", len(expected), hitters)
+		}
+
+		for _, hitter := range hitters {
+			count, ok := expected[hitter.Label]
+			if !ok {
+				t.Errorf("Unexpected heavy hitter %v", hitter)
+				continue
+			}
+
+			if relativeError(hitter.Discounted, count) > 0.15 {
+				t.Errorf("Expected discounted cardinality close to %d for %s, got %d",
+					count, hitter.Label, hitter.Discounted)
+			}
+
+			if hitter.Level != len(pathAncestors(hitter.Label))-1 {
+				t.Errorf("Expected %s at level %d, got %d",
+					hitter.Label, len(pathAncestors(hitter.Label))-1, hitter.Level)
+			}
+		}
+
+		// Results are ordered by level
+		if hitters[0].Label != "checkout/cart" {
+			t.Errorf("Expected checkout/cart first, got %v", hitters[0])
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		sketch1 := newSketch(t)
+		sketch2 := newSketch(t)
+		traffic(sketch1)
+		traffic(sketch2)
+
+		if err := sketch1.Merge(sketch2); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		// The same users were inserted twice
+		if relativeError(sketch1.Cardinality("checkout", 0), 1600) > 0.1 {
+			t.Errorf("Expected checkout cardinality close to 1600, got %d", sketch1.Cardinality("checkout", 0))
+		}
+
+		if err := sketch1.Merge(nil); err == nil {
+			t.Error("Expected error merging a nil sketch")
+		}
+	})
+
+	t.Run("Invalid Config", func(t *testing.T) {
+		config := newSketch(t).config
+
+		if _, err := NewHierarchicalSketch[string, uint64](config, 0, pathAncestors); err == nil {
+			t.Error("Expected error creating a sketch without levels")
+		}
+
+		if _, err := NewHierarchicalSketch[string, uint64](config, 3, nil); err == nil {
+			t.Error("Expected error creating a sketch without ancestors function")
+		}
+	})
+}
@@ -1,13 +1,11 @@
 package ssss
 
 import (
-	"crypto/rand"
-	"encoding/binary"
-	"errors"
 	"fmt"
 	"hash/fnv"
 	"math"
 	"math/bits"
+	"reflect"
 )
 
 // HLLConfig represents the configuration for a HyperLogLog sketch
@@ -18,53 +16,177 @@ type HLLConfig struct {
 	Alpha float64
 	// Seeds are used for hashing
 	Seeds []uint64
+	// Hasher hashes items into the sketch. If nil, items are hashed with
+	// FNV-1a over their %v formatting mixed with Seeds[1].
+	Hasher Hasher
 }
 
-func secureRandomInt() uint64 {
-	var b [8]byte
-	_, err := rand.Read(b[:])
-	if err != nil {
-		panic(err) // or handle error appropriately
-	}
-	return binary.LittleEndian.Uint64(b[:])
+// Hasher computes the 64-bit hash of an item. The low bits of the hash select
+// the register and the remaining bits determine its value, so sketches can
+// only be merged or exchanged when they use the same Hasher.
+type Hasher interface {
+	Hash(item any) uint64
 }
 
-// NewHLLConfig creates a new HyperLogLog configuration
-func NewHLLConfig(numRegisters int, seeds []uint64) (*HLLConfig, error) {
-	if numRegisters == 0 {
-		return nil, errors.New("number of registers must be greater than zero")
-	}
+// registerHasher is implemented by hashers that reproduce the register values
+// of another system, which differ from the trailing zeros insertHash counts
+// for some hashes. registerValue returns the value of the register selected
+// by the low registerBits bits of a hash, or 0 to leave it unchanged, and
+// maxRegisterValue the largest value registerValue returns.
+type registerHasher interface {
+	registerValue(hash uint64, registerBits uint) uint8
+	maxRegisterValue(registerBits uint) uint8
+}
 
-	if numRegisters&(numRegisters-1) != 0 {
-		return nil, errors.New("number of registers must be a power of 2")
-	}
+// Bounds of the number of registers of a HyperLogLog sketch
+const (
+	minNumRegisters = 16
+	maxNumRegisters = 1 << 21
+)
 
+// NewHLLConfig creates a new HyperLogLog configuration. The number of
+// registers must be a power of 2 from 16 to 2^21. Items are hashed with
+// seeds[1], so at least two seeds are required; nil seeds are generated.
+func NewHLLConfig(numRegisters int, seeds []uint64) (*HLLConfig, error) {
+	return newHLLConfig(numRegisters, seeds, nil)
+}
+
+// newHLLConfig creates and validates a HyperLogLog configuration, generating
+// seeds when they are nil
+func newHLLConfig(numRegisters int, seeds []uint64, hasher Hasher) (*HLLConfig, error) {
 	// If no seeds are provided, generate random ones
 	if seeds == nil {
-		seeds = make([]uint64, 8)
-		for i := range seeds {
-			seeds[i] = secureRandomInt()
+		var err error
+		if seeds, err = randomSeeds(numHLLSeeds); err != nil {
+			return nil, err
 		}
 	}
 
-	// Calculate alpha based on the number of registers
-	var alpha float64
+	config := &HLLConfig{
+		NumRegisters: numRegisters,
+		Alpha:        hllAlpha(numRegisters),
+		Seeds:        seeds,
+		Hasher:       hasher,
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// hllAlpha returns the bias correction factor for a number of registers
+func hllAlpha(numRegisters int) float64 {
 	switch {
 	case numRegisters == 16:
-		alpha = 0.673
+		return 0.673
 	case numRegisters == 32:
-		alpha = 0.697
+		return 0.697
 	case numRegisters == 64:
-		alpha = 0.709
+		return 0.709
 	default:
-		alpha = 0.7213 / (1.0 + 1.079/float64(numRegisters))
+		return 0.7213 / (1.0 + 1.079/float64(numRegisters))
 	}
+}
 
-	return &HLLConfig{
-		NumRegisters: numRegisters,
-		Alpha:        alpha,
-		Seeds:        seeds,
-	}, nil
+// Validate checks that sketches can be built with the configuration: the
+// number of registers is a power of 2 from 16 to 2^21, alpha is a positive
+// number, without a Hasher there are at least two seeds, and a SipHasher has
+// a key. Its errors match ErrInvalidConfig.
+func (c *HLLConfig) Validate() error {
+	return invalidConfig(c.validate())
+}
+
+func (c *HLLConfig) validate() error {
+	if err := c.validateRegisters(); err != nil {
+		return err
+	}
+
+	// A DataSketches hash holds the register index in its low LgK bits
+	if h, ok := c.Hasher.(DataSketchesHasher); ok && (h.LgK < 0 || 1<<uint(h.LgK) != c.NumRegisters) {
+		return fmt.Errorf("DataSketchesHasher LgK must be %d for %d registers, got %d",
+			bits.TrailingZeros(uint(c.NumRegisters)), c.NumRegisters, h.LgK)
+	}
+
+	if c.Hasher == nil && len(c.Seeds) < 2 {
+		return fmt.Errorf("at least 2 seeds are required without a hasher, got %d", len(c.Seeds))
+	}
+
+	// The key is checked last, so decoders can check everything else
+	if c.Hasher == (SipHasher{}) {
+		return errMissingHashKey
+	}
+
+	return nil
+}
+
+// validateRegisters checks the number of registers and alpha, which the
+// hasher of a decoded configuration is derived from
+func (c *HLLConfig) validateRegisters() error {
+	if c.NumRegisters <= 0 {
+		return fmt.Errorf("number of registers must be greater than zero, got %d", c.NumRegisters)
+	}
+
+	if c.NumRegisters&(c.NumRegisters-1) != 0 {
+		return fmt.Errorf("number of registers must be a power of 2, got %d", c.NumRegisters)
+	}
+
+	if c.NumRegisters < minNumRegisters || c.NumRegisters > maxNumRegisters {
+		return fmt.Errorf("number of registers must be between %d and %d, got %d",
+			minNumRegisters, maxNumRegisters, c.NumRegisters)
+	}
+
+	if !(c.Alpha > 0) || math.IsInf(c.Alpha, 0) {
+		return fmt.Errorf("alpha must be a positive number, got %v", c.Alpha)
+	}
+
+	return nil
+}
+
+// CheckCompatible checks that sketches with the other configuration hash and
+// estimate like sketches with this one, so that their registers can be
+// merged. It compares the number of registers, alpha, the seeds and the
+// Hasher, and returns a *ConfigMismatchError naming the first difference.
+func (c *HLLConfig) CheckCompatible(other *HLLConfig) error {
+	switch {
+	case c.NumRegisters != other.NumRegisters:
+		return &ConfigMismatchError{Field: "NumRegisters"}
+	case c.Alpha != other.Alpha:
+		return &ConfigMismatchError{Field: "Alpha"}
+	case !equalSeeds(c.Seeds, other.Seeds):
+		return &ConfigMismatchError{Field: "Seeds"}
+	case !sameHasher(c.Hasher, other.Hasher):
+		return &ConfigMismatchError{Field: "Hasher"}
+	}
+	return nil
+}
+
+// equalSeeds reports whether two seed slices hold the same seeds
+func equalSeeds(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameHasher reports whether two hashers are of the same type and, when that
+// type is comparable, equal
+func sameHasher(a, b Hasher) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+
+	if a == nil || !reflect.TypeOf(a).Comparable() {
+		return true
+	}
+	return a == b
 }
 
 // HyperLogLog implements the CardinalitySketch interface
@@ -95,12 +217,12 @@ func (h *HyperLogLog[T]) Insert(item T) {
 // Merge combines this sketch with another sketch of the same type
 func (h *HyperLogLog[T]) Merge(other CardinalitySketch[T]) error {
 	otherHLL, ok := other.(*HyperLogLog[T])
-	if !ok {
-		return errors.New("can only merge with another HyperLogLog")
+	if !ok || otherHLL == nil {
+		return &IncompatibleSketchError{Reason: "can only merge with another HyperLogLog"}
 	}
 
-	if h.config.NumRegisters != otherHLL.config.NumRegisters {
-		return errors.New("config mismatch: different number of registers")
+	if err := h.config.CheckCompatible(otherHLL.config); err != nil {
+		return err
 	}
 
 	h.numZeroRegisters = 0
@@ -148,6 +270,13 @@ func (h *HyperLogLog[T]) Cardinality() uint64 {
 	return estimate
 }
 
+// clone returns an independent copy of the sketch
+func (h *HyperLogLog[T]) clone() *HyperLogLog[T] {
+	c := *h
+	c.registers = append([]byte(nil), h.registers...)
+	return &c
+}
+
 // linearCounting implements the linear counting algorithm for small cardinalities
 func (h *HyperLogLog[T]) linearCounting() float64 {
 	return float64(
@@ -159,6 +288,10 @@ func (h *HyperLogLog[T]) linearCounting() float64 {
 
 // hashItem hashes an item and returns the hash value
 func (h *HyperLogLog[T]) hashItem(item T) uint64 {
+	if h.config.Hasher != nil {
+		return h.config.Hasher.Hash(item)
+	}
+
 	// Create a hash of the item
 	hasher := fnv.New64a()
 	fmt.Fprintf(hasher, "%v", item)
@@ -170,25 +303,61 @@ func (h *HyperLogLog[T]) hashItem(item T) uint64 {
 	return hash
 }
 
+// maxRegisterValue returns the largest value insertHash stores in a register
+func (c *HLLConfig) maxRegisterValue() uint8 {
+	registerBits := uint(bits.Len(uint(c.NumRegisters - 1)))
+	if hasher, ok := c.Hasher.(registerHasher); ok {
+		return hasher.maxRegisterValue(registerBits)
+	}
+
+	// The sentinel bit above the remaining hash bounds the trailing zeros
+	return uint8(64-registerBits) + 1
+}
+
+// checkRegisters checks decoded registers against the configuration: there
+// must be one per register and none above what insertHash can store, which
+// would skew the estimate of the sketch and of everything merged with it
+func (c *HLLConfig) checkRegisters(registers []byte) error {
+	if len(registers) != c.NumRegisters {
+		return fmt.Errorf("expected %d registers, got %d", c.NumRegisters, len(registers))
+	}
+
+	maxValue := c.maxRegisterValue()
+	for i, value := range registers {
+		if value > maxValue {
+			return fmt.Errorf("register %d is %d, above the maximum of %d", i, value, maxValue)
+		}
+	}
+	return nil
+}
+
 // insertHash processes a hash value and updates the registers
 func (h *HyperLogLog[T]) insertHash(hash uint64) {
 	// Use the first few bits to determine the register index
 	registerBits := uint(bits.Len(uint(h.config.NumRegisters - 1)))
 	registerIdx := hash & ((1 << registerBits) - 1)
 
-	// Count the number of leading zeros in the rest of the hash
-	remainingHash := hash >> registerBits
-	leadingZeros := uint8(bits.LeadingZeros64(remainingHash)) + 1
+	// The register value is the position of the lowest set bit in the rest of
+	// the hash. A sentinel bit bounds it when the remaining bits are all zero.
+	// This matches the register values of Redis. Hashers of other systems
+	// compute their own values.
+	var rank uint8
+	if hasher, ok := h.config.Hasher.(registerHasher); ok {
+		rank = hasher.registerValue(hash, registerBits)
+	} else {
+		remainingHash := hash>>registerBits | 1<<(64-registerBits)
+		rank = uint8(bits.TrailingZeros64(remainingHash)) + 1
+	}
 
-	if h.registers[registerIdx] < leadingZeros {
+	if h.registers[registerIdx] < rank {
 		if h.registers[registerIdx] == 0 {
 			h.numZeroRegisters--
 		}
 
 		// Update zInv by removing the old value and adding the new one
 		h.zInv -= math.Pow(2.0, -float64(h.registers[registerIdx]))
-		h.zInv += math.Pow(2.0, -float64(leadingZeros))
+		h.zInv += math.Pow(2.0, -float64(rank))
 
-		h.registers[registerIdx] = leadingZeros
+		h.registers[registerIdx] = rank
 	}
 }
@@ -0,0 +1,448 @@
+package ssss
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonUint64 is a uint64 encoded as a JSON string, since many JSON parsers
+// read numbers as doubles and lose precision above 2^53. Every uint64 field
+// of the JSON encoding uses it. Numbers are still accepted when decoding.
+type jsonUint64 uint64
+
+// MarshalJSON encodes the value as a decimal string
+func (u jsonUint64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatUint(uint64(u), 10))
+}
+
+// UnmarshalJSON decodes a decimal string or number
+func (u *jsonUint64) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	value, err := strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid unsigned integer %s", data)
+	}
+
+	*u = jsonUint64(value)
+	return nil
+}
+
+// newJSONUint64s converts seeds to their JSON representation
+func newJSONUint64s(values []uint64) []jsonUint64 {
+	if values == nil {
+		return nil
+	}
+
+	j := make([]jsonUint64, len(values))
+	for i, value := range values {
+		j[i] = jsonUint64(value)
+	}
+	return j
+}
+
+// uint64s converts seeds from their JSON representation
+func uint64s(j []jsonUint64) []uint64 {
+	if j == nil {
+		return nil
+	}
+
+	values := make([]uint64, len(j))
+	for i, value := range j {
+		values[i] = uint64(value)
+	}
+	return values
+}
+
+// jsonHLLConfig is the JSON representation of an HLLConfig. The hasher is
+// one of hasherNames and left out for the default.
+type jsonHLLConfig struct {
+	NumRegisters int          `json:"num_registers"`
+	Alpha        float64      `json:"alpha"`
+	Seeds        []jsonUint64 `json:"seeds"`
+	Hasher       string       `json:"hasher,omitempty"`
+}
+
+// jsonHyperLogLog is the JSON representation of a HyperLogLog sketch. The
+// cardinality is informational and ignored when decoding.
+type jsonHyperLogLog struct {
+	Version     jsonUint64     `json:"version"`
+	Config      *jsonHLLConfig `json:"config"`
+	Cardinality jsonUint64     `json:"cardinality"`
+	Registers   []byte         `json:"registers"`
+}
+
+// jsonConfig is the JSON representation of a Config
+type jsonConfig struct {
+	MaxNumCounters          int            `json:"max_num_counters"`
+	Seeds                   []jsonUint64   `json:"seeds"`
+	CardinalitySketchConfig *jsonHLLConfig `json:"cardinality_sketch_config"`
+	TrackTotals             bool           `json:"track_totals,omitempty"`
+	TrackFrequencies        bool           `json:"track_frequencies,omitempty"`
+	SubsetSampleSize        int            `json:"subset_sample_size,omitempty"`
+	KeyedHashing            bool           `json:"keyed_hashing,omitempty"`
+	Fingerprint             string         `json:"fingerprint,omitempty"`
+}
+
+// jsonCounter is the JSON representation of a tracked label. The cardinality
+// is the cached value and is recomputed from the registers when decoding. The
+// event count is only present with Config.TrackFrequencies.
+type jsonCounter struct {
+	Label          string     `json:"label"`
+	Cardinality    jsonUint64 `json:"cardinality"`
+	Registers      []byte     `json:"registers"`
+	Frequency      jsonUint64 `json:"frequency,omitempty"`
+	FrequencyError jsonUint64 `json:"frequency_error,omitempty"`
+}
+
+// jsonSampledPair is the JSON representation of a pair of the subset sample
+type jsonSampledPair struct {
+	Label string     `json:"label"`
+	Hash  jsonUint64 `json:"hash"`
+}
+
+// jsonSubsetSample is the JSON representation of the subset sample, with its
+// pairs in increasing hash order
+type jsonSubsetSample struct {
+	Threshold jsonUint64        `json:"threshold"`
+	Pairs     []jsonSampledPair `json:"pairs"`
+}
+
+// jsonSketch is the JSON representation of a SamplingSpaceSavingSets sketch.
+// The registers of the totals are only present with Config.TrackTotals, and
+// the subset sample with Config.SubsetSampleSize.
+type jsonSketch struct {
+	Version        jsonUint64        `json:"version"`
+	Config         *jsonConfig       `json:"config"`
+	Threshold      jsonUint64        `json:"threshold"`
+	Counters       []jsonCounter     `json:"counters"`
+	TotalItems     []byte            `json:"total_items,omitempty"`
+	DistinctLabels []byte            `json:"distinct_labels,omitempty"`
+	SubsetSample   *jsonSubsetSample `json:"subset_sample,omitempty"`
+}
+
+func newJSONHLLConfig(c *HLLConfig) (*jsonHLLConfig, error) {
+	kind, err := hasherKind(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonHLLConfig{
+		NumRegisters: c.NumRegisters,
+		Alpha:        c.Alpha,
+		Seeds:        newJSONUint64s(c.Seeds),
+		Hasher:       hasherNames[kind],
+	}, nil
+}
+
+func (j *jsonHLLConfig) config() (*HLLConfig, error) {
+	if j == nil {
+		return nil, errors.New("missing HLL config")
+	}
+
+	for kind, name := range hasherNames {
+		if name == j.Hasher {
+			return decodedHLLConfig(j.NumRegisters, j.Alpha, uint64s(j.Seeds), uint64(kind))
+		}
+	}
+	return nil, fmt.Errorf("unknown hasher %q", j.Hasher)
+}
+
+func newJSONConfig(c *Config) (*jsonConfig, error) {
+	hllConfig, err := newJSONHLLConfig(c.CardinalitySketchConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonConfig{
+		MaxNumCounters:          c.MaxNumCounters,
+		Seeds:                   newJSONUint64s(c.Seeds),
+		CardinalitySketchConfig: hllConfig,
+		TrackTotals:             c.TrackTotals,
+		TrackFrequencies:        c.TrackFrequencies,
+		SubsetSampleSize:        c.SubsetSampleSize,
+		KeyedHashing:            c.KeyedHashing,
+		Fingerprint:             strconv.FormatUint(c.Fingerprint(), 16),
+	}, nil
+}
+
+func (j *jsonConfig) config() (*Config, error) {
+	if j == nil {
+		return nil, errors.New("missing config")
+	}
+
+	hllConfig, err := j.CardinalitySketchConfig.config()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		MaxNumCounters:          j.MaxNumCounters,
+		Seeds:                   uint64s(j.Seeds),
+		CardinalitySketchConfig: hllConfig,
+		TrackTotals:             j.TrackTotals,
+		TrackFrequencies:        j.TrackFrequencies,
+		SubsetSampleSize:        j.SubsetSampleSize,
+		KeyedHashing:            j.KeyedHashing,
+	}
+	if err := validateDecoded(config.validate()); err != nil {
+		return nil, err
+	}
+
+	if j.Fingerprint != "" {
+		fingerprint, err := strconv.ParseUint(j.Fingerprint, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fingerprint %q", j.Fingerprint)
+		}
+		if err := config.checkFingerprint(fingerprint); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// MarshalJSON encodes the configuration as JSON
+func (c *HLLConfig) MarshalJSON() ([]byte, error) {
+	j, err := newJSONHLLConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a configuration produced by MarshalJSON
+func (c *HLLConfig) UnmarshalJSON(data []byte) error {
+	var j jsonHLLConfig
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	config, err := j.config()
+	if err != nil {
+		return err
+	}
+
+	*c = *config
+	return nil
+}
+
+// MarshalJSON encodes the sketch as JSON with base64 encoded registers
+func (h *HyperLogLog[T]) MarshalJSON() ([]byte, error) {
+	config, err := newJSONHLLConfig(h.config)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonHyperLogLog{
+		Version:     encodingVersion,
+		Config:      config,
+		Cardinality: jsonUint64(h.Cardinality()),
+		Registers:   h.registers,
+	})
+}
+
+// UnmarshalJSON decodes a sketch produced by MarshalJSON
+func (h *HyperLogLog[T]) UnmarshalJSON(data []byte) error {
+	var j jsonHyperLogLog
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	if err := checkVersion(uint64(j.Version)); err != nil {
+		return err
+	}
+
+	config, err := j.Config.config()
+	if err != nil {
+		return err
+	}
+
+	hll, err := hyperLogLogFromRegisters[T](config, j.Registers)
+	if err != nil {
+		return err
+	}
+
+	*h = *hll
+	return nil
+}
+
+// MarshalJSON encodes the configuration as JSON
+func (c *Config) MarshalJSON() ([]byte, error) {
+	j, err := newJSONConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a configuration produced by MarshalJSON
+func (c *Config) UnmarshalJSON(data []byte) error {
+	var j jsonConfig
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	config, err := j.config()
+	if err != nil {
+		return err
+	}
+
+	*c = *config
+	return nil
+}
+
+// MarshalJSON encodes the sketch as JSON. Counters are listed by descending
+// cached cardinality with their labels in text form, see marshalLabel.
+func (s *SamplingSpaceSavingSets[L, T]) MarshalJSON() ([]byte, error) {
+	config, err := newJSONConfig(s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	j := jsonSketch{
+		Version:   encodingVersion,
+		Config:    config,
+		Threshold: jsonUint64(s.threshold),
+		Counters:  make([]jsonCounter, 0, len(s.counters)),
+	}
+
+	for label, counter := range s.counters {
+		text, err := marshalLabel(label)
+		if err != nil {
+			return nil, err
+		}
+
+		hll, ok := counter.sketch.(*HyperLogLog[T])
+		if !ok {
+			return nil, errors.New("can only encode counters backed by HyperLogLog")
+		}
+
+		j.Counters = append(j.Counters, jsonCounter{
+			Label:          string(text),
+			Cardinality:    jsonUint64(counter.Cardinality()),
+			Registers:      hll.registers,
+			Frequency:      jsonUint64(counter.frequency),
+			FrequencyError: jsonUint64(counter.frequencyError),
+		})
+	}
+
+	sort.Slice(j.Counters, func(a, b int) bool {
+		if j.Counters[a].Cardinality != j.Counters[b].Cardinality {
+			return j.Counters[a].Cardinality > j.Counters[b].Cardinality
+		}
+		return j.Counters[a].Label < j.Counters[b].Label
+	})
+
+	if s.items != nil {
+		j.TotalItems = s.items.registers
+		j.DistinctLabels = s.labels.registers
+	}
+
+	if s.sample != nil {
+		hashes, texts, err := s.sample.entries()
+		if err != nil {
+			return nil, err
+		}
+
+		j.SubsetSample = &jsonSubsetSample{
+			Threshold: jsonUint64(s.sample.threshold),
+			Pairs:     make([]jsonSampledPair, len(hashes)),
+		}
+		for i, hash := range hashes {
+			j.SubsetSample.Pairs[i] = jsonSampledPair{Label: string(texts[i]), Hash: jsonUint64(hash)}
+		}
+	}
+
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a sketch produced by MarshalJSON, replacing the
+// configuration and contents of s. On error s is left unchanged.
+func (s *SamplingSpaceSavingSets[L, T]) UnmarshalJSON(data []byte) error {
+	var j jsonSketch
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	if err := checkVersion(uint64(j.Version)); err != nil {
+		return err
+	}
+
+	config, err := j.Config.config()
+	if err != nil {
+		return err
+	}
+
+	if len(j.Counters) > config.MaxNumCounters {
+		return errors.New("more counters than MaxNumCounters")
+	}
+
+	counters := make(map[L]*CachedSketch[T], len(j.Counters))
+	for _, c := range j.Counters {
+		label, err := unmarshalLabel[L]([]byte(c.Label))
+		if err != nil {
+			return err
+		}
+
+		if _, exists := counters[label]; exists {
+			return fmt.Errorf("duplicate label %q", c.Label)
+		}
+
+		hll, err := hyperLogLogFromRegisters[T](config.CardinalitySketchConfig, c.Registers)
+		if err != nil {
+			return err
+		}
+
+		counter := NewCachedSketch[T](hll)
+		counter.cardinality = hll.Cardinality()
+		if config.TrackFrequencies {
+			counter.frequency = uint64(c.Frequency)
+			counter.frequencyError = uint64(c.FrequencyError)
+		}
+		counters[label] = counter
+	}
+
+	decoded := newSamplingSpaceSavingSets[L, T](config, 0)
+	decoded.counters = counters
+	decoded.updateThreshold()
+
+	if decoded.items != nil {
+		if err := decoded.setTotals(j.TotalItems, j.DistinctLabels); err != nil {
+			return err
+		}
+	}
+
+	if decoded.sample != nil {
+		if j.SubsetSample == nil {
+			return errors.New("missing subset sample")
+		}
+
+		hashes := make([]uint64, len(j.SubsetSample.Pairs))
+		texts := make([][]byte, len(j.SubsetSample.Pairs))
+		for i, pair := range j.SubsetSample.Pairs {
+			hashes[i] = uint64(pair.Hash)
+			texts[i] = []byte(pair.Label)
+		}
+
+		if err := decoded.sample.set(uint64(j.SubsetSample.Threshold), hashes, texts); err != nil {
+			return err
+		}
+	}
+
+	*s = *decoded
+	return nil
+}
@@ -0,0 +1,302 @@
+package ssss
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestJSONEncoding(t *testing.T) {
+	t.Run("Config Round Trip", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(64, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(10, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		data, err := json.Marshal(config)
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+
+		var decoded Config
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal config: %v", err)
+		}
+
+		if decoded.MaxNumCounters != 10 || len(decoded.Seeds) != 4 ||
+			decoded.CardinalitySketchConfig.NumRegisters != 64 ||
+			decoded.CardinalitySketchConfig.Alpha != hllConfig.Alpha {
+			t.Errorf("Config differs after round trip: %s", data)
+		}
+	})
+
+	t.Run("HyperLogLog Registers Are Base64", func(t *testing.T) {
+		config, err := NewHLLConfig(16, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		hll := NewHyperLogLog[uint64](config)
+		for i := uint64(0); i < 10; i++ {
+			hll.Insert(i)
+		}
+
+		data, err := json.Marshal(hll)
+		if err != nil {
+			t.Fatalf("Failed to marshal HLL: %v", err)
+		}
+
+		if !strings.Contains(string(data), base64.StdEncoding.EncodeToString(hll.registers)) {
+			t.Errorf("Expected base64 registers in %s", data)
+		}
+
+		var decoded HyperLogLog[uint64]
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal HLL: %v", err)
+		}
+
+		if !bytes.Equal(decoded.registers, hll.registers) || decoded.Cardinality() != hll.Cardinality() {
+			t.Error("HLL differs after round trip")
+		}
+	})
+
+	t.Run("Sketch Is Human Readable", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(64, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(3, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[netip.Addr, uint64](config)
+		addrs := []string{"10.0.0.1", "10.0.0.2", "2001:db8::1", "192.168.1.1"}
+		for i, addr := range addrs {
+			for j := uint64(0); j < uint64(i+1)*20; j++ {
+				sketch.Insert(netip.MustParseAddr(addr), j)
+			}
+		}
+
+		data, err := json.Marshal(sketch)
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		// Unsigned 64-bit values are strings, which fail to decode as numbers
+		var raw struct {
+			Version   string `json:"version"`
+			Threshold string `json:"threshold"`
+			Counters  []struct {
+				Label       string `json:"label"`
+				Cardinality string `json:"cardinality"`
+			} `json:"counters"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatalf("Failed to inspect JSON: %v", err)
+		}
+
+		if raw.Version != strconv.Itoa(encodingVersion) {
+			t.Errorf("Expected version %d in JSON, got %s", encodingVersion, raw.Version)
+		}
+
+		if raw.Threshold != strconv.FormatUint(sketch.threshold, 10) {
+			t.Errorf("Expected threshold %d in JSON, got %s", sketch.threshold, raw.Threshold)
+		}
+
+		// Labels use their text form and counters are ordered by cardinality
+		top := sketch.Top(3)
+		if len(raw.Counters) != len(top) {
+			t.Fatalf("Expected %d counters in JSON, got %d", len(top), len(raw.Counters))
+		}
+		var previous uint64
+		for i, entry := range raw.Counters {
+			cardinality, err := strconv.ParseUint(entry.Cardinality, 10, 64)
+			if err != nil {
+				t.Fatalf("Expected cardinality as a decimal string, got %q", entry.Cardinality)
+			}
+
+			if i > 0 && cardinality > previous {
+				t.Errorf("Counters are not ordered by cardinality: %s", data)
+			}
+			previous = cardinality
+
+			addr, err := netip.ParseAddr(entry.Label)
+			if err != nil {
+				t.Fatalf("Expected label in text form, got %q", entry.Label)
+			}
+
+			if sketch.Cardinality(addr) != cardinality {
+				t.Errorf("Expected cardinality %d for %s, got %d",
+					sketch.Cardinality(addr), entry.Label, cardinality)
+			}
+		}
+
+		decoded := new(SamplingSpaceSavingSets[netip.Addr, uint64])
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatalf("Failed to unmarshal sketch: %v", err)
+		}
+
+		for _, entry := range top {
+			if decoded.Cardinality(entry.Label) != entry.Count {
+				t.Errorf("Expected cardinality %d for %v, got %d",
+					entry.Count, entry.Label, decoded.Cardinality(entry.Label))
+			}
+		}
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		inputs := []string{
+			`{}`,
+			`{"version":2,"config":{"max_num_counters":0,"cardinality_sketch_config":{"num_registers":16}}}`,
+			`{"version":2,"config":{"max_num_counters":2,"cardinality_sketch_config":{"num_registers":15}}}`,
+			`{"version":2,"config":{"max_num_counters":2,"cardinality_sketch_config":{"num_registers":16}},` +
+				`"counters":[{"label":"a","registers":"AAAA"}]}`,
+			`{"version":2,"config":{"max_num_counters":2,"cardinality_sketch_config":{"num_registers":4}},` +
+				`"counters":[{"label":"x","registers":"AAAAAA=="}]}`,
+			`{"version":4,"config":{"max_num_counters":2,"cardinality_sketch_config":` +
+				`{"num_registers":16,"alpha":0.673,"seeds":["1","2"],"hasher":"md5"}},"counters":[]}`,
+			// Without a hasher, items are hashed with the second seed
+			`{"version":4,"config":{"max_num_counters":2,"cardinality_sketch_config":` +
+				`{"num_registers":16,"alpha":0.673,"seeds":["1"]}},"counters":[]}`,
+			// Registers of version 1 used another rank
+			`{"config":{"max_num_counters":2,"cardinality_sketch_config":` +
+				`{"num_registers":16,"alpha":0.673,"seeds":[1,2]}},"counters":[]}`,
+		}
+
+		for _, input := range inputs {
+			decoded := new(SamplingSpaceSavingSets[int, uint64])
+			if err := json.Unmarshal([]byte(input), decoded); err == nil {
+				t.Errorf("Expected error decoding %s", input)
+			}
+		}
+	})
+
+	t.Run("Large Values Are Strings", func(t *testing.T) {
+		// Above 2^53, JSON numbers lose precision in parsers that use doubles
+		seed := uint64(1)<<63 + 1
+		hllConfig, err := NewHLLConfig(16, []uint64{seed, seed + 2})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(2, hllConfig, []uint64{seed, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		config.SubsetSampleSize = 10
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		for i := uint64(0); i < 100; i++ {
+			sketch.Insert("a", i)
+		}
+
+		data, err := json.Marshal(sketch)
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		var raw struct {
+			Config struct {
+				Seeds []json.RawMessage `json:"seeds"`
+			} `json:"config"`
+			SubsetSample struct {
+				Threshold json.RawMessage `json:"threshold"`
+				Pairs     []struct {
+					Hash json.RawMessage `json:"hash"`
+				} `json:"pairs"`
+			} `json:"subset_sample"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatalf("Failed to unmarshal raw JSON: %v", err)
+		}
+
+		if got := string(raw.Config.Seeds[0]); got != `"9223372036854775809"` {
+			t.Errorf("Expected seed as a string, got %s", got)
+		}
+
+		values := []json.RawMessage{raw.SubsetSample.Threshold}
+		for _, pair := range raw.SubsetSample.Pairs {
+			values = append(values, pair.Hash)
+		}
+		for _, value := range values {
+			if !strings.HasPrefix(string(value), `"`) {
+				t.Errorf("Expected sample hash as a string, got %s", value)
+			}
+		}
+
+		decoded := new(SamplingSpaceSavingSets[string, uint64])
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatalf("Failed to unmarshal sketch: %v", err)
+		}
+
+		if decoded.config.Seeds[0] != seed || decoded.config.CardinalitySketchConfig.Seeds[1] != seed+2 {
+			t.Errorf("Seeds changed after round trip: %v", decoded.config.Seeds)
+		}
+
+		want, _ := sketch.EstimateSubset(func(string) bool { return true })
+		got, err := decoded.EstimateSubset(func(string) bool { return true })
+		if err != nil {
+			t.Fatalf("Failed to estimate subset: %v", err)
+		}
+
+		if got != want {
+			t.Errorf("Subset estimate after round trip is %+v, expected %+v", got, want)
+		}
+
+		// Earlier versions wrote numbers
+		var legacy Config
+		if err := json.Unmarshal([]byte(`{"max_num_counters":2,"seeds":[1,2],`+
+			`"cardinality_sketch_config":{"num_registers":16,"alpha":0.673,"seeds":[3,4]}}`), &legacy); err != nil {
+			t.Fatalf("Failed to unmarshal config with numeric seeds: %v", err)
+		}
+
+		if legacy.Seeds[1] != 2 || legacy.CardinalitySketchConfig.Seeds[1] != 4 {
+			t.Errorf("Unexpected seeds %v and %v", legacy.Seeds, legacy.CardinalitySketchConfig.Seeds)
+		}
+	})
+
+	t.Run("Failed Decoding Leaves Sketch Unchanged", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(16, []uint64{8, 9})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(2, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		config.TrackTotals = true
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		sketch.Insert("a", 1)
+
+		data, err := json.Marshal(sketch)
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		// The counters decode, but the totals have the wrong size
+		corrupt := strings.Replace(string(data), `"total_items":"`, `"total_items":"AAAA`, 1)
+
+		decoded := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		decoded.Insert("b", 1)
+		if err := json.Unmarshal([]byte(corrupt), decoded); err == nil {
+			t.Fatal("Expected error for totals of the wrong size")
+		}
+
+		if top := decoded.Top(2); len(top) != 1 || top[0].Label != "b" {
+			t.Errorf("Expected the sketch to be unchanged after a failed decode, got %v", top)
+		}
+	})
+}
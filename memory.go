@@ -0,0 +1,161 @@
+package ssss
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unsafe"
+)
+
+// Memory model of a sketch. Map entries are stored in groups of 8 slots with a
+// byte of hash each behind a header of 48 bytes, and every entry is charged
+// for the slots of a map that is 6.5/8 full, the load factor of the bucket
+// maps before Go 1.24. The Swiss tables of Go 1.24 and later fill groups up
+// to 7/8 and grow in steps of their own, so there the model is an upper
+// estimate of a steady state rather than the size of a given map.
+const (
+	mapHeaderBytes = 48
+	pointerBytes   = int(unsafe.Sizeof(uintptr(0)))
+)
+
+// NewConfigForBudget creates a configuration for a sketch that fits in about
+// budgetBytes of memory and estimates the cardinality of each label with a
+// standard error of at most relativeError. The number of registers is the
+// smallest power of 2 with a standard error of 1.04/sqrt(registers) within
+// relativeError. A subset sample of subsetSampleSize pairs is set aside
+// next, when it is not 0, and the rest of the budget goes to as many counters
+// as fit.
+//
+// labelBytes is the average memory of a label: its size, plus the bytes of
+// the text for strings. Seeds are generated when nil, as with NewConfig and
+// NewHLLConfig. Setting TrackTotals afterwards adds two more sketches.
+func NewConfigForBudget(
+	budgetBytes int,
+	relativeError float64,
+	labelBytes int,
+	subsetSampleSize int,
+	seeds []uint64,
+	hllSeeds []uint64,
+) (*Config, error) {
+	if relativeError <= 0 || relativeError >= 1 {
+		return nil, errors.New("relative error must be between 0 and 1")
+	}
+
+	if labelBytes < 0 {
+		return nil, errors.New("label bytes must not be negative")
+	}
+
+	if subsetSampleSize < 0 {
+		return nil, errors.New("subset sample size must not be negative")
+	}
+
+	numRegisters := minNumRegisters
+	for 1.04/math.Sqrt(float64(numRegisters)) > relativeError {
+		if numRegisters >= maxNumRegisters {
+			return nil, fmt.Errorf("relative error %v is too small for the maximum precision of %d registers",
+				relativeError, maxNumRegisters)
+		}
+		numRegisters *= 2
+	}
+
+	perCounter := mapEntryBytes(labelBytes, pointerBytes) + candidateBytes(labelBytes) + counterBytes(numRegisters)
+	available := budgetBytes - sketchBytes()
+	if subsetSampleSize > 0 {
+		available -= sampleBytes(subsetSampleSize, labelBytes)
+	}
+
+	numCounters := available / perCounter
+	if numCounters < 1 {
+		return nil, fmt.Errorf("budget of %d bytes is too small for a counter of %d bytes", budgetBytes, perCounter)
+	}
+
+	hllConfig, err := NewHLLConfig(numRegisters, hllSeeds)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := NewConfig(numCounters, hllConfig, seeds)
+	if err != nil {
+		return nil, err
+	}
+
+	config.SubsetSampleSize = subsetSampleSize
+	return config, nil
+}
+
+// SizeInBytes estimates the memory used by the sketch: its counters with
+// their registers and their slot in the heap of eviction candidates, its
+// labels, the totals, the subset sample and the overhead of its maps. It
+// follows the memory model above and is not a measurement of the heap, which
+// depends on the Go version and on how the maps have grown.
+func (s *SamplingSpaceSavingSets[L, T]) SizeInBytes() int {
+	var zero L
+	labelSize := int(unsafe.Sizeof(zero))
+	numRegisters := s.config.CardinalitySketchConfig.NumRegisters
+
+	size := sketchBytes()
+	for label := range s.counters {
+		size += mapEntryBytes(labelSize, pointerBytes) + candidateBytes(labelSize) + counterBytes(numRegisters) + labelDataBytes(label)
+	}
+
+	// Pinned labels share the text of their counters
+	size += mapHeaderBytes + mapEntryBytes(labelSize, 0)*len(s.pinned)
+
+	if s.items != nil {
+		size += 2 * hllBytes(numRegisters)
+	}
+
+	if s.sample != nil {
+		size += sampleBytes(len(s.sample.pairs), labelSize)
+		for _, label := range s.sample.pairs {
+			size += labelDataBytes(label)
+		}
+	}
+
+	return size
+}
+
+// sketchBytes is the memory of an empty sketch and its map of counters
+func sketchBytes() int {
+	return int(unsafe.Sizeof(SamplingSpaceSavingSets[struct{}, struct{}]{})) + mapHeaderBytes
+}
+
+// counterBytes is the memory of a counter backed by a HyperLogLog sketch
+func counterBytes(numRegisters int) int {
+	return int(unsafe.Sizeof(CachedSketch[struct{}]{})) + hllBytes(numRegisters)
+}
+
+// candidateBytes is the memory of a counter in the heap of eviction
+// candidates: its label, a pointer and the admission it was taken over at
+func candidateBytes(labelBytes int) int {
+	return labelBytes + pointerBytes + 8
+}
+
+// sampleBytes is the memory of a subset sample holding numPairs pairs
+func sampleBytes(numPairs int, labelBytes int) int {
+	return int(unsafe.Sizeof(pairSample[struct{}]{})) + mapHeaderBytes + numPairs*pairBytes(labelBytes)
+}
+
+// pairBytes is the memory of a sampled pair: a map entry from its hash to its
+// label, and its hash in the heap of sampled hashes
+func pairBytes(labelBytes int) int {
+	return mapEntryBytes(8, labelBytes) + 8
+}
+
+// hllBytes is the memory of a HyperLogLog sketch and its registers
+func hllBytes(numRegisters int) int {
+	return int(unsafe.Sizeof(HyperLogLog[struct{}]{})) + numRegisters
+}
+
+// mapEntryBytes is the share of map buckets used by one entry
+func mapEntryBytes(keyBytes, valueBytes int) int {
+	return (keyBytes + valueBytes + 1) * 16 / 13
+}
+
+// labelDataBytes is the memory a label points to outside of the map
+func labelDataBytes(label any) int {
+	if text, ok := label.(string); ok {
+		return len(text)
+	}
+	return 0
+}
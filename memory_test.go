@@ -0,0 +1,231 @@
+package ssss
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	// Labels are 16 byte strings, taking 32 bytes with their header
+	const labelBytes = 32
+	label := func(i int) string {
+		return fmt.Sprintf("label-%010d", i)
+	}
+
+	t.Run("Accuracy And Memory Report", func(t *testing.T) {
+		t.Logf("%10s %6s %9s %8s %10s %8s", "budget", "error", "registers", "counters", "size", "measured")
+
+		for _, budget := range []int{64 << 10, 1 << 20, 16 << 20} {
+			for _, target := range []float64{0.05, 0.02, 0.01} {
+				config, err := NewConfigForBudget(budget, target, labelBytes, 0, nil, nil)
+				if err != nil {
+					t.Fatalf("Failed to create config for %d bytes: %v", budget, err)
+				}
+
+				// The register count is the smallest one reaching the error
+				numRegisters := float64(config.CardinalitySketchConfig.NumRegisters)
+				if 1.04/math.Sqrt(numRegisters) > target || 1.04/math.Sqrt(numRegisters/2) <= target {
+					t.Errorf("Register count %v is not the smallest with error %v", numRegisters, target)
+				}
+
+				// Fill every counter, the first one with a known set
+				sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+				const numItems = 20000
+				for i := uint64(0); i < numItems; i++ {
+					sketch.Insert(label(0), i*0x9e3779b97f4a7c15)
+				}
+				for i := 1; i < config.MaxNumCounters; i++ {
+					sketch.Insert(label(i), uint64(i))
+				}
+
+				size := sketch.SizeInBytes()
+				if size > budget {
+					t.Errorf("Sketch of %d bytes exceeds the budget of %d bytes", size, budget)
+				}
+
+				// The budget is used up to a counter, and to the map slots
+				// that the label text doesn't need
+				perCounter := mapEntryBytes(labelBytes, pointerBytes) + candidateBytes(labelBytes) + counterBytes(int(numRegisters))
+				if budget-size >= perCounter && budget-size > budget/20 {
+					t.Errorf("Budget of %d bytes leaves %d bytes unused", budget, budget-size)
+				}
+
+				measured := relativeError(sketch.Cardinality(label(0)), numItems)
+				if measured > 3*target {
+					t.Errorf("Error %.4f exceeds 3 standard errors of %.4f", measured, target)
+				}
+
+				t.Logf("%10d %6.2f %9.0f %8d %10d %8.4f",
+					budget, target, numRegisters, config.MaxNumCounters, size, measured)
+			}
+		}
+	})
+
+	t.Run("Heap Within Budget", func(t *testing.T) {
+		const budget = 4 << 20
+		config, err := NewConfigForBudget(budget, 0.02, labelBytes, 0, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		for i := 0; i < config.MaxNumCounters; i++ {
+			sketch.Insert(label(i), uint64(i))
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(sketch)
+
+		// The model is not a measurement, so the heap may differ from the
+		// budget by how the maps have grown on this Go version, but not by
+		// more than a quarter of it
+		heap := float64(after.HeapAlloc) - float64(before.HeapAlloc)
+		t.Logf("budget of %d bytes, estimated %d bytes, measured %d bytes on the heap",
+			budget, sketch.SizeInBytes(), int(heap))
+
+		if math.Abs(heap-budget)/budget > 0.25 {
+			t.Errorf("Heap growth of %d bytes is more than 25%% off the budget of %d bytes", int(heap), budget)
+		}
+	})
+
+	t.Run("Map Entries", func(t *testing.T) {
+		// Entries are charged for a map that is 6.5/8 full, with a byte of
+		// hash per slot
+		tests := []struct {
+			keyBytes, valueBytes, expected int
+		}{
+			{0, 0, 1},
+			{8, 0, 11},
+			{8, 8, 20},
+			{16, 8, 30},
+			{32, 8, 50},
+		}
+		for _, test := range tests {
+			if got := mapEntryBytes(test.keyBytes, test.valueBytes); got != test.expected {
+				t.Errorf("Expected %d bytes for a %d byte key and a %d byte value, got %d",
+					test.expected, test.keyBytes, test.valueBytes, got)
+			}
+		}
+	})
+
+	t.Run("Size Of Known Sketch", func(t *testing.T) {
+		if pointerBytes != 8 {
+			t.Skip("Expected sizes are for 64-bit platforms")
+		}
+
+		hllConfig, err := NewHLLConfig(64, []uint64{1, 2})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+		config, err := NewConfig(4, hllConfig, []uint64{1, 2})
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[uint64, uint64](config)
+		if size := sketch.SizeInBytes(); size != 208 {
+			t.Errorf("Expected 208 bytes for an empty sketch, got %d", size)
+		}
+
+		// Each counter adds a map entry of 20 bytes, a heap slot of 24 bytes
+		// and 160 bytes of counter and HyperLogLog sketch with 64 registers
+		for i := uint64(0); i < 4; i++ {
+			sketch.Insert(i, i)
+		}
+		if size := sketch.SizeInBytes(); size != 1024 {
+			t.Errorf("Expected 1024 bytes for 4 counters, got %d", size)
+		}
+
+		// String labels add their text
+		texts := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		texts.Insert("0123456789", 1)
+		if size := texts.SizeInBytes(); size != 208+30+32+160+10 {
+			t.Errorf("Expected %d bytes for a string label, got %d", 208+30+32+160+10, size)
+		}
+	})
+
+	t.Run("Size Of Empty And Totals", func(t *testing.T) {
+		config, err := NewConfigForBudget(1<<20, 0.05, labelBytes, 0, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		empty := NewHLLSamplingSpaceSavingSets[string, uint64](config).SizeInBytes()
+
+		config.TrackTotals = true
+		totals := NewHLLSamplingSpaceSavingSets[string, uint64](config).SizeInBytes()
+
+		if want := empty + 2*hllBytes(config.CardinalitySketchConfig.NumRegisters); totals != want {
+			t.Errorf("Expected totals to add %d bytes, got %d", want-empty, totals-empty)
+		}
+	})
+
+	t.Run("Subset Sample Within Budget", func(t *testing.T) {
+		const budget = 1 << 20
+		plain, err := NewConfigForBudget(budget, 0.05, labelBytes, 0, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		config, err := NewConfigForBudget(budget, 0.05, labelBytes, 4096, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		if config.SubsetSampleSize != 4096 {
+			t.Errorf("Expected a subset sample of 4096 pairs, got %d", config.SubsetSampleSize)
+		}
+
+		if config.MaxNumCounters >= plain.MaxNumCounters {
+			t.Errorf("Expected the sample to leave fewer than %d counters, got %d", plain.MaxNumCounters, config.MaxNumCounters)
+		}
+
+		// Fill every counter and the sample
+		sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		for i := 0; i < config.MaxNumCounters; i++ {
+			for j := uint64(0); j < 100; j++ {
+				sketch.Insert(label(i), j)
+			}
+		}
+
+		if n := len(sketch.sample.pairs); n != config.SubsetSampleSize {
+			t.Fatalf("Expected a full sample of %d pairs, got %d", config.SubsetSampleSize, n)
+		}
+
+		if size := sketch.SizeInBytes(); size > budget {
+			t.Errorf("Sketch of %d bytes with a full sample exceeds the budget of %d bytes", size, budget)
+		}
+	})
+
+	t.Run("Invalid Budgets", func(t *testing.T) {
+		if _, err := NewConfigForBudget(1024, 0.01, labelBytes, 0, nil, nil); err == nil {
+			t.Error("Expected error for a budget smaller than a counter")
+		}
+
+		for _, relativeError := range []float64{0, -0.1, 1} {
+			if _, err := NewConfigForBudget(1<<20, relativeError, labelBytes, 0, nil, nil); err == nil {
+				t.Errorf("Expected error for relative error %v", relativeError)
+			}
+		}
+
+		// 1.04/sqrt(2^21) is about 0.0007, the best the registers reach
+		if _, err := NewConfigForBudget(1<<20, 1e-12, labelBytes, 0, nil, nil); err == nil {
+			t.Error("Expected error for a relative error below the maximum precision")
+		}
+
+		if _, err := NewConfigForBudget(1<<20, 0.01, -1, 0, nil, nil); err == nil {
+			t.Error("Expected error for negative label bytes")
+		}
+
+		if _, err := NewConfigForBudget(1<<20, 0.01, labelBytes, -1, nil, nil); err == nil {
+			t.Error("Expected error for a negative subset sample size")
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package ssss
+
+import (
+	"bytes"
+	"testing"
+	"testing/quick"
+)
+
+func TestMergeProperties(t *testing.T) {
+	hllConfig, err := NewHLLConfig(64, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+	if err != nil {
+		t.Fatalf("Failed to create HLL config: %v", err)
+	}
+
+	newHLL := func(items []uint16) *HyperLogLog[uint16] {
+		hll := NewHyperLogLog[uint16](hllConfig)
+		for _, item := range items {
+			hll.Insert(item)
+		}
+		return hll
+	}
+
+	// merged returns the union of copies of the sketches, merged left to right
+	merged := func(sketches ...*HyperLogLog[uint16]) *HyperLogLog[uint16] {
+		union := NewHyperLogLog[uint16](hllConfig)
+		for _, sketch := range sketches {
+			if err := union.Merge(sketch); err != nil {
+				t.Fatalf("Failed to merge HLLs: %v", err)
+			}
+		}
+		return union
+	}
+
+	sameHLL := func(a, b *HyperLogLog[uint16]) bool {
+		return bytes.Equal(a.registers, b.registers) && a.Cardinality() == b.Cardinality()
+	}
+
+	t.Run("HyperLogLog Merge Is Commutative", func(t *testing.T) {
+		property := func(a, b []uint16) bool {
+			return sameHLL(merged(newHLL(a), newHLL(b)), merged(newHLL(b), newHLL(a)))
+		}
+		if err := quick.Check(property, nil); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("HyperLogLog Merge Is Associative", func(t *testing.T) {
+		property := func(a, b, c []uint16) bool {
+			left := merged(merged(newHLL(a), newHLL(b)), newHLL(c))
+			right := merged(newHLL(a), merged(newHLL(b), newHLL(c)))
+			return sameHLL(left, right)
+		}
+		if err := quick.Check(property, nil); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("HyperLogLog Merge Is Idempotent", func(t *testing.T) {
+		property := func(a []uint16) bool {
+			hll := newHLL(a)
+			if err := hll.Merge(newHLL(a)); err != nil {
+				return false
+			}
+			return sameHLL(hll, newHLL(a))
+		}
+		if err := quick.Check(property, nil); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("HyperLogLog Merge Is The Union", func(t *testing.T) {
+		property := func(a, b []uint16) bool {
+			return sameHLL(merged(newHLL(a), newHLL(b)), newHLL(append(a, b...)))
+		}
+		if err := quick.Check(property, nil); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// Events are packed in uint16 values: the top 4 bits are the label and
+	// the rest is the item, so streams have up to 16 labels
+	newSketch := func(t *testing.T, maxNumCounters uint8, events []uint16) *SamplingSpaceSavingSets[uint16, uint16] {
+		config, err := NewConfig(int(maxNumCounters%8)+1, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[uint16, uint16](config)
+		for _, e := range events {
+			sketch.Insert(e>>12, e&0xfff)
+		}
+		return sketch
+	}
+
+	// registers returns copies of the registers of the counters
+	registers := func(sketch *SamplingSpaceSavingSets[uint16, uint16]) map[uint16][]byte {
+		snapshot := make(map[uint16][]byte, len(sketch.counters))
+		for label, counter := range sketch.counters {
+			snapshot[label] = append([]byte(nil), counter.sketch.(*HyperLogLog[uint16]).registers...)
+		}
+		return snapshot
+	}
+
+	sameRegisters := func(a, b map[uint16][]byte) bool {
+		if len(a) != len(b) {
+			return false
+		}
+
+		for label, registers := range a {
+			if !bytes.Equal(registers, b[label]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Run("Sampling Merge Keeps Its Invariants", func(t *testing.T) {
+		property := func(capacity uint8, a, b []uint16) bool {
+			sketch := newSketch(t, capacity, a)
+			if err := sketch.Merge(newSketch(t, capacity, b)); err != nil {
+				return false
+			}
+
+			if len(sketch.counters) > sketch.config.MaxNumCounters {
+				return false
+			}
+
+			// The threshold is the minimum cardinality of the counters, or 0
+			// without counters
+			var threshold uint64
+			if _, minCardinality := sketch.minCounter(); len(sketch.counters) > 0 {
+				threshold = minCardinality
+			}
+			return sketch.threshold == threshold
+		}
+		if err := quick.Check(property, nil); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Merging An Empty Sketch Is The Identity", func(t *testing.T) {
+		// Ties between minimum counters depend on map order, so sketches
+		// are compared with themselves rather than rebuilt from the stream
+		property := func(capacity uint8, a []uint16) bool {
+			sketch := newSketch(t, capacity, a)
+			original := registers(sketch)
+			if err := sketch.Merge(newSketch(t, capacity, nil)); err != nil {
+				return false
+			}
+
+			empty := newSketch(t, capacity, nil)
+			if err := empty.Merge(sketch); err != nil {
+				return false
+			}
+
+			return sameRegisters(registers(sketch), original) && sameRegisters(registers(empty), original)
+		}
+		if err := quick.Check(property, nil); err != nil {
+			t.Error(err)
+		}
+	})
+}
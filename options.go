@@ -0,0 +1,155 @@
+package ssss
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Default and bounds of the precision, the base 2 logarithm of the number of
+// registers of the cardinality sketches
+const (
+	defaultPrecision = 10
+	minPrecision     = 4
+	maxPrecision     = 21
+)
+
+// Option sets a parameter of a configuration created by NewConfigWithOptions
+type Option func(*options)
+
+// options collects the parameters set by options
+type options struct {
+	seeds            []uint64
+	hllSeeds         []uint64
+	precision        int
+	hasher           Hasher
+	trackTotals      bool
+	trackFrequencies bool
+	subsetSampleSize int
+	admissionPolicy  AdmissionPolicy
+	keyedHashing     bool
+	hashKey          [2]uint64
+}
+
+// WithSeeds sets the seeds of the sampling estimate
+func WithSeeds(seeds []uint64) Option {
+	return func(o *options) {
+		o.seeds = seeds
+	}
+}
+
+// WithHLLSeeds sets the seeds of the cardinality sketches
+func WithHLLSeeds(seeds []uint64) Option {
+	return func(o *options) {
+		o.hllSeeds = seeds
+	}
+}
+
+// WithMasterSeed derives the sampling and HyperLogLog seeds from a master
+// seed, like NewSeededConfig
+func WithMasterSeed(master string) Option {
+	return func(o *options) {
+		o.seeds = DeriveSeeds(master+"/sampling", numSeeds)
+		o.hllSeeds = DeriveSeeds(master+"/hll", numHLLSeeds)
+	}
+}
+
+// WithPrecision sets the number of registers of the cardinality sketches to
+// 2^precision, from 2^4 to 2^21. The default is 2^10.
+func WithPrecision(precision int) Option {
+	return func(o *options) {
+		o.precision = precision
+	}
+}
+
+// WithHasher sets the Hasher of the cardinality sketches. Without HLL seeds,
+// which a Hasher doesn't use, the seeds are left empty.
+func WithHasher(hasher Hasher) Option {
+	return func(o *options) {
+		o.hasher = hasher
+	}
+}
+
+// WithTotals sets Config.TrackTotals
+func WithTotals() Option {
+	return func(o *options) {
+		o.trackTotals = true
+	}
+}
+
+// WithFrequencies sets Config.TrackFrequencies
+func WithFrequencies() Option {
+	return func(o *options) {
+		o.trackFrequencies = true
+	}
+}
+
+// WithSubsetSample sets Config.SubsetSampleSize
+func WithSubsetSample(size int) Option {
+	return func(o *options) {
+		o.subsetSampleSize = size
+	}
+}
+
+// WithAdmissionPolicy sets Config.AdmissionPolicy
+func WithAdmissionPolicy(policy AdmissionPolicy) Option {
+	return func(o *options) {
+		o.admissionPolicy = policy
+	}
+}
+
+// WithKeyedHashing sets Config.KeyedHashing with the secret Config.HashKey
+// and keys the cardinality sketches with a SipHasher with the same key. The
+// key must not be zero and is never encoded, so sketches that are merged must
+// share it and decoded sketches need it again, see SetHashKey. It cannot be
+// combined with WithHasher.
+func WithKeyedHashing(key0, key1 uint64) Option {
+	return func(o *options) {
+		o.keyedHashing = true
+		o.hashKey = [2]uint64{key0, key1}
+	}
+}
+
+// NewConfigWithOptions creates a validated configuration with maxNumCounters
+// counters. Without options, the cardinality sketches have 2^10 registers
+// and the seeds are random.
+func NewConfigWithOptions(maxNumCounters int, opts ...Option) (*Config, error) {
+	o := options{precision: defaultPrecision}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.precision < minPrecision || o.precision > maxPrecision {
+		return nil, fmt.Errorf("precision must be between %d and %d, got %d", minPrecision, maxPrecision, o.precision)
+	}
+
+	if o.keyedHashing && o.hasher != nil {
+		return nil, errors.New("keyed hashing cannot be combined with a hasher")
+	}
+
+	hllSeeds := o.hllSeeds
+	if hllSeeds == nil && o.hasher != nil {
+		hllSeeds = []uint64{}
+	}
+
+	hllConfig, err := newHLLConfig(1<<uint(o.precision), hllSeeds, o.hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := NewConfig(maxNumCounters, hllConfig, o.seeds)
+	if err != nil {
+		return nil, err
+	}
+
+	config.TrackTotals = o.trackTotals
+	config.TrackFrequencies = o.trackFrequencies
+	config.SubsetSampleSize = o.subsetSampleSize
+	config.AdmissionPolicy = o.admissionPolicy
+	config.KeyedHashing = o.keyedHashing
+	config.HashKey = o.hashKey
+	config.keyCardinalitySketches()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
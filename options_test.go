@@ -0,0 +1,213 @@
+package ssss
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestConfigValidation(t *testing.T) {
+	t.Run("HLL Config", func(t *testing.T) {
+		invalid := map[string]*HLLConfig{
+			"zero registers":      {NumRegisters: 0, Alpha: 0.7, Seeds: []uint64{1, 2}},
+			"negative registers":  {NumRegisters: -16, Alpha: 0.7, Seeds: []uint64{1, 2}},
+			"not a power of 2":    {NumRegisters: 100, Alpha: 0.7, Seeds: []uint64{1, 2}},
+			"too few registers":   {NumRegisters: 2, Alpha: 0.7, Seeds: []uint64{1, 2}},
+			"too many registers":  {NumRegisters: 1 << 22, Alpha: 0.7, Seeds: []uint64{1, 2}},
+			"zero alpha":          {NumRegisters: 16, Alpha: 0, Seeds: []uint64{1, 2}},
+			"NaN alpha":           {NumRegisters: 16, Alpha: math.NaN(), Seeds: []uint64{1, 2}},
+			"one seed":            {NumRegisters: 16, Alpha: 0.7, Seeds: []uint64{1}},
+			"no seeds, no hasher": {NumRegisters: 16, Alpha: 0.7},
+		}
+
+		for name, config := range invalid {
+			if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("Expected ErrInvalidConfig validating a config with %s, got %v", name, err)
+			}
+		}
+
+		// A Hasher doesn't need seeds
+		if err := NewRedisHLLConfig().Validate(); err != nil {
+			t.Errorf("Expected the Redis config to be valid, got %v", err)
+		}
+
+		if _, err := NewHLLConfig(16, []uint64{1}); err == nil {
+			t.Error("Expected NewHLLConfig to reject a single seed")
+		}
+
+		if _, err := NewHLLConfig(1<<22, nil); err == nil {
+			t.Error("Expected NewHLLConfig to reject 2^22 registers")
+		}
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(16, []uint64{1, 2})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		if _, err := NewConfig(-1, hllConfig, nil); err == nil {
+			t.Error("Expected error creating a config with negative counters")
+		}
+
+		if _, err := NewConfig(10, nil, nil); err == nil {
+			t.Error("Expected error creating a config without a cardinality sketch config")
+		}
+
+		// Configurations built by hand are checked as a whole
+		config := &Config{MaxNumCounters: 10, CardinalitySketchConfig: &HLLConfig{NumRegisters: 16, Alpha: 0.7}}
+		if err := config.Validate(); err == nil {
+			t.Error("Expected error validating a config with an invalid cardinality sketch config")
+		}
+
+		config.CardinalitySketchConfig = hllConfig
+		if err := config.Validate(); err != nil {
+			t.Errorf("Expected a config without sampling seeds to be valid, got %v", err)
+		}
+	})
+
+	t.Run("Decoded Config", func(t *testing.T) {
+		config, err := NewConfig(10, NewRedisHLLConfig(), nil)
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		data, err := config.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+
+		var decoded Config
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Failed to decode config: %v", err)
+		}
+
+		if err := decoded.Validate(); err != nil {
+			t.Errorf("Expected the decoded config to be valid, got %v", err)
+		}
+
+		if decoded.CardinalitySketchConfig.Hasher != (RedisHasher{}) {
+			t.Errorf("Expected the decoded config to hash like Redis, got %T", decoded.CardinalitySketchConfig.Hasher)
+		}
+	})
+
+	t.Run("Invalid Decoded Configs", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(16, []uint64{1, 2})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		invalid := map[string]*Config{
+			"zero counters": {MaxNumCounters: 0, CardinalitySketchConfig: hllConfig},
+			"zero alpha":    {MaxNumCounters: 10, CardinalitySketchConfig: &HLLConfig{NumRegisters: 16, Alpha: 0, Seeds: []uint64{1, 2}}},
+		}
+
+		for name, config := range invalid {
+			data, err := config.MarshalBinary()
+			if err != nil {
+				t.Fatalf("Failed to marshal config with %s: %v", name, err)
+			}
+			if err := new(Config).UnmarshalBinary(data); !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("Expected ErrInvalidConfig decoding binary with %s, got %v", name, err)
+			}
+
+			data, err = json.Marshal(config)
+			if err != nil {
+				t.Fatalf("Failed to marshal config with %s to JSON: %v", name, err)
+			}
+			if err := json.Unmarshal(data, new(Config)); !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("Expected ErrInvalidConfig decoding JSON with %s, got %v", name, err)
+			}
+
+			p, err := config.ToProto()
+			if err != nil {
+				t.Fatalf("Failed to convert config with %s to protobuf: %v", name, err)
+			}
+			if _, err := ConfigFromProto(p); !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("Expected ErrInvalidConfig converting protobuf with %s, got %v", name, err)
+			}
+		}
+	})
+}
+
+func TestConfigOptions(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		config, err := NewConfigWithOptions(10)
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		if config.CardinalitySketchConfig.NumRegisters != 1024 || len(config.Seeds) != 4 ||
+			len(config.CardinalitySketchConfig.Seeds) != 8 {
+			t.Errorf("Unexpected default config %+v", config)
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		config, err := NewConfigWithOptions(10,
+			WithSeeds([]uint64{1, 2, 3, 4}),
+			WithHLLSeeds([]uint64{5, 6}),
+			WithPrecision(12),
+			WithTotals(),
+			WithFrequencies(),
+		)
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		if config.Seeds[0] != 1 || config.CardinalitySketchConfig.Seeds[1] != 6 ||
+			config.CardinalitySketchConfig.NumRegisters != 4096 ||
+			!config.TrackTotals || !config.TrackFrequencies {
+			t.Errorf("Options not applied: %+v", config)
+		}
+	})
+
+	t.Run("Master Seed", func(t *testing.T) {
+		config1, err := NewConfigWithOptions(10, WithMasterSeed("prod"), WithPrecision(8))
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		config2, err := NewSeededConfig(10, 256, "prod")
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		if config1.Fingerprint() != config2.Fingerprint() {
+			t.Error("Expected the same seeds as NewSeededConfig")
+		}
+	})
+
+	t.Run("Hasher", func(t *testing.T) {
+		config1, err := NewConfigWithOptions(10, WithHasher(RedisHasher{}), WithPrecision(14), WithSeeds([]uint64{1}))
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		config2, err := NewConfig(10, NewRedisHLLConfig(), []uint64{1})
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		if err := config1.CheckCompatible(config2); err != nil {
+			t.Errorf("Expected a Redis config from options to match NewRedisHLLConfig, got %v", err)
+		}
+	})
+
+	t.Run("Invalid Options", func(t *testing.T) {
+		for _, precision := range []int{3, 22, -1} {
+			if _, err := NewConfigWithOptions(10, WithPrecision(precision)); err == nil {
+				t.Errorf("Expected error with precision %d", precision)
+			}
+		}
+
+		if _, err := NewConfigWithOptions(10, WithHLLSeeds([]uint64{1})); err == nil {
+			t.Error("Expected error with a single HLL seed")
+		}
+
+		if _, err := NewConfigWithOptions(0); err == nil {
+			t.Error("Expected error with zero counters")
+		}
+	})
+}
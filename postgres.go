@@ -0,0 +1,283 @@
+package ssss
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// postgresql-hll parameters, see STORAGE.md in the postgresql-hll sources
+const (
+	// PostgresHLLDefaultRegWidth is the default register width of the hll
+	// extension, which bounds register values to 31
+	PostgresHLLDefaultRegWidth = 5
+
+	pgHLLVersion     = 1
+	pgHLLMinLog2m    = 4
+	pgHLLMaxLog2m    = 17
+	pgHLLMaxRegWidth = 8
+
+	pgHLLEmpty    = 1
+	pgHLLExplicit = 2
+	pgHLLSparse   = 3
+	pgHLLFull     = 4
+
+	// pgHLLCutoff enables the sparse representation and lets the extension
+	// pick the explicit cutoff, like hll_empty() with default settings
+	pgHLLCutoff = 0x40 | 63
+)
+
+// PostgresHasher hashes items the way the hll_hash_* functions of the
+// postgresql-hll extension do: the first half of MurmurHash3 x64 128 with
+// seed 0 over the item bytes. Integers are hashed in their little-endian
+// in-memory form, so int64 and int match hll_hash_bigint, int32
+// hll_hash_integer and int16 hll_hash_smallint. Strings and byte slices match
+// hll_hash_text and hll_hash_bytea, and other items are hashed as their %v
+// formatting.
+type PostgresHasher struct{}
+
+// Hash returns the postgresql-hll hash of an item
+func (PostgresHasher) Hash(item any) uint64 {
+	var b [8]byte
+	var data []byte
+
+	switch v := item.(type) {
+	case int16:
+		binary.LittleEndian.PutUint16(b[:], uint16(v))
+		data = b[:2]
+	case int32:
+		binary.LittleEndian.PutUint32(b[:], uint32(v))
+		data = b[:4]
+	case int:
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		data = b[:]
+	case int64:
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		data = b[:]
+	default:
+		data = itemText(item)
+	}
+
+	h, _ := murmurHash3x64128(data, 0)
+	return h
+}
+
+// registerValue returns the value the extension stores for a hash
+func (PostgresHasher) registerValue(hash uint64, registerBits uint) uint8 {
+	return postgresRegisterValue(hash, registerBits)
+}
+
+// maxRegisterValue returns the value of a hash with only its top bit set
+// above the index
+func (PostgresHasher) maxRegisterValue(registerBits uint) uint8 {
+	return uint8(64 - registerBits)
+}
+
+// postgresRegisterValue returns the trailing zeros plus one of the bits of a
+// hash above the register index. Unlike insertHash there is no sentinel bit,
+// so the extension leaves the register unchanged when these bits are zero.
+func postgresRegisterValue(hash uint64, registerBits uint) uint8 {
+	if hash>>registerBits == 0 {
+		return 0
+	}
+	return uint8(bits.TrailingZeros64(hash>>registerBits)) + 1
+}
+
+// NewPostgresHLLConfig creates a HyperLogLog configuration with 2^log2m
+// registers whose sketches are register-compatible with hll values of the
+// same log2m built from the hll_hash_* functions
+func NewPostgresHLLConfig(log2m int) (*HLLConfig, error) {
+	if log2m < pgHLLMinLog2m || log2m > pgHLLMaxLog2m {
+		return nil, errors.New("log2m must be between 4 and 17 for postgresql-hll")
+	}
+
+	return newHLLConfig(1<<uint(log2m), []uint64{}, PostgresHasher{})
+}
+
+// HyperLogLogFromPostgres decodes a postgresql-hll value of any type into a
+// sketch with NewPostgresHLLConfig
+func HyperLogLogFromPostgres[T comparable](data []byte) (*HyperLogLog[T], error) {
+	if len(data) < 3 {
+		return nil, errors.New("truncated postgresql-hll header")
+	}
+
+	config, err := NewPostgresHLLConfig(int(data[1] & 0x1f))
+	if err != nil {
+		return nil, err
+	}
+
+	h := NewHyperLogLog[T](config)
+	if err := h.UnmarshalPostgres(data); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// UnmarshalPostgres replaces the registers of the sketch with those of a
+// postgresql-hll value of the EMPTY, EXPLICIT, SPARSE or FULL type. The value
+// must have as many registers as the sketch, and the sketch should use
+// PostgresHasher for later insertions to be consistent with the extension.
+// The hashes of an EXPLICIT value are folded into registers, so the exact
+// count of the value becomes an estimate.
+func (h *HyperLogLog[T]) UnmarshalPostgres(data []byte) error {
+	if len(data) < 3 {
+		return errors.New("truncated postgresql-hll header")
+	}
+
+	version, hllType := data[0]>>4, data[0]&0x0f
+	regWidth, log2m := int(data[1]>>5)+1, int(data[1]&0x1f)
+
+	switch {
+	case version != pgHLLVersion:
+		return fmt.Errorf("unsupported postgresql-hll version %d", version)
+	case log2m < pgHLLMinLog2m || log2m > pgHLLMaxLog2m:
+		return errors.New("log2m must be between 4 and 17 for postgresql-hll")
+	case h.config.NumRegisters != 1<<uint(log2m):
+		return fmt.Errorf("sketch must have %d registers for this postgresql-hll value", 1<<uint(log2m))
+	}
+
+	maxValue := byte(1<<uint(regWidth) - 1)
+	registers := make([]byte, h.config.NumRegisters)
+	body := data[3:]
+
+	switch hllType {
+	case pgHLLEmpty:
+		if len(body) != 0 {
+			return errors.New("invalid postgresql-hll EMPTY length")
+		}
+
+	case pgHLLExplicit:
+		if len(body)%8 != 0 {
+			return errors.New("invalid postgresql-hll EXPLICIT length")
+		}
+
+		// Promoting to registers caps values at the register width
+		for i := 0; i < len(body); i += 8 {
+			hash := binary.BigEndian.Uint64(body[i:])
+			idx := hash & uint64(h.config.NumRegisters-1)
+			value := postgresRegisterValue(hash, uint(log2m))
+			if value > maxValue {
+				value = maxValue
+			}
+			if value > registers[idx] {
+				registers[idx] = value
+			}
+		}
+
+	case pgHLLSparse:
+		r := bitReader{buf: body}
+		entryBits := log2m + regWidth
+		for n := len(body) * 8 / entryBits; n > 0; n-- {
+			idx := r.read(log2m)
+			value := byte(r.read(regWidth))
+
+			// Padding long enough to hold an entry reads as a zero value
+			if value == 0 {
+				continue
+			}
+			if value > registers[idx] {
+				registers[idx] = value
+			}
+		}
+
+	case pgHLLFull:
+		if len(body) != (h.config.NumRegisters*regWidth+7)/8 {
+			return errors.New("invalid postgresql-hll FULL length")
+		}
+
+		r := bitReader{buf: body}
+		for i := range registers {
+			registers[i] = byte(r.read(regWidth))
+		}
+
+	default:
+		return fmt.Errorf("unsupported postgresql-hll type %d", hllType)
+	}
+
+	copy(h.registers, registers)
+	h.recompute()
+	return nil
+}
+
+// MarshalPostgres encodes the sketch as a postgresql-hll value with the
+// given register width, PostgresHLLDefaultRegWidth unless the column was
+// created with another. Register values above what the width can hold are
+// capped, as the extension does. Sketches without any insertions are written
+// as EMPTY and all others as SPARSE or FULL, whichever is smaller.
+func (h *HyperLogLog[T]) MarshalPostgres(regWidth int) ([]byte, error) {
+	log2m := bits.Len(uint(h.config.NumRegisters)) - 1
+	if log2m < pgHLLMinLog2m || log2m > pgHLLMaxLog2m {
+		return nil, errors.New("sketch must have between 2^4 and 2^17 registers for postgresql-hll")
+	}
+	if regWidth < 1 || regWidth > pgHLLMaxRegWidth {
+		return nil, errors.New("register width must be between 1 and 8 for postgresql-hll")
+	}
+
+	header := []byte{pgHLLVersion << 4, byte(regWidth-1)<<5 | byte(log2m), pgHLLCutoff}
+	maxValue := byte(1<<uint(regWidth) - 1)
+
+	nonZero := h.config.NumRegisters - h.numZeroRegisters
+	if nonZero == 0 {
+		header[0] |= pgHLLEmpty
+		return header, nil
+	}
+
+	var w bitWriter
+	if sparseBits := nonZero * (log2m + regWidth); sparseBits < h.config.NumRegisters*regWidth {
+		header[0] |= pgHLLSparse
+		for i, value := range h.registers {
+			if value == 0 {
+				continue
+			}
+			if value > maxValue {
+				value = maxValue
+			}
+			w.write(uint64(i), log2m)
+			w.write(uint64(value), regWidth)
+		}
+	} else {
+		header[0] |= pgHLLFull
+		for _, value := range h.registers {
+			if value > maxValue {
+				value = maxValue
+			}
+			w.write(uint64(value), regWidth)
+		}
+	}
+
+	return append(header, w.buf...), nil
+}
+
+// bitReader reads big-endian bit-packed values, most significant bit first
+type bitReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *bitReader) read(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		bit := r.buf[r.pos/8] >> (7 - uint(r.pos%8)) & 1
+		v = v<<1 | uint64(bit)
+		r.pos++
+	}
+	return v
+}
+
+// bitWriter writes big-endian bit-packed values, most significant bit first,
+// padding the last byte with zeros
+type bitWriter struct {
+	buf []byte
+	pos int
+}
+
+func (w *bitWriter) write(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.pos%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		w.buf[w.pos/8] |= byte(v>>uint(i)&1) << (7 - uint(w.pos%8))
+		w.pos++
+	}
+}
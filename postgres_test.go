@@ -0,0 +1,355 @@
+package ssss
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Documented outputs of hll_empty() and
+// hll_add(hll_empty(), hll_hash_integer(1)) in the postgresql-hll README
+var (
+	pgEmpty    = []byte{0x11, 0x8b, 0x7f}
+	pgExplicit = []byte{0x12, 0x8b, 0x7f, 0x88, 0x95, 0xa3, 0xf5, 0xaf, 0x28, 0xca, 0xfe}
+)
+
+// postgresCaptures lists the values captured by testdata/postgres/capture.sh.
+// Items are hashed with hll_hash_bigint, and hashes are added as they are.
+// When encoded is set, MarshalPostgres reproduces the value up to the cutoff
+// byte, which depends on the parameters given to hll_add_agg.
+var postgresCaptures = []struct {
+	name    string
+	items   int64
+	hashes  []uint64
+	encoded bool
+}{
+	{name: "empty", encoded: true},
+	{name: "explicit", items: 10},
+	{name: "sparse", items: 100, encoded: true},
+	{name: "full-small", items: 100},
+	{name: "full", items: 10000, encoded: true},
+	{name: "zero-explicit", hashes: []uint64{5, 2053, 1029}},
+	{name: "zero-full", hashes: []uint64{5, 2053, 1029}},
+}
+
+// readPostgresCaptures reads the values written by capture.sh and the
+// cardinalities the extension estimated for them
+func readPostgresCaptures(t *testing.T) (map[string][]byte, map[string]uint64) {
+	t.Helper()
+
+	values := make(map[string][]byte)
+	counts := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(readFixture(t, "postgres/values.txt")))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			t.Fatalf("Invalid line in values.txt: %q", scanner.Text())
+		}
+
+		value, err := hex.DecodeString(fields[1])
+		if err != nil {
+			t.Fatalf("Invalid value %s in values.txt: %v", fields[0], err)
+		}
+
+		count, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			t.Fatalf("Invalid cardinality of %s in values.txt: %v", fields[0], err)
+		}
+
+		values[fields[0]] = value
+		counts[fields[0]] = count
+	}
+	return values, counts
+}
+
+func TestPostgresHyperLogLog(t *testing.T) {
+	// newSketch creates a sketch with the default hll column parameters
+	newSketch := func(t *testing.T) *HyperLogLog[int64] {
+		t.Helper()
+
+		config, err := NewPostgresHLLConfig(11)
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+		return NewHyperLogLog[int64](config)
+	}
+
+	// newFull creates a FULL value of 10000 items
+	newFull := func(t *testing.T) []byte {
+		t.Helper()
+
+		hll := newSketch(t)
+		for i := int64(0); i < 10000; i++ {
+			hll.Insert(i)
+		}
+
+		data, err := hll.MarshalPostgres(PostgresHLLDefaultRegWidth)
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+		return data
+	}
+
+	t.Run("Hashing", func(t *testing.T) {
+		// hll_hash_integer(1)
+		if hash := (PostgresHasher{}).Hash(int32(1)); hash != 0x8895a3f5af28cafe {
+			t.Errorf("Expected hash 0x8895a3f5af28cafe, got %#x", hash)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		decoded, err := HyperLogLogFromPostgres[int64](pgEmpty)
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		if decoded.Cardinality() != 0 {
+			t.Errorf("Expected cardinality 0, got %d", decoded.Cardinality())
+		}
+
+		data, err := newSketch(t).MarshalPostgres(PostgresHLLDefaultRegWidth)
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		if !bytes.Equal(data, pgEmpty) {
+			t.Errorf("Expected hll_empty() encoding, got %x", data)
+		}
+	})
+
+	t.Run("Explicit", func(t *testing.T) {
+		decoded, err := HyperLogLogFromPostgres[int32](pgExplicit)
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		hll := NewHyperLogLog[int32](decoded.config)
+		hll.Insert(1)
+
+		if !bytes.Equal(decoded.registers, hll.registers) {
+			t.Error("Registers differ from inserting hll_hash_integer(1)")
+		}
+
+		if decoded.Cardinality() != 1 {
+			t.Errorf("Expected cardinality 1, got %d", decoded.Cardinality())
+		}
+	})
+
+	// The extension computes registers from the bits above the index with no
+	// sentinel bit, so a hash whose upper bits are zero changes nothing
+	t.Run("Zero Upper Bits", func(t *testing.T) {
+		hll := newSketch(t)
+		hll.insertHash(5)
+		hll.insertHash(1029)
+
+		if hll.Cardinality() != 0 || hll.registers[5] != 0 || hll.registers[1029] != 0 {
+			t.Errorf("Expected hashes 5 and 1029 to leave registers unchanged, got %d and %d",
+				hll.registers[5], hll.registers[1029])
+		}
+
+		hll.insertHash(2053)
+		if hll.registers[5] != 1 {
+			t.Errorf("Expected register 5 to be 1, got %d", hll.registers[5])
+		}
+
+		explicit := []byte{0x12, 0x8b, 0x7f}
+		for _, hash := range []uint64{5, 2053, 1029} {
+			var b [8]byte
+			binary.BigEndian.PutUint64(b[:], hash)
+			explicit = append(explicit, b[:]...)
+		}
+
+		decoded, err := HyperLogLogFromPostgres[int64](explicit)
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		if !bytes.Equal(decoded.registers, hll.registers) {
+			t.Error("Registers of the EXPLICIT value differ from inserting its hashes")
+		}
+	})
+
+	// Values captured from postgresql-hll with capture.sh, which records the
+	// server and extension versions in VERSION
+	t.Run("Captured Values", func(t *testing.T) {
+		if _, err := os.Stat(filepath.Join("testdata", "postgres", "VERSION")); err != nil {
+			t.Skip("No captured postgresql-hll values, run testdata/postgres/capture.sh against PostgreSQL")
+		}
+
+		values, counts := readPostgresCaptures(t)
+		for _, capture := range postgresCaptures {
+			value, ok := values[capture.name]
+			if !ok {
+				t.Fatalf("Missing %s in values.txt", capture.name)
+			}
+
+			decoded, err := HyperLogLogFromPostgres[int64](value)
+			if err != nil {
+				t.Fatalf("Failed to decode %s: %v", capture.name, err)
+			}
+
+			hll := newSketch(t)
+			for i := int64(1); i <= capture.items; i++ {
+				hll.Insert(i)
+			}
+			for _, hash := range capture.hashes {
+				hll.insertHash(hash)
+			}
+
+			if !bytes.Equal(decoded.registers, hll.registers) {
+				t.Errorf("Registers of %s differ from inserting its elements", capture.name)
+			}
+
+			// The extension corrects small and large estimates differently,
+			// so estimates agree only approximately. Raw hashes with zero
+			// upper bits are counted by EXPLICIT values but not registers.
+			count := counts[capture.name]
+			switch {
+			case len(capture.hashes) > 0:
+			case count == 0 && decoded.Cardinality() != 0:
+				t.Errorf("Expected cardinality of %s to be 0, got %d", capture.name, decoded.Cardinality())
+			case count > 0 && relativeError(decoded.Cardinality(), count) > 0.05:
+				t.Errorf("Expected cardinality of %s close to hll_cardinality %d, got %d",
+					capture.name, count, decoded.Cardinality())
+			}
+
+			if capture.encoded {
+				data, err := hll.MarshalPostgres(PostgresHLLDefaultRegWidth)
+				if err != nil {
+					t.Fatalf("Failed to encode HLL: %v", err)
+				}
+
+				if !bytes.Equal(data[:2], value[:2]) || !bytes.Equal(data[3:], value[3:]) {
+					t.Errorf("Encoding differs from %s", capture.name)
+				}
+			}
+		}
+	})
+
+	t.Run("Sparse And Full Round Trip", func(t *testing.T) {
+		types := map[int64]byte{
+			100:   pgHLLSparse,
+			10000: pgHLLFull,
+		}
+
+		for n, hllType := range types {
+			hll := newSketch(t)
+			for i := int64(0); i < n; i++ {
+				hll.Insert(i)
+			}
+
+			data, err := hll.MarshalPostgres(PostgresHLLDefaultRegWidth)
+			if err != nil {
+				t.Fatalf("Failed to encode HLL: %v", err)
+			}
+
+			if data[0]&0x0f != hllType {
+				t.Errorf("Expected type %d for %d items, got %d", hllType, n, data[0]&0x0f)
+			}
+
+			decoded, err := HyperLogLogFromPostgres[int64](data)
+			if err != nil {
+				t.Fatalf("Failed to decode HLL: %v", err)
+			}
+
+			if !bytes.Equal(decoded.registers, hll.registers) {
+				t.Errorf("Registers of %d items differ after decoding", n)
+			}
+
+			if relativeError(decoded.Cardinality(), uint64(n)) > 0.1 {
+				t.Errorf("Expected cardinality close to %d, got %d", n, decoded.Cardinality())
+			}
+		}
+	})
+
+	t.Run("Register Width", func(t *testing.T) {
+		hll := newSketch(t)
+		for i := int64(0); i < 5000; i++ {
+			hll.Insert(i)
+		}
+
+		// Narrow registers are capped at 2^regwidth-1
+		data, err := hll.MarshalPostgres(2)
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		decoded, err := HyperLogLogFromPostgres[int64](data)
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		for i, value := range decoded.registers {
+			expected := hll.registers[i]
+			if expected > 3 {
+				expected = 3
+			}
+			if value != expected {
+				t.Fatalf("Expected register %d to be %d, got %d", i, expected, value)
+			}
+		}
+	})
+
+	t.Run("Merge Back In", func(t *testing.T) {
+		config, err := NewConfig(2, newSketch(t).config, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, int64](config)
+		for i := int64(10000); i < 10100; i++ {
+			sketch.Insert("daily", i)
+		}
+
+		imported, err := HyperLogLogFromPostgres[int64](newFull(t))
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		if err := sketch.MergeLabel("daily", imported); err != nil {
+			t.Fatalf("Failed to merge label: %v", err)
+		}
+
+		if relativeError(sketch.Cardinality("daily"), 10100) > 0.1 {
+			t.Errorf("Expected cardinality close to 10100, got %d", sketch.Cardinality("daily"))
+		}
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		full := newFull(t)
+		inputs := map[string][]byte{
+			"short":          {0x11, 0x8b},
+			"bad version":    {0x21, 0x8b, 0x7f},
+			"undefined":      {0x10, 0x8b, 0x7f},
+			"bad log2m":      {0x11, 0x83, 0x7f},
+			"long empty":     {0x11, 0x8b, 0x7f, 0x00},
+			"short explicit": pgExplicit[:10],
+			"short full":     full[:len(full)-1],
+		}
+
+		for name, input := range inputs {
+			if _, err := HyperLogLogFromPostgres[int64](input); err == nil {
+				t.Errorf("Expected error decoding %s input", name)
+			}
+		}
+
+		config, err := NewPostgresHLLConfig(12)
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		if err := NewHyperLogLog[int64](config).UnmarshalPostgres(full); err == nil {
+			t.Error("Expected error decoding a value with a different log2m")
+		}
+
+		if _, err := newSketch(t).MarshalPostgres(9); err == nil {
+			t.Error("Expected error encoding with a register width above 8")
+		}
+	})
+}
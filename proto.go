@@ -0,0 +1,282 @@
+package ssss
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sawmills/go-ssss/ssssproto"
+)
+
+// ToProto converts the configuration to its protobuf representation. It
+// fails for hashers that cannot be encoded.
+func (c *HLLConfig) ToProto() (*ssssproto.HLLConfig, error) {
+	kind, err := hasherKind(c)
+	if err != nil {
+		return nil, err
+	}
+
+	numRegisters, err := protoUint32("NumRegisters", c.NumRegisters)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssssproto.HLLConfig{
+		NumRegisters: numRegisters,
+		Alpha:        c.Alpha,
+		Seeds:        append([]uint64(nil), c.Seeds...),
+		Hasher:       ssssproto.Hasher(kind),
+	}, nil
+}
+
+// HLLConfigFromProto converts a protobuf HyperLogLog configuration
+func HLLConfigFromProto(p *ssssproto.HLLConfig) (*HLLConfig, error) {
+	if p == nil {
+		return nil, errors.New("missing HLL config")
+	}
+
+	return decodedHLLConfig(
+		int(p.GetNumRegisters()),
+		p.GetAlpha(),
+		append([]uint64(nil), p.GetSeeds()...),
+		uint64(p.GetHasher()),
+	)
+}
+
+// ToProto converts the sketch to its protobuf representation
+func (h *HyperLogLog[T]) ToProto() (*ssssproto.HyperLogLog, error) {
+	config, err := h.config.ToProto()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssssproto.HyperLogLog{
+		Config:    config,
+		Registers: append([]byte(nil), h.registers...),
+		Version:   encodingVersion,
+	}, nil
+}
+
+// HyperLogLogFromProto converts a protobuf HyperLogLog sketch
+func HyperLogLogFromProto[T comparable](p *ssssproto.HyperLogLog) (*HyperLogLog[T], error) {
+	if err := checkVersion(uint64(p.GetVersion())); err != nil {
+		return nil, err
+	}
+
+	config, err := HLLConfigFromProto(p.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return hyperLogLogFromRegisters[T](config, p.GetRegisters())
+}
+
+// ToProto converts the configuration to its protobuf representation. It
+// fails for sizes that do not fit the 32-bit fields of the message.
+func (c *Config) ToProto() (*ssssproto.Config, error) {
+	hllConfig, err := c.CardinalitySketchConfig.ToProto()
+	if err != nil {
+		return nil, err
+	}
+
+	maxNumCounters, err := protoUint32("MaxNumCounters", c.MaxNumCounters)
+	if err != nil {
+		return nil, err
+	}
+
+	subsetSampleSize, err := protoUint32("SubsetSampleSize", c.SubsetSampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssssproto.Config{
+		MaxNumCounters:          maxNumCounters,
+		Seeds:                   append([]uint64(nil), c.Seeds...),
+		CardinalitySketchConfig: hllConfig,
+		TrackTotals:             c.TrackTotals,
+		TrackFrequencies:        c.TrackFrequencies,
+		Fingerprint:             c.Fingerprint(),
+		SubsetSampleSize:        subsetSampleSize,
+		KeyedHashing:            c.KeyedHashing,
+	}, nil
+}
+
+// protoUint32 converts a size to a uint32 message field and fails instead of
+// truncating it
+func protoUint32(field string, value int) (uint32, error) {
+	if value < 0 || uint64(value) > math.MaxUint32 {
+		return 0, fmt.Errorf("%s %d does not fit the protobuf encoding", field, value)
+	}
+	return uint32(value), nil
+}
+
+// ConfigFromProto converts a protobuf SamplingSpaceSavingSets configuration
+func ConfigFromProto(p *ssssproto.Config) (*Config, error) {
+	if p == nil {
+		return nil, errors.New("missing config")
+	}
+
+	hllConfig, err := HLLConfigFromProto(p.GetCardinalitySketchConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		MaxNumCounters:          int(p.GetMaxNumCounters()),
+		Seeds:                   append([]uint64(nil), p.GetSeeds()...),
+		CardinalitySketchConfig: hllConfig,
+		TrackTotals:             p.GetTrackTotals(),
+		TrackFrequencies:        p.GetTrackFrequencies(),
+		SubsetSampleSize:        int(p.GetSubsetSampleSize()),
+		KeyedHashing:            p.GetKeyedHashing(),
+	}
+	if err := validateDecoded(config.validate()); err != nil {
+		return nil, err
+	}
+	if err := config.checkFingerprint(p.GetFingerprint()); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ToProto converts the sketch to its protobuf representation. Counters are
+// ordered by label and labels are stored in their text form.
+func (s *SamplingSpaceSavingSets[L, T]) ToProto() (*ssssproto.SamplingSpaceSavingSets, error) {
+	config, err := s.config.ToProto()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ssssproto.SamplingSpaceSavingSets{
+		Config:    config,
+		Threshold: s.threshold,
+		Counters:  make([]*ssssproto.Counter, 0, len(s.counters)),
+		Version:   encodingVersion,
+	}
+
+	for label, counter := range s.counters {
+		text, err := marshalLabel(label)
+		if err != nil {
+			return nil, err
+		}
+
+		hll, ok := counter.sketch.(*HyperLogLog[T])
+		if !ok {
+			return nil, errors.New("can only encode counters backed by HyperLogLog")
+		}
+
+		p.Counters = append(p.Counters, &ssssproto.Counter{
+			Label:          text,
+			Registers:      append([]byte(nil), hll.registers...),
+			Cardinality:    counter.Cardinality(),
+			Frequency:      counter.frequency,
+			FrequencyError: counter.frequencyError,
+		})
+	}
+
+	sort.Slice(p.Counters, func(i, j int) bool {
+		return bytes.Compare(p.Counters[i].Label, p.Counters[j].Label) < 0
+	})
+
+	if s.items != nil {
+		p.TotalItems = append([]byte(nil), s.items.registers...)
+		p.DistinctLabels = append([]byte(nil), s.labels.registers...)
+	}
+
+	if s.sample != nil {
+		hashes, texts, err := s.sample.entries()
+		if err != nil {
+			return nil, err
+		}
+
+		p.SubsetThreshold = s.sample.threshold
+		p.SubsetSample = make([]*ssssproto.SampledPair, len(hashes))
+		for i, hash := range hashes {
+			p.SubsetSample[i] = &ssssproto.SampledPair{Label: texts[i], Hash: hash}
+		}
+	}
+
+	return p, nil
+}
+
+// SamplingSpaceSavingSetsFromProto converts a protobuf sketch. Cached
+// cardinalities and the threshold are recomputed from the registers.
+func SamplingSpaceSavingSetsFromProto[L comparable, T comparable](
+	p *ssssproto.SamplingSpaceSavingSets,
+) (*SamplingSpaceSavingSets[L, T], error) {
+	if err := checkVersion(uint64(p.GetVersion())); err != nil {
+		return nil, err
+	}
+
+	config, err := ConfigFromProto(p.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.GetCounters()) > config.MaxNumCounters {
+		return nil, errors.New("more counters than MaxNumCounters")
+	}
+
+	s := newSamplingSpaceSavingSets[L, T](config, len(p.GetCounters()))
+
+	for _, c := range p.GetCounters() {
+		label, err := unmarshalLabel[L](c.GetLabel())
+		if err != nil {
+			return nil, err
+		}
+
+		if _, exists := s.counters[label]; exists {
+			return nil, fmt.Errorf("duplicate label %q", c.GetLabel())
+		}
+
+		hll, err := hyperLogLogFromRegisters[T](config.CardinalitySketchConfig, c.GetRegisters())
+		if err != nil {
+			return nil, err
+		}
+
+		counter := NewCachedSketch[T](hll)
+		counter.cardinality = hll.Cardinality()
+		if config.TrackFrequencies {
+			counter.frequency = c.GetFrequency()
+			counter.frequencyError = c.GetFrequencyError()
+		}
+		s.counters[label] = counter
+	}
+	s.updateThreshold()
+
+	if s.items != nil {
+		if err := s.setTotals(p.GetTotalItems(), p.GetDistinctLabels()); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.sample != nil {
+		pairs := p.GetSubsetSample()
+		hashes := make([]uint64, len(pairs))
+		texts := make([][]byte, len(pairs))
+		for i, pair := range pairs {
+			hashes[i] = pair.GetHash()
+			texts[i] = pair.GetLabel()
+		}
+
+		if err := s.sample.set(p.GetSubsetThreshold(), hashes, texts); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// hyperLogLogFromRegisters creates a sketch with a copy of the given registers
+func hyperLogLogFromRegisters[T comparable](config *HLLConfig, registers []byte) (*HyperLogLog[T], error) {
+	if err := config.checkRegisters(registers); err != nil {
+		return nil, err
+	}
+
+	hll := NewHyperLogLog[T](config)
+	copy(hll.registers, registers)
+	hll.recompute()
+	return hll, nil
+}
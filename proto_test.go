@@ -0,0 +1,190 @@
+package ssss
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/sawmills/go-ssss/ssssproto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProtoConversion(t *testing.T) {
+	t.Run("HyperLogLog Round Trip", func(t *testing.T) {
+		config, err := NewHLLConfig(256, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		hll := NewHyperLogLog[uint64](config)
+		for i := uint64(0); i < 500; i++ {
+			hll.Insert(i)
+		}
+
+		hllProto, err := hll.ToProto()
+		if err != nil {
+			t.Fatalf("Failed to convert HLL to protobuf: %v", err)
+		}
+
+		data, err := proto.Marshal(hllProto)
+		if err != nil {
+			t.Fatalf("Failed to marshal HLL: %v", err)
+		}
+
+		var p ssssproto.HyperLogLog
+		if err := proto.Unmarshal(data, &p); err != nil {
+			t.Fatalf("Failed to unmarshal HLL: %v", err)
+		}
+
+		decoded, err := HyperLogLogFromProto[uint64](&p)
+		if err != nil {
+			t.Fatalf("Failed to convert HLL: %v", err)
+		}
+
+		if !bytes.Equal(decoded.registers, hll.registers) {
+			t.Error("Registers differ after round trip")
+		}
+
+		if decoded.Cardinality() != hll.Cardinality() {
+			t.Errorf("Expected cardinality %d, got %d", hll.Cardinality(), decoded.Cardinality())
+		}
+	})
+
+	t.Run("Sketch Round Trip", func(t *testing.T) {
+		hllConfig, err := NewHLLConfig(512, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(5, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+		for i, label := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+			for j := uint64(0); j < uint64(i+1)*40; j++ {
+				sketch.Insert(label, j)
+			}
+		}
+
+		p, err := sketch.ToProto()
+		if err != nil {
+			t.Fatalf("Failed to convert sketch: %v", err)
+		}
+
+		data, err := proto.Marshal(p)
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+
+		var decodedProto ssssproto.SamplingSpaceSavingSets
+		if err := proto.Unmarshal(data, &decodedProto); err != nil {
+			t.Fatalf("Failed to unmarshal sketch: %v", err)
+		}
+
+		decoded, err := SamplingSpaceSavingSetsFromProto[string, uint64](&decodedProto)
+		if err != nil {
+			t.Fatalf("Failed to convert sketch: %v", err)
+		}
+
+		// The threshold is recomputed from the decoded counters
+		if _, minCardinality := sketch.minCounter(); decoded.threshold != minCardinality {
+			t.Errorf("Expected threshold %d, got %d", minCardinality, decoded.threshold)
+		}
+
+		expected := sketch.Top(5)
+		actual := decoded.Top(5)
+		if len(actual) != len(expected) {
+			t.Fatalf("Expected %d labels, got %d", len(expected), len(actual))
+		}
+
+		for i := range expected {
+			if actual[i] != expected[i] {
+				t.Errorf("Expected %v at position %d, got %v", expected[i], i, actual[i])
+			}
+		}
+
+		if err := decoded.Merge(sketch); err != nil {
+			t.Errorf("Failed to merge converted sketch: %v", err)
+		}
+	})
+
+	t.Run("Oversized Config", func(t *testing.T) {
+		if strconv.IntSize < 64 {
+			t.Skip("sizes above 32 bits need a 64-bit int")
+		}
+
+		hllConfig, err := NewHLLConfig(16, []uint64{1, 2})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		oversized := uint64(math.MaxUint32) + 1
+		for _, field := range []string{"MaxNumCounters", "SubsetSampleSize"} {
+			config, err := NewConfig(2, hllConfig, []uint64{1, 2})
+			if err != nil {
+				t.Fatalf("Failed to create config: %v", err)
+			}
+
+			if field == "MaxNumCounters" {
+				config.MaxNumCounters = int(oversized)
+			} else {
+				config.SubsetSampleSize = int(oversized)
+			}
+
+			// The value must not be truncated to a different config
+			if _, err := config.ToProto(); err == nil {
+				t.Errorf("Expected error converting a config with %s above 32 bits", field)
+			}
+		}
+	})
+
+	t.Run("Invalid Messages", func(t *testing.T) {
+		hllConfig := &ssssproto.HLLConfig{NumRegisters: 16, Alpha: 0.673, Seeds: []uint64{1, 2}}
+
+		messages := map[string]*ssssproto.SamplingSpaceSavingSets{
+			"missing config": {Version: encodingVersion},
+			"missing version": {
+				Config: &ssssproto.Config{MaxNumCounters: 2, CardinalitySketchConfig: hllConfig},
+			},
+			"bad registers": {
+				Version: encodingVersion,
+				Config: &ssssproto.Config{
+					MaxNumCounters:          2,
+					CardinalitySketchConfig: &ssssproto.HLLConfig{NumRegisters: 12},
+				},
+			},
+			"wrong register count": {
+				Version: encodingVersion,
+				Config:  &ssssproto.Config{MaxNumCounters: 2, CardinalitySketchConfig: hllConfig},
+				Counters: []*ssssproto.Counter{
+					{Label: []byte("a"), Registers: make([]byte, 8)},
+				},
+			},
+			"duplicate label": {
+				Version: encodingVersion,
+				Config:  &ssssproto.Config{MaxNumCounters: 2, CardinalitySketchConfig: hllConfig},
+				Counters: []*ssssproto.Counter{
+					{Label: []byte("a"), Registers: make([]byte, 16)},
+					{Label: []byte("a"), Registers: make([]byte, 16)},
+				},
+			},
+			"too many counters": {
+				Version: encodingVersion,
+				Config:  &ssssproto.Config{MaxNumCounters: 1, CardinalitySketchConfig: hllConfig},
+				Counters: []*ssssproto.Counter{
+					{Label: []byte("a"), Registers: make([]byte, 16)},
+					{Label: []byte("b"), Registers: make([]byte, 16)},
+				},
+			},
+		}
+
+		for name, message := range messages {
+			if _, err := SamplingSpaceSavingSetsFromProto[string, uint64](message); err == nil {
+				t.Errorf("Expected error converting message with %s", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package ssss
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Redis HyperLogLog parameters, see hyperloglog.c in the Redis sources
+const (
+	// RedisHLLRegisters is the number of registers of a Redis HyperLogLog
+	RedisHLLRegisters = 1 << redisHLLP
+
+	redisHLLP          = 14
+	redisHLLBits       = 6
+	redisHLLSeed       = 0xadc83b19
+	redisHLLHeaderSize = 16
+	redisHLLDenseSize  = redisHLLHeaderSize + (RedisHLLRegisters*redisHLLBits+7)/8
+
+	redisHLLDense  = 0
+	redisHLLSparse = 1
+
+	// Sparse opcodes
+	redisSparseValMaxValue = 32
+	redisSparseValMaxLen   = 4
+	redisSparseZeroMaxLen  = 64
+	redisSparseXZeroMaxLen = 16384
+
+	// redisSparseMaxBytes is the default hll-sparse-max-bytes setting, the
+	// size above which Redis converts sparse sketches to the dense encoding
+	redisSparseMaxBytes = 3000
+)
+
+// RedisHasher hashes items the way Redis PFADD hashes its elements: with
+// MurmurHash64A and Redis's seed over the item text, see itemText
+type RedisHasher struct{}
+
+// Hash returns the Redis hash of an item
+func (RedisHasher) Hash(item any) uint64 {
+	return murmurHash64A(itemText(item), redisHLLSeed)
+}
+
+// NewRedisHLLConfig creates a HyperLogLog configuration whose sketches are
+// register-compatible with Redis, so they can be converted to and from the
+// values produced by PFADD
+func NewRedisHLLConfig() *HLLConfig {
+	config, _ := newHLLConfig(RedisHLLRegisters, []uint64{}, RedisHasher{})
+	return config
+}
+
+// HyperLogLogFromRedis decodes a Redis HyperLogLog string value in either the
+// dense or the sparse encoding into a sketch with NewRedisHLLConfig
+func HyperLogLogFromRedis[T comparable](data []byte) (*HyperLogLog[T], error) {
+	h := NewHyperLogLog[T](NewRedisHLLConfig())
+	if err := h.UnmarshalRedis(data); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// UnmarshalRedis replaces the registers of the sketch with those of a Redis
+// HyperLogLog string value in either the dense or the sparse encoding. The
+// sketch must have RedisHLLRegisters registers, and should use RedisHasher
+// for later insertions to be consistent with Redis.
+func (h *HyperLogLog[T]) UnmarshalRedis(data []byte) error {
+	if h.config.NumRegisters != RedisHLLRegisters {
+		return errors.New("sketch must have 16384 registers for the Redis encoding")
+	}
+
+	if len(data) < redisHLLHeaderSize || string(data[:4]) != "HYLL" {
+		return errors.New("missing Redis HYLL header")
+	}
+
+	registers := make([]byte, RedisHLLRegisters)
+	body := data[redisHLLHeaderSize:]
+
+	switch data[4] {
+	case redisHLLDense:
+		if len(data) != redisHLLDenseSize {
+			return errors.New("invalid Redis dense encoding length")
+		}
+		for i := range registers {
+			registers[i] = redisDenseGet(body, i)
+		}
+
+	case redisHLLSparse:
+		idx := 0
+		for len(body) > 0 {
+			var value byte
+			var runLen int
+
+			switch op := body[0]; {
+			case op&0xc0 == 0x00: // ZERO: 00xxxxxx
+				runLen = int(op&0x3f) + 1
+				body = body[1:]
+			case op&0xc0 == 0x40: // XZERO: 01xxxxxx yyyyyyyy
+				if len(body) < 2 {
+					return errors.New("truncated Redis sparse opcode")
+				}
+				runLen = (int(op&0x3f)<<8 | int(body[1])) + 1
+				body = body[2:]
+			default: // VAL: 1vvvvvxx
+				value = (op>>2)&0x1f + 1
+				runLen = int(op&0x03) + 1
+				body = body[1:]
+			}
+
+			if idx+runLen > RedisHLLRegisters {
+				return errors.New("sparse runs of the Redis encoding exceed the register count")
+			}
+
+			for i := idx; i < idx+runLen; i++ {
+				registers[i] = value
+			}
+			idx += runLen
+		}
+
+		if idx != RedisHLLRegisters {
+			return errors.New("sparse runs of the Redis encoding do not cover all registers")
+		}
+
+	default:
+		return errors.New("unsupported Redis HLL encoding")
+	}
+
+	copy(h.registers, registers)
+	h.recompute()
+	return nil
+}
+
+// MarshalRedis encodes the sketch as a Redis HyperLogLog string value. Like
+// Redis, it uses the sparse encoding while the sketch is small enough and
+// the dense encoding otherwise.
+func (h *HyperLogLog[T]) MarshalRedis() ([]byte, error) {
+	if sparse, err := h.MarshalRedisSparse(); err == nil && len(sparse) <= redisSparseMaxBytes {
+		return sparse, nil
+	}
+	return h.MarshalRedisDense()
+}
+
+// MarshalRedisDense encodes the sketch in Redis's dense encoding
+func (h *HyperLogLog[T]) MarshalRedisDense() ([]byte, error) {
+	if err := h.checkRedisRegisters(63); err != nil {
+		return nil, err
+	}
+
+	// The extra byte absorbs the spill of the last register, like the
+	// trailing NUL of the Redis string does
+	data := make([]byte, redisHLLDenseSize+1)
+	redisHeader(data, redisHLLDense)
+
+	body := data[redisHLLHeaderSize:]
+	for i, value := range h.registers {
+		redisDenseSet(body, i, value)
+	}
+
+	return data[:redisHLLDenseSize], nil
+}
+
+// MarshalRedisSparse encodes the sketch in Redis's sparse encoding. It fails
+// if a register exceeds 32, the largest value the sparse encoding can hold.
+func (h *HyperLogLog[T]) MarshalRedisSparse() ([]byte, error) {
+	if err := h.checkRedisRegisters(redisSparseValMaxValue); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, redisHLLHeaderSize, redisHLLHeaderSize+64)
+	redisHeader(data, redisHLLSparse)
+
+	for idx := 0; idx < len(h.registers); {
+		value := h.registers[idx]
+		runLen := 1
+		for idx+runLen < len(h.registers) && h.registers[idx+runLen] == value {
+			runLen++
+		}
+		idx += runLen
+
+		for runLen > 0 {
+			switch {
+			case value != 0:
+				n := minInt(runLen, redisSparseValMaxLen)
+				data = append(data, 0x80|(value-1)<<2|byte(n-1))
+				runLen -= n
+			case runLen > redisSparseZeroMaxLen:
+				n := minInt(runLen, redisSparseXZeroMaxLen)
+				data = append(data, 0x40|byte((n-1)>>8), byte(n-1))
+				runLen -= n
+			default:
+				data = append(data, byte(runLen-1))
+				runLen = 0
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// checkRedisRegisters checks that the sketch can be represented by Redis
+func (h *HyperLogLog[T]) checkRedisRegisters(maxValue byte) error {
+	if h.config.NumRegisters != RedisHLLRegisters {
+		return errors.New("sketch must have 16384 registers for the Redis encoding")
+	}
+
+	for _, value := range h.registers {
+		if value > maxValue {
+			return errors.New("register value out of range for the Redis encoding")
+		}
+	}
+	return nil
+}
+
+// redisHeader writes the header of a Redis HyperLogLog. The cached
+// cardinality is marked invalid so that Redis computes its own estimate on
+// the next PFCOUNT.
+func redisHeader(data []byte, encoding byte) {
+	copy(data, "HYLL")
+	data[4] = encoding
+	binary.LittleEndian.PutUint64(data[8:], 1<<63)
+}
+
+// redisDenseGet reads a 6-bit register from the dense representation
+func redisDenseGet(body []byte, idx int) byte {
+	bit := idx * redisHLLBits
+	b, fb := bit/8, uint(bit%8)
+
+	value := uint16(body[b]) >> fb
+	if b+1 < len(body) {
+		value |= uint16(body[b+1]) << (8 - fb)
+	}
+	return byte(value) & 0x3f
+}
+
+// redisDenseSet writes a 6-bit register to the dense representation. The
+// body must have one spare byte past the last register.
+func redisDenseSet(body []byte, idx int, value byte) {
+	bit := idx * redisHLLBits
+	b, fb := bit/8, uint(bit%8)
+
+	body[b] &^= 0x3f << fb
+	body[b] |= value << fb
+	body[b+1] &^= 0x3f >> (8 - fb)
+	body[b+1] |= value >> (8 - fb)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,340 @@
+package ssss
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readFixture reads a file from the testdata directory
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	return data
+}
+
+// redisCaptures lists the values captured by testdata/redis/capture.sh with
+// the elements added to each key. merged is PFMERGE of sparse and other.
+var redisCaptures = []struct {
+	name  string
+	items []string
+	dense bool
+}{
+	{name: "empty"},
+	{name: "abc", items: []string{"a", "b", "c"}},
+	{name: "sparse", items: redisItems(0, 199)},
+	{name: "other", items: redisItems(100, 299)},
+	{name: "dense", items: redisItems(0, 19999), dense: true},
+	{name: "merged", items: redisItems(0, 299)},
+}
+
+// redisItems returns the elements item:first to item:last of capture.sh
+func redisItems(first, last int) []string {
+	items := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		items = append(items, fmt.Sprintf("item:%d", i))
+	}
+	return items
+}
+
+// redisPFCount reads the PFCOUNT of every captured key
+func redisPFCount(t *testing.T) map[string]uint64 {
+	t.Helper()
+
+	counts := make(map[string]uint64)
+	for _, line := range strings.Split(strings.TrimSpace(string(readFixture(t, "redis/pfcount.txt"))), "\n") {
+		var name string
+		var count uint64
+		if _, err := fmt.Sscan(line, &name, &count); err != nil {
+			t.Fatalf("Invalid pfcount.txt line %q: %v", line, err)
+		}
+		counts[name] = count
+	}
+	return counts
+}
+
+// newRedisSparseFixture returns a sparse value with registers 0, 100 to 103
+// and 5000 set, which uses all three opcodes
+func newRedisSparseFixture(t *testing.T) []byte {
+	t.Helper()
+
+	hll := NewHyperLogLog[string](NewRedisHLLConfig())
+	for i, value := range map[int]byte{0: 1, 100: 3, 101: 3, 102: 3, 103: 3, 5000: 32} {
+		hll.registers[i] = value
+	}
+	hll.recompute()
+
+	data, err := hll.MarshalRedisSparse()
+	if err != nil {
+		t.Fatalf("Failed to encode HLL: %v", err)
+	}
+	return data
+}
+
+func TestRedisHyperLogLog(t *testing.T) {
+	// These are regression values of this implementation. The registers of
+	// the captured values below check the hash against Redis itself.
+	t.Run("MurmurHash64A", func(t *testing.T) {
+		vectors := map[string]uint64{
+			"":                0xd8dfea6585bc9732,
+			"a":               0x53d2470a9b43b1a7,
+			"hello":           0x0f656f01eecfe400,
+			"foobar123456789": 0x39fcdf6008aed077,
+			"12345":           0xa1a192864caff970,
+		}
+
+		for input, expected := range vectors {
+			if hash := murmurHash64A([]byte(input), redisHLLSeed); hash != expected {
+				t.Errorf("Expected hash %#x for %q, got %#x", expected, input, hash)
+			}
+		}
+
+		// Integers are hashed as their decimal text, like Redis clients send them
+		if (RedisHasher{}).Hash(12345) != vectors["12345"] {
+			t.Error("Expected integer items to hash as their decimal text")
+		}
+	})
+
+	// The values in testdata/redis are captured from a Redis server by
+	// capture.sh, which records the server version in VERSION
+	t.Run("Captured Values", func(t *testing.T) {
+		if _, err := os.Stat(filepath.Join("testdata", "redis", "VERSION")); err != nil {
+			t.Skip("No captured Redis values, run testdata/redis/capture.sh against a Redis server")
+		}
+
+		counts := redisPFCount(t)
+		for _, capture := range redisCaptures {
+			fixture := readFixture(t, "redis/"+capture.name+".hyll")
+
+			decoded, err := HyperLogLogFromRedis[string](fixture)
+			if err != nil {
+				t.Fatalf("Failed to decode %s: %v", capture.name, err)
+			}
+
+			hll := NewHyperLogLog[string](NewRedisHLLConfig())
+			for _, item := range capture.items {
+				hll.Insert(item)
+			}
+
+			if !bytes.Equal(decoded.registers, hll.registers) {
+				t.Errorf("Registers of %s differ from inserting its elements", capture.name)
+			}
+
+			if relativeError(decoded.Cardinality(), counts[capture.name]) > 0.02 {
+				t.Errorf("Expected cardinality of %s close to PFCOUNT %d, got %d",
+					capture.name, counts[capture.name], decoded.Cardinality())
+			}
+
+			// The cached cardinality of a dense value is invalid, so the
+			// whole value can be reproduced
+			if capture.dense {
+				data, err := hll.MarshalRedisDense()
+				if err != nil {
+					t.Fatalf("Failed to encode HLL: %v", err)
+				}
+
+				if !bytes.Equal(data, fixture) {
+					t.Errorf("Dense encoding differs from %s", capture.name)
+				}
+			}
+		}
+	})
+
+	t.Run("Empty Sparse", func(t *testing.T) {
+		data, err := NewHyperLogLog[string](NewRedisHLLConfig()).MarshalRedis()
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		// A single XZERO opcode covers all registers
+		if !bytes.Equal(data[redisHLLHeaderSize:], []byte{0x7f, 0xff}) || data[4] != redisHLLSparse {
+			t.Errorf("Unexpected empty encoding %x", data)
+		}
+
+		hll, err := HyperLogLogFromRedis[string](data)
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		if hll.Cardinality() != 0 {
+			t.Errorf("Expected cardinality 0, got %d", hll.Cardinality())
+		}
+	})
+
+	t.Run("Sparse Round Trip", func(t *testing.T) {
+		data := newRedisSparseFixture(t)
+
+		// ZERO, VAL, XZERO, VAL, XZERO, VAL, XZERO
+		expected := []byte{0x80, 0x40, 0x62, 0x8b, 0x53, 0x1f, 0xfc, 0x6c, 0x76}
+		if !bytes.Equal(data[redisHLLHeaderSize:], expected) {
+			t.Errorf("Expected sparse opcodes %x, got %x", expected, data[redisHLLHeaderSize:])
+		}
+
+		hll, err := HyperLogLogFromRedis[string](data)
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		expectedRegisters := map[int]byte{0: 1, 100: 3, 101: 3, 102: 3, 103: 3, 5000: 32}
+		for i, value := range hll.registers {
+			if value != expectedRegisters[i] {
+				t.Errorf("Expected register %d to be %d, got %d", i, expectedRegisters[i], value)
+			}
+		}
+	})
+
+	t.Run("Dense Round Trip", func(t *testing.T) {
+		hll := NewHyperLogLog[string](NewRedisHLLConfig())
+		for i := range hll.registers {
+			hll.registers[i] = byte((i*7 + i/3) % 52)
+		}
+		hll.recompute()
+
+		// Registers above 32 force the dense encoding
+		data, err := hll.MarshalRedis()
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		if len(data) != redisHLLDenseSize || data[4] != redisHLLDense {
+			t.Fatalf("Expected a dense value of %d bytes, got %d bytes", redisHLLDenseSize, len(data))
+		}
+
+		decoded, err := HyperLogLogFromRedis[string](data)
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		if !bytes.Equal(decoded.registers, hll.registers) {
+			t.Error("Registers differ after round trip")
+		}
+
+		if _, err := hll.MarshalRedisSparse(); err == nil {
+			t.Error("Expected error encoding large registers as sparse")
+		}
+	})
+
+	t.Run("Dense And Sparse Agree", func(t *testing.T) {
+		hll := NewHyperLogLog[int](NewRedisHLLConfig())
+		for i := 0; i < 2000; i++ {
+			hll.Insert(i)
+		}
+
+		sparse, err := hll.MarshalRedisSparse()
+		if err != nil {
+			t.Fatalf("Failed to encode sparse HLL: %v", err)
+		}
+
+		dense, err := hll.MarshalRedisDense()
+		if err != nil {
+			t.Fatalf("Failed to encode dense HLL: %v", err)
+		}
+
+		for _, data := range [][]byte{sparse, dense} {
+			decoded, err := HyperLogLogFromRedis[int](data)
+			if err != nil {
+				t.Fatalf("Failed to decode HLL: %v", err)
+			}
+
+			if !bytes.Equal(decoded.registers, hll.registers) {
+				t.Error("Registers differ after round trip")
+			}
+		}
+
+		if relativeError(hll.Cardinality(), 2000) > 0.05 {
+			t.Errorf("Expected cardinality close to 2000, got %d", hll.Cardinality())
+		}
+	})
+
+	t.Run("Seed Sketch Label", func(t *testing.T) {
+		config, err := NewConfig(2, NewRedisHLLConfig(), []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, string](config)
+		sketch.Insert("checkout", "d")
+
+		abc := NewHyperLogLog[string](NewRedisHLLConfig())
+		for _, item := range []string{"a", "b", "c"} {
+			abc.Insert(item)
+		}
+
+		data, err := abc.MarshalRedis()
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		imported, err := HyperLogLogFromRedis[string](data)
+		if err != nil {
+			t.Fatalf("Failed to decode HLL: %v", err)
+		}
+
+		if err := sketch.MergeLabel("checkout", imported); err != nil {
+			t.Fatalf("Failed to merge label: %v", err)
+		}
+
+		if sketch.Cardinality("checkout") != 4 {
+			t.Errorf("Expected cardinality 4 after seeding, got %d", sketch.Cardinality("checkout"))
+		}
+
+		exported, ok := sketch.LabelSketch("checkout")
+		if !ok {
+			t.Fatal("Expected label sketch for tracked label")
+		}
+
+		data, err = exported.MarshalRedis()
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		roundTrip, err := HyperLogLogFromRedis[string](data)
+		if err != nil {
+			t.Fatalf("Failed to decode exported HLL: %v", err)
+		}
+
+		if roundTrip.Cardinality() != 4 {
+			t.Errorf("Expected exported cardinality 4, got %d", roundTrip.Cardinality())
+		}
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		sparse := newRedisSparseFixture(t)
+		dense, err := NewHyperLogLog[string](NewRedisHLLConfig()).MarshalRedisDense()
+		if err != nil {
+			t.Fatalf("Failed to encode HLL: %v", err)
+		}
+
+		inputs := map[string][]byte{
+			"short":          []byte("HYLL"),
+			"bad magic":      append([]byte("HYLX"), sparse[4:]...),
+			"bad encoding":   append(append([]byte{}, sparse[:4]...), append([]byte{7}, sparse[5:]...)...),
+			"short sparse":   sparse[:len(sparse)-2],
+			"long sparse":    append(append([]byte{}, sparse...), 0x00),
+			"truncated op":   append(append([]byte{}, sparse[:16]...), 0x40),
+			"bad dense size": dense[:100],
+		}
+
+		for name, input := range inputs {
+			if _, err := HyperLogLogFromRedis[string](input); err == nil {
+				t.Errorf("Expected error decoding %s input", name)
+			}
+		}
+
+		config, err := NewHLLConfig(512, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		if _, err := NewHyperLogLog[string](config).MarshalRedis(); err == nil {
+			t.Error("Expected error encoding a sketch without 16384 registers")
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package ssss
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Number of seeds generated for the sampling estimate and for HyperLogLog
+const (
+	numSeeds    = 4
+	numHLLSeeds = 8
+)
+
+// DeriveSeeds derives n seeds from a master seed, such as the name of a
+// deployment. The same master seed always gives the same seeds, so agents
+// configured with it build mergeable sketches without copying seed slices.
+// Seed i is the first 8 bytes of the SHA-256 of the master seed followed by
+// i as a little-endian uint64.
+func DeriveSeeds(master string, n int) []uint64 {
+	seeds := make([]uint64, n)
+	input := make([]byte, len(master)+8)
+	copy(input, master)
+
+	for i := range seeds {
+		binary.LittleEndian.PutUint64(input[len(master):], uint64(i))
+		sum := sha256.Sum256(input)
+		seeds[i] = binary.LittleEndian.Uint64(sum[:8])
+	}
+
+	return seeds
+}
+
+// ReadSeeds reads n seeds from r, for example a seeded math/rand source in
+// tests or crypto/rand.Reader
+func ReadSeeds(r io.Reader, n int) ([]uint64, error) {
+	buf := make([]byte, 8*n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("failed to read seeds: %w", err)
+	}
+
+	seeds := make([]uint64, n)
+	for i := range seeds {
+		seeds[i] = binary.LittleEndian.Uint64(buf[8*i:])
+	}
+	return seeds, nil
+}
+
+// NewSeededConfig creates a configuration whose sampling and HyperLogLog
+// seeds are all derived from a master seed, see DeriveSeeds
+func NewSeededConfig(maxNumCounters int, numRegisters int, master string) (*Config, error) {
+	hllConfig, err := NewHLLConfig(numRegisters, DeriveSeeds(master+"/hll", numHLLSeeds))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConfig(maxNumCounters, hllConfig, DeriveSeeds(master+"/sampling", numSeeds))
+}
+
+// randomSeeds generates n seeds with crypto/rand
+func randomSeeds(n int) ([]uint64, error) {
+	return ReadSeeds(rand.Reader, n)
+}
@@ -0,0 +1,148 @@
+package ssss
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSeeding(t *testing.T) {
+	t.Run("Derived Seeds Are Stable", func(t *testing.T) {
+		seeds := DeriveSeeds("prod", 2)
+
+		// SHA-256 of "prod" and eight zero bytes starts with cdc18c116efbaeca
+		if seeds[0] != 0xcaaefb6e118cc1cd || seeds[1] != 0xeae7aaeb8992bbf3 {
+			t.Errorf("Derived seeds changed: %#x", seeds)
+		}
+
+		if other := DeriveSeeds("staging", 2); other[0] == seeds[0] || other[1] == seeds[1] {
+			t.Error("Expected different master seeds to derive different seeds")
+		}
+	})
+
+	t.Run("Seeded Configs Merge", func(t *testing.T) {
+		config1, err := NewSeededConfig(10, 256, "prod")
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		config2, err := NewSeededConfig(20, 256, "prod")
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		if config1.Fingerprint() != config2.Fingerprint() {
+			t.Error("Expected configs with the same master seed to have the same fingerprint")
+		}
+
+		sketch1 := NewHLLSamplingSpaceSavingSets[int, int](config1)
+		sketch2 := NewHLLSamplingSpaceSavingSets[int, int](config2)
+		sketch1.Insert(1, 1)
+		sketch2.Insert(1, 2)
+
+		if err := sketch1.Merge(sketch2); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		if sketch1.Cardinality(1) != 2 {
+			t.Errorf("Expected cardinality 2 after merge, got %d", sketch1.Cardinality(1))
+		}
+	})
+
+	t.Run("Seeds From Reader", func(t *testing.T) {
+		seeds1, err := ReadSeeds(rand.New(rand.NewSource(42)), 4)
+		if err != nil {
+			t.Fatalf("Failed to read seeds: %v", err)
+		}
+
+		seeds2, err := ReadSeeds(rand.New(rand.NewSource(42)), 4)
+		if err != nil {
+			t.Fatalf("Failed to read seeds: %v", err)
+		}
+
+		for i := range seeds1 {
+			if seeds1[i] != seeds2[i] {
+				t.Fatalf("Expected the same source to give the same seeds, got %v and %v", seeds1, seeds2)
+			}
+		}
+
+		// A short reader fails instead of panicking
+		if _, err := ReadSeeds(bytes.NewReader(make([]byte, 12)), 2); err == nil {
+			t.Error("Expected error reading seeds from a short reader")
+		}
+	})
+
+	t.Run("Fingerprint", func(t *testing.T) {
+		config, err := NewSeededConfig(10, 256, "prod")
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		fingerprint := config.Fingerprint()
+
+		changes := map[string]func(c *Config){
+			"seeds":             func(c *Config) { c.Seeds = DeriveSeeds("other", 4) },
+			"HLL seeds":         func(c *Config) { c.CardinalitySketchConfig.Seeds = DeriveSeeds("other", 8) },
+			"registers":         func(c *Config) { c.CardinalitySketchConfig.NumRegisters = 512 },
+			"track totals":      func(c *Config) { c.TrackTotals = true },
+			"track frequencies": func(c *Config) { c.TrackFrequencies = true },
+		}
+
+		for name, change := range changes {
+			changed := *config
+			hllConfig := *config.CardinalitySketchConfig
+			changed.CardinalitySketchConfig = &hllConfig
+			change(&changed)
+
+			if changed.Fingerprint() == fingerprint {
+				t.Errorf("Expected fingerprint to change with %s", name)
+			}
+		}
+
+		resized := *config
+		resized.MaxNumCounters = 100
+		if resized.Fingerprint() != fingerprint {
+			t.Error("Expected fingerprint to ignore MaxNumCounters")
+		}
+	})
+
+	t.Run("Fingerprint Is Checked When Decoding", func(t *testing.T) {
+		config, err := NewSeededConfig(10, 16, "prod")
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		data, err := json.Marshal(config)
+		if err != nil {
+			t.Fatalf("Failed to marshal config: %v", err)
+		}
+
+		// Editing a seed without the fingerprint is detected
+		seed := config.Seeds[0]
+		tampered := strings.Replace(string(data), strconv.FormatUint(seed, 10), strconv.FormatUint(seed+1, 10), 1)
+
+		var decoded Config
+		if err := json.Unmarshal([]byte(tampered), &decoded); err == nil {
+			t.Error("Expected error decoding JSON with a mismatched fingerprint")
+		}
+
+		p, err := config.ToProto()
+		if err != nil {
+			t.Fatalf("Failed to convert config to protobuf: %v", err)
+		}
+
+		p.Seeds[0]++
+		if _, err := ConfigFromProto(p); err == nil {
+			t.Error("Expected error decoding protobuf with a mismatched fingerprint")
+		}
+
+		// Messages from other implementations may omit the fingerprint
+		p.Fingerprint = 0
+		if _, err := ConfigFromProto(p); err != nil {
+			t.Errorf("Failed to decode protobuf without fingerprint: %v", err)
+		}
+	})
+}
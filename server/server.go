@@ -0,0 +1,495 @@
+// Package server provides an embeddable HTTP handler that aggregates
+// serialized SamplingSpaceSavingSets sketches posted by many agents.
+//
+// Agents encode their local sketch with MarshalBinary and POST it to
+// /sketches. The handler merges every sketch it receives into one aggregate
+// per tenant and time window, and serves the merged results as JSON:
+//
+//	POST /sketches?tenant=t&window=1700000000
+//	GET  /top?tenant=t&window=1700000000&k=10
+//	GET  /cardinality?tenant=t&window=1700000000&label=l
+//
+// The window parameter is a Unix timestamp in seconds and defaults to the
+// current window. Queries truncate it to the configured window size. Posted
+// sketches must name the start of a window, and that window may be at most
+// one window ahead of the current one, so a client cannot push the retained
+// windows of its tenant out with windows in the future.
+//
+// The aggregates are built with a configuration set on the server. Posted
+// sketches must have exactly the same configuration, so agents cannot choose
+// the size of the aggregates.
+//
+// Labels are exchanged in their text form, so agents may use any label type
+// supported by the binary encoding; the aggregate holds string labels.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sawmills/go-ssss"
+)
+
+// Default values used when the corresponding Options field is zero
+const (
+	DefaultWindowSize   = time.Minute
+	DefaultMaxBodyBytes = 1 << 20
+	DefaultMaxWindows   = 60
+	DefaultMaxTenants   = 1000
+	DefaultTopK         = 10
+)
+
+var (
+	// ErrTooManyTenants is returned when a sketch is merged for a new tenant
+	// while MaxTenants tenants are already aggregated
+	ErrTooManyTenants = errors.New("too many tenants")
+	// ErrStaleWindow is returned when a sketch is merged for a window older
+	// than all the windows retained for its tenant
+	ErrStaleWindow = errors.New("window is no longer retained")
+	// ErrUnalignedWindow is returned when a sketch is merged for a time that
+	// is not the start of a window
+	ErrUnalignedWindow = errors.New("window is not aligned to the window size")
+	// ErrFutureWindow is returned when a sketch is merged for a window more
+	// than one window ahead of the current one
+	ErrFutureWindow = errors.New("window is in the future")
+)
+
+// Options configures a Handler
+type Options struct {
+	// WindowSize is the length of the aggregation windows
+	WindowSize time.Duration
+	// MaxBodyBytes is the maximum accepted size of a posted sketch
+	MaxBodyBytes int64
+	// MaxWindows is the number of windows retained per tenant. When a new
+	// window is created the oldest one is dropped.
+	MaxWindows int
+	// MaxTenants is the number of tenants that can be aggregated. Sketches
+	// for further tenants are rejected.
+	MaxTenants int
+	// Authorize is called for every request with the requested tenant. A
+	// non-nil error rejects the request with 401 Unauthorized.
+	Authorize func(r *http.Request, tenant string) error
+	// Now returns the current time and defaults to time.Now
+	Now func() time.Time
+}
+
+// LabelCount is the JSON representation of a label and its cardinality
+type LabelCount struct {
+	Label string `json:"label"`
+	Count uint64 `json:"count"`
+}
+
+// TopResponse is the body returned by GET /top
+type TopResponse struct {
+	Tenant string       `json:"tenant"`
+	Window int64        `json:"window"`
+	Top    []LabelCount `json:"top"`
+}
+
+// CardinalityResponse is the body returned by GET /cardinality
+type CardinalityResponse struct {
+	Tenant      string `json:"tenant"`
+	Window      int64  `json:"window"`
+	Label       string `json:"label"`
+	Cardinality uint64 `json:"cardinality"`
+}
+
+// Handler merges posted sketches per tenant and window and serves queries
+// over the merged sketches. It is safe for concurrent use.
+type Handler struct {
+	config *ssss.Config
+	opts   Options
+	mux    *http.ServeMux
+
+	mu      sync.Mutex
+	tenants map[string]map[int64]*ssss.SamplingSpaceSavingSets[string, string]
+}
+
+// NewHandler creates a new Handler whose aggregates use the given
+// configuration. Only sketches with the same configuration are accepted.
+func NewHandler(config *ssss.Config, opts Options) (*Handler, error) {
+	if config == nil {
+		return nil, errors.New("missing config")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	if opts.WindowSize <= 0 {
+		opts.WindowSize = DefaultWindowSize
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if opts.MaxWindows <= 0 {
+		opts.MaxWindows = DefaultMaxWindows
+	}
+	if opts.MaxTenants <= 0 {
+		opts.MaxTenants = DefaultMaxTenants
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	h := &Handler{
+		config:  config,
+		opts:    opts,
+		mux:     http.NewServeMux(),
+		tenants: make(map[string]map[int64]*ssss.SamplingSpaceSavingSets[string, string]),
+	}
+
+	h.mux.HandleFunc("/sketches", h.handleSketches)
+	h.mux.HandleFunc("/top", h.handleTop)
+	h.mux.HandleFunc("/cardinality", h.handleCardinality)
+
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Merge merges a sketch into the aggregate for the given tenant and window
+// start. The sketch is copied and may be reused by the caller. The window
+// must be aligned to the window size and at most one window ahead of the
+// current one.
+func (h *Handler) Merge(tenant string, window int64, sketch *ssss.SamplingSpaceSavingSets[string, string]) error {
+	data, err := sketch.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return h.merge(tenant, window, data)
+}
+
+// Sketch returns a copy of the aggregate for the given tenant and window
+// start, or nil if no sketch has been received for it
+func (h *Handler) Sketch(tenant string, window int64) *ssss.SamplingSpaceSavingSets[string, string] {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	aggregate := h.tenants[tenant][h.truncate(window)]
+	if aggregate == nil {
+		return nil
+	}
+
+	data, err := aggregate.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+
+	sketch, err := h.decode(data)
+	if err != nil {
+		return nil
+	}
+	return sketch
+}
+
+func (h *Handler) handleSketches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenant, window, ok := h.params(w, r)
+	if !ok {
+		return
+	}
+
+	// Read one byte past the limit to detect oversized bodies
+	data, err := io.ReadAll(io.LimitReader(r.Body, h.opts.MaxBodyBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > h.opts.MaxBodyBytes {
+		http.Error(w, "sketch too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := h.merge(tenant, window, data); err != nil {
+		switch {
+		case errors.Is(err, ErrStaleWindow):
+			http.Error(w, err.Error(), http.StatusGone)
+		case errors.Is(err, ErrUnalignedWindow), errors.Is(err, ErrFutureWindow):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, ErrTooManyTenants):
+			http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleTop(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	tenant, window, ok := h.params(w, r)
+	if !ok {
+		return
+	}
+	window = h.truncate(window)
+
+	k := DefaultTopK
+	if value := r.URL.Query().Get("k"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "invalid k", http.StatusBadRequest)
+			return
+		}
+		k = parsed
+	}
+
+	response := TopResponse{
+		Tenant: tenant,
+		Window: window,
+		Top:    []LabelCount{},
+	}
+
+	h.mu.Lock()
+	if aggregate := h.tenants[tenant][window]; aggregate != nil {
+		for _, entry := range aggregate.Top(k) {
+			response.Top = append(response.Top, LabelCount{Label: entry.Label, Count: entry.Count})
+		}
+	}
+	h.mu.Unlock()
+
+	writeJSON(w, response)
+}
+
+func (h *Handler) handleCardinality(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	tenant, window, ok := h.params(w, r)
+	if !ok {
+		return
+	}
+	window = h.truncate(window)
+
+	if !r.URL.Query().Has("label") {
+		http.Error(w, "missing label", http.StatusBadRequest)
+		return
+	}
+
+	response := CardinalityResponse{
+		Tenant: tenant,
+		Window: window,
+		Label:  r.URL.Query().Get("label"),
+	}
+
+	h.mu.Lock()
+	if aggregate := h.tenants[tenant][window]; aggregate != nil {
+		response.Cardinality = aggregate.Cardinality(response.Label)
+	}
+	h.mu.Unlock()
+
+	writeJSON(w, response)
+}
+
+// params parses and authorizes the tenant and window of a request. It writes
+// an error response and returns false if the request should not proceed. The
+// window defaults to the start of the current window.
+func (h *Handler) params(w http.ResponseWriter, r *http.Request) (string, int64, bool) {
+	query := r.URL.Query()
+
+	tenant := query.Get("tenant")
+	if tenant == "" {
+		http.Error(w, "missing tenant", http.StatusBadRequest)
+		return "", 0, false
+	}
+
+	if h.opts.Authorize != nil {
+		if err := h.opts.Authorize(r, tenant); err != nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return "", 0, false
+		}
+	}
+
+	window := h.truncate(h.opts.Now().Unix())
+	if value := query.Get("window"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid window", http.StatusBadRequest)
+			return "", 0, false
+		}
+		window = parsed
+	}
+
+	return tenant, window, true
+}
+
+// truncate returns the start of the window containing the Unix time t
+func (h *Handler) truncate(t int64) int64 {
+	size := int64(h.opts.WindowSize / time.Second)
+	if size <= 1 {
+		return t
+	}
+
+	start := t - t%size
+	if t < 0 && t%size != 0 {
+		start -= size
+	}
+	return start
+}
+
+// decode decodes a sketch and gives it the hash key of the server config,
+// which is never encoded, if it uses keyed hashing
+func (h *Handler) decode(data []byte) (*ssss.SamplingSpaceSavingSets[string, string], error) {
+	sketch := new(ssss.SamplingSpaceSavingSets[string, string])
+	if err := sketch.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+
+	if sketch.Config().KeyedHashing && h.config.KeyedHashing {
+		if err := sketch.SetHashKey(h.config.HashKey[0], h.config.HashKey[1]); err != nil {
+			return nil, err
+		}
+	}
+	return sketch, nil
+}
+
+// merge decodes a sketch and merges it into the aggregate for the tenant and window
+func (h *Handler) merge(tenant string, window int64, data []byte) error {
+	if err := h.checkWindow(window); err != nil {
+		return err
+	}
+
+	sketch, err := h.decode(data)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkConfig(sketch.Config()); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	windows, exists := h.tenants[tenant]
+	if !exists && len(h.tenants) >= h.opts.MaxTenants {
+		return ErrTooManyTenants
+	}
+
+	if aggregate, exists := windows[window]; exists {
+		return aggregate.Merge(sketch)
+	}
+
+	// A new window older than all retained ones would be dropped right away
+	if len(windows) >= h.opts.MaxWindows && window < oldest(windows) {
+		return ErrStaleWindow
+	}
+
+	aggregate := ssss.NewHLLSamplingSpaceSavingSets[string, string](h.config)
+	if err := aggregate.Merge(sketch); err != nil {
+		return err
+	}
+
+	if windows == nil {
+		windows = make(map[int64]*ssss.SamplingSpaceSavingSets[string, string])
+		h.tenants[tenant] = windows
+	}
+	windows[window] = aggregate
+	h.evict(windows)
+	return nil
+}
+
+// checkConfig checks that a posted sketch has the configuration of the
+// aggregates. Unlike merges, it also requires the same capacity.
+func (h *Handler) checkConfig(config *ssss.Config) error {
+	if err := h.config.CheckCompatible(config); err != nil {
+		return err
+	}
+
+	switch {
+	case config.MaxNumCounters != h.config.MaxNumCounters:
+		return &ssss.ConfigMismatchError{Field: "MaxNumCounters"}
+	case config.SubsetSampleSize != h.config.SubsetSampleSize:
+		return &ssss.ConfigMismatchError{Field: "SubsetSampleSize"}
+	}
+	return nil
+}
+
+// checkWindow checks that a window is aligned to the window size and starts
+// at most one window after the current one. Otherwise a client could evict
+// the retained windows of its tenant with windows in the future.
+func (h *Handler) checkWindow(window int64) error {
+	if h.truncate(window) != window {
+		return ErrUnalignedWindow
+	}
+
+	size := int64(h.opts.WindowSize / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	if window > h.truncate(h.opts.Now().Unix())+size {
+		return ErrFutureWindow
+	}
+	return nil
+}
+
+// evict drops the oldest windows of a tenant beyond MaxWindows
+func (h *Handler) evict(windows map[int64]*ssss.SamplingSpaceSavingSets[string, string]) {
+	if len(windows) <= h.opts.MaxWindows {
+		return
+	}
+
+	starts := make([]int64, 0, len(windows))
+	for start := range windows {
+		starts = append(starts, start)
+	}
+
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] < starts[j]
+	})
+
+	for _, start := range starts[:len(starts)-h.opts.MaxWindows] {
+		delete(windows, start)
+	}
+}
+
+// oldest returns the start of the oldest window
+func oldest(windows map[int64]*ssss.SamplingSpaceSavingSets[string, string]) int64 {
+	first := true
+	var start int64
+	for window := range windows {
+		if first || window < start {
+			start = window
+			first = false
+		}
+	}
+	return start
+}
+
+// allowGet rejects requests that are not GET requests
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return true
+	}
+
+	w.Header().Set("Allow", http.MethodGet)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
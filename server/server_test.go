@@ -0,0 +1,403 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sawmills/go-ssss"
+)
+
+// newConfig creates the configuration shared by the server and all agents
+func newConfig(t *testing.T) *ssss.Config {
+	t.Helper()
+
+	hllConfig, err := ssss.NewHLLConfig(512, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+	if err != nil {
+		t.Fatalf("Failed to create HLL config: %v", err)
+	}
+
+	config, err := ssss.NewConfig(10, hllConfig, []uint64{0, 1, 2, 3})
+	if err != nil {
+		t.Fatalf("Failed to create SSSS config: %v", err)
+	}
+
+	return config
+}
+
+// newSketch creates an agent sketch with the shared configuration
+func newSketch(t *testing.T) *ssss.SamplingSpaceSavingSets[string, string] {
+	t.Helper()
+
+	return ssss.NewHLLSamplingSpaceSavingSets[string, string](newConfig(t))
+}
+
+// newHandler creates a handler with the shared configuration
+func newHandler(t *testing.T, opts Options) *Handler {
+	t.Helper()
+
+	handler, err := NewHandler(newConfig(t), opts)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	return handler
+}
+
+// post sends a sketch to the server and returns the response status
+func post(t *testing.T, url string, sketch *ssss.SamplingSpaceSavingSets[string, string]) int {
+	t.Helper()
+
+	data, err := sketch.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to marshal sketch: %v", err)
+	}
+
+	resp, err := http.Post(url, "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to post sketch: %v", err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+// get fetches a JSON response from the server
+func get(t *testing.T, url string, v any) int {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Failed to get %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func TestHandler(t *testing.T) {
+	now := time.Unix(1700000030, 0)
+	newServer := func(opts Options) *httptest.Server {
+		opts.Now = func() time.Time { return now }
+		return httptest.NewServer(newHandler(t, opts))
+	}
+
+	t.Run("Merge Per Tenant And Window", func(t *testing.T) {
+		srv := newServer(Options{WindowSize: time.Minute})
+		defer srv.Close()
+
+		// Two agents see overlapping labels
+		agent1 := newSketch(t)
+		agent2 := newSketch(t)
+		for i := 0; i < 100; i++ {
+			agent1.Insert("api", fmt.Sprintf("user-%d", i))
+			agent2.Insert("api", fmt.Sprintf("user-%d", i+100))
+			agent2.Insert("web", fmt.Sprintf("user-%d", i%20))
+		}
+
+		for _, agent := range []*ssss.SamplingSpaceSavingSets[string, string]{agent1, agent2} {
+			status := post(t, srv.URL+"/sketches?tenant=acme&window=1699999980", agent)
+			if status != http.StatusNoContent {
+				t.Fatalf("Expected status %d, got %d", http.StatusNoContent, status)
+			}
+		}
+
+		var top TopResponse
+		if status := get(t, srv.URL+"/top?tenant=acme&window=1700000039&k=1", &top); status != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, status)
+		}
+
+		if top.Window != 1699999980 {
+			t.Errorf("Expected window to be truncated to 1699999980, got %d", top.Window)
+		}
+
+		if len(top.Top) != 1 || top.Top[0].Label != "api" {
+			t.Fatalf("Expected top label api, got %v", top.Top)
+		}
+
+		if top.Top[0].Count < 160 || top.Top[0].Count > 240 {
+			t.Errorf("Expected merged cardinality close to 200, got %d", top.Top[0].Count)
+		}
+
+		// The window defaults to the current one
+		var cardinality CardinalityResponse
+		if status := get(t, srv.URL+"/cardinality?tenant=acme&label=web", &cardinality); status != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, status)
+		}
+
+		if cardinality.Cardinality < 15 || cardinality.Cardinality > 25 {
+			t.Errorf("Expected cardinality close to 20 for web, got %d", cardinality.Cardinality)
+		}
+
+		// Other tenants and windows are unaffected
+		if status := get(t, srv.URL+"/top?tenant=other", &top); status != http.StatusOK || len(top.Top) != 0 {
+			t.Errorf("Expected empty top for another tenant, got %v", top.Top)
+		}
+
+		if status := get(t, srv.URL+"/top?tenant=acme&window=1700000060", &top); status != http.StatusOK || len(top.Top) != 0 {
+			t.Errorf("Expected empty top for another window, got %v", top.Top)
+		}
+	})
+
+	t.Run("Authorization", func(t *testing.T) {
+		srv := newServer(Options{
+			Authorize: func(r *http.Request, tenant string) error {
+				if r.Header.Get("Authorization") != "Bearer "+tenant {
+					return errors.New("invalid token")
+				}
+				return nil
+			},
+		})
+		defer srv.Close()
+
+		if status := post(t, srv.URL+"/sketches?tenant=acme", newSketch(t)); status != http.StatusUnauthorized {
+			t.Errorf("Expected status %d without token, got %d", http.StatusUnauthorized, status)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/top?tenant=acme", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer acme")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status %d with token, got %d", http.StatusOK, resp.StatusCode)
+		}
+	})
+
+	t.Run("Request Size Limit", func(t *testing.T) {
+		srv := newServer(Options{MaxBodyBytes: 64})
+		defer srv.Close()
+
+		sketch := newSketch(t)
+		sketch.Insert("api", "user")
+
+		if status := post(t, srv.URL+"/sketches?tenant=acme", sketch); status != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, status)
+		}
+	})
+
+	t.Run("Invalid Requests", func(t *testing.T) {
+		srv := newServer(Options{})
+		defer srv.Close()
+
+		resp, err := http.Post(srv.URL+"/sketches?tenant=acme", "application/octet-stream",
+			bytes.NewReader([]byte("garbage")))
+		if err != nil {
+			t.Fatalf("Failed to post: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status %d for garbage body, got %d", http.StatusBadRequest, resp.StatusCode)
+		}
+
+		var top TopResponse
+		for _, path := range []string{
+			"/top",
+			"/top?tenant=acme&k=-1",
+			"/top?tenant=acme&window=soon",
+			"/cardinality?tenant=acme",
+		} {
+			if status := get(t, srv.URL+path, &top); status != http.StatusBadRequest {
+				t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, path, status)
+			}
+		}
+
+		if status := get(t, srv.URL+"/sketches?tenant=acme", &top); status != http.StatusMethodNotAllowed {
+			t.Errorf("Expected status %d for GET /sketches, got %d", http.StatusMethodNotAllowed, status)
+		}
+	})
+
+	t.Run("Config Mismatch", func(t *testing.T) {
+		srv := newServer(Options{})
+		defer srv.Close()
+
+		if status := post(t, srv.URL+"/sketches?tenant=acme", newSketch(t)); status != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, status)
+		}
+
+		hllConfig, err := ssss.NewHLLConfig(256, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := ssss.NewConfig(10, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		other := ssss.NewHLLSamplingSpaceSavingSets[string, string](config)
+		if status := post(t, srv.URL+"/sketches?tenant=acme", other); status != http.StatusBadRequest {
+			t.Errorf("Expected status %d for mismatched config, got %d", http.StatusBadRequest, status)
+		}
+
+		// The first sketch of a window must match as well
+		if status := post(t, srv.URL+"/sketches?tenant=other", other); status != http.StatusBadRequest {
+			t.Errorf("Expected status %d for mismatched config in a new window, got %d", http.StatusBadRequest, status)
+		}
+
+		// Agents cannot choose the capacity of the aggregate
+		config = newConfig(t)
+		config.MaxNumCounters = 1 << 20
+
+		larger := ssss.NewHLLSamplingSpaceSavingSets[string, string](config)
+		if status := post(t, srv.URL+"/sketches?tenant=acme", larger); status != http.StatusBadRequest {
+			t.Errorf("Expected status %d for a different capacity, got %d", http.StatusBadRequest, status)
+		}
+
+		if _, err := NewHandler(nil, Options{}); err == nil {
+			t.Error("Expected an error for a handler without config")
+		}
+	})
+
+	t.Run("Tenant Limit", func(t *testing.T) {
+		srv := newServer(Options{MaxTenants: 2})
+		defer srv.Close()
+
+		for _, tenant := range []string{"acme", "globex"} {
+			if status := post(t, srv.URL+"/sketches?tenant="+tenant, newSketch(t)); status != http.StatusNoContent {
+				t.Fatalf("Expected status %d for tenant %s, got %d", http.StatusNoContent, tenant, status)
+			}
+		}
+
+		if status := post(t, srv.URL+"/sketches?tenant=initech", newSketch(t)); status != http.StatusInsufficientStorage {
+			t.Errorf("Expected status %d beyond the tenant limit, got %d", http.StatusInsufficientStorage, status)
+		}
+
+		// Known tenants can still post
+		if status := post(t, srv.URL+"/sketches?tenant=acme", newSketch(t)); status != http.StatusNoContent {
+			t.Errorf("Expected status %d for a known tenant, got %d", http.StatusNoContent, status)
+		}
+	})
+
+	t.Run("Stale Window", func(t *testing.T) {
+		srv := newServer(Options{WindowSize: time.Minute, MaxWindows: 2})
+		defer srv.Close()
+
+		for _, window := range []string{"120", "180"} {
+			if status := post(t, srv.URL+"/sketches?tenant=acme&window="+window, newSketch(t)); status != http.StatusNoContent {
+				t.Fatalf("Expected status %d for window %s, got %d", http.StatusNoContent, window, status)
+			}
+		}
+
+		if status := post(t, srv.URL+"/sketches?tenant=acme&window=60", newSketch(t)); status != http.StatusGone {
+			t.Errorf("Expected status %d for a stale window, got %d", http.StatusGone, status)
+		}
+
+		// Retained and newer windows are accepted
+		for _, window := range []string{"120", "240"} {
+			if status := post(t, srv.URL+"/sketches?tenant=acme&window="+window, newSketch(t)); status != http.StatusNoContent {
+				t.Errorf("Expected status %d for window %s, got %d", http.StatusNoContent, window, status)
+			}
+		}
+	})
+
+	t.Run("Future Window", func(t *testing.T) {
+		handler := newHandler(t, Options{
+			WindowSize: time.Minute,
+			MaxWindows: 2,
+			Now:        func() time.Time { return now },
+		})
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		for _, window := range []string{"1699999920", "1699999980"} {
+			if status := post(t, srv.URL+"/sketches?tenant=acme&window="+window, newSketch(t)); status != http.StatusNoContent {
+				t.Fatalf("Expected status %d for window %s, got %d", http.StatusNoContent, window, status)
+			}
+		}
+
+		// Windows far ahead or between window starts are rejected
+		for _, window := range []string{"1700000100", "4102444800", "1699999990"} {
+			if status := post(t, srv.URL+"/sketches?tenant=acme&window="+window, newSketch(t)); status != http.StatusUnprocessableEntity {
+				t.Errorf("Expected status %d for window %s, got %d", http.StatusUnprocessableEntity, window, status)
+			}
+		}
+
+		if err := handler.Merge("acme", 4102444800, newSketch(t)); !errors.Is(err, ErrFutureWindow) {
+			t.Errorf("Expected ErrFutureWindow, got %v", err)
+		}
+
+		if err := handler.Merge("acme", 1699999990, newSketch(t)); !errors.Is(err, ErrUnalignedWindow) {
+			t.Errorf("Expected ErrUnalignedWindow, got %v", err)
+		}
+
+		for _, window := range []int64{1699999920, 1699999980} {
+			if handler.Sketch("acme", window) == nil {
+				t.Errorf("Expected window %d to be retained", window)
+			}
+		}
+
+		// The next window is accepted to allow for clock skew
+		if status := post(t, srv.URL+"/sketches?tenant=acme&window=1700000040", newSketch(t)); status != http.StatusNoContent {
+			t.Errorf("Expected status %d for the next window, got %d", http.StatusNoContent, status)
+		}
+	})
+
+	t.Run("Keyed Hashing", func(t *testing.T) {
+		config, err := ssss.NewConfigWithOptions(10, ssss.WithKeyedHashing(3, 4))
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+
+		handler, err := NewHandler(config, Options{})
+		if err != nil {
+			t.Fatalf("Failed to create handler: %v", err)
+		}
+
+		// The key is not encoded, so the handler supplies its own
+		sketch := ssss.NewHLLSamplingSpaceSavingSets[string, string](config)
+		sketch.Insert("api", "user")
+		if err := handler.Merge("acme", 0, sketch); err != nil {
+			t.Fatalf("Failed to merge keyed sketch: %v", err)
+		}
+
+		aggregate := handler.Sketch("acme", 0)
+		if aggregate == nil || aggregate.Config().HashKey != config.HashKey {
+			t.Fatal("Expected a copy of the aggregate with the hash key")
+		}
+
+		aggregate.Insert("api", "user")
+		if cardinality := aggregate.Cardinality("api"); cardinality != 1 {
+			t.Errorf("Expected cardinality 1 after inserting the same item, got %d", cardinality)
+		}
+	})
+
+	t.Run("Window Retention", func(t *testing.T) {
+		handler := newHandler(t, Options{WindowSize: time.Minute, MaxWindows: 2})
+
+		for _, window := range []int64{0, 60, 120} {
+			sketch := newSketch(t)
+			sketch.Insert("api", "user")
+			if err := handler.Merge("acme", window, sketch); err != nil {
+				t.Fatalf("Failed to merge sketch: %v", err)
+			}
+		}
+
+		if handler.Sketch("acme", 0) != nil {
+			t.Error("Expected the oldest window to be dropped")
+		}
+
+		if handler.Sketch("acme", 120) == nil {
+			t.Error("Expected the newest window to be retained")
+		}
+	})
+}
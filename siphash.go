@@ -0,0 +1,168 @@
+package ssss
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// SipHasher hashes items with SipHash-2-4 under a secret 128-bit key, so
+// that without the key no one can craft items that land in chosen registers
+// or get large trailing-zeros estimates. Items are hashed in the form of
+// itemText. See WithKeyedHashing, which keys it with Config.HashKey.
+//
+// The encodings record that a SipHasher is used but never its key, so
+// decoded configurations get a SipHasher with a zero key, which does not
+// validate until the key is set again, see SetHashKey.
+type SipHasher struct {
+	Key0 uint64
+	Key1 uint64
+}
+
+// Hash returns the keyed hash of the item
+func (h SipHasher) Hash(item any) uint64 {
+	return sipHash24(h.Key0, h.Key1, itemText(item))
+}
+
+// Tweaks of HashKey for the keyed hashes other than those of the cardinality
+// sketches, so that each use hashes independently of the others
+const (
+	admissionKeyTweak = 0x61646d697373696f // "admissio"
+	pairKeyTweak      = 0x7061697273616d70 // "pairsamp"
+)
+
+// tweakedKey returns HashKey with a tweak applied to its first half
+func (c *Config) tweakedKey(tweak uint64) [2]uint64 {
+	return [2]uint64{c.HashKey[0] ^ tweak, c.HashKey[1]}
+}
+
+// keyedPairHash hashes a (label, item) pair with SipHash-2-4. The label is
+// prefixed with its length, so that no two pairs hash the same text.
+func keyedPairHash(key [2]uint64, label, item any) uint64 {
+	text := itemText(label)
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], uint64(len(text)))
+
+	data := make([]byte, 0, n+len(text)+8)
+	data = append(data, b[:n]...)
+	data = append(data, text...)
+	data = append(data, itemText(item)...)
+	return sipHash24(key[0], key[1], data)
+}
+
+// errMissingHashKey reports a keyed configuration without its key, which
+// decoders accept since the key is never encoded
+var errMissingHashKey = errors.New("missing hash key, which is not encoded")
+
+// keyCardinalitySketches gives the cardinality sketches of a configuration
+// with KeyedHashing a SipHasher keyed with HashKey, unless they have a Hasher
+func (c *Config) keyCardinalitySketches() {
+	hll := c.CardinalitySketchConfig
+	if c.KeyedHashing && hll.Hasher == nil {
+		hll.Hasher = SipHasher{Key0: c.HashKey[0], Key1: c.HashKey[1]}
+	}
+}
+
+// setHashKey sets the key of keyed hashing and of a SipHasher without one
+func (c *Config) setHashKey(key [2]uint64) error {
+	if c.HashKey != ([2]uint64{}) && c.HashKey != key {
+		return errors.New("config has a different hash key")
+	}
+
+	if err := c.CardinalitySketchConfig.setHashKey(key); err != nil {
+		return err
+	}
+
+	c.HashKey = key
+	return nil
+}
+
+// setHashKey keys a SipHasher without a key
+func (c *HLLConfig) setHashKey(key [2]uint64) error {
+	if key == ([2]uint64{}) {
+		return errors.New("hash key must not be zero")
+	}
+
+	keyed := SipHasher{Key0: key[0], Key1: key[1]}
+	switch c.Hasher {
+	case SipHasher{}:
+		c.Hasher = keyed
+	case keyed:
+	default:
+		if _, ok := c.Hasher.(SipHasher); ok {
+			return errors.New("cardinality sketch config has a different hash key")
+		}
+	}
+	return nil
+}
+
+// SetHashKey supplies the secret key of keyed hashing to a decoded sketch,
+// see Config.HashKey, and keys its cardinality sketches if they use a
+// SipHasher. It sets the key on the configuration of the sketch, which
+// decoded sketches own, and must be called before inserting. It fails if the
+// sketch already has a different key.
+func (s *SamplingSpaceSavingSets[L, T]) SetHashKey(key0, key1 uint64) error {
+	return s.config.setHashKey([2]uint64{key0, key1})
+}
+
+// SetHashKey supplies the key of the SipHasher of a decoded sketch. It must
+// be called before inserting, and fails if the sketch already has a
+// different key.
+func (h *HyperLogLog[T]) SetHashKey(key0, key1 uint64) error {
+	return h.config.setHashKey([2]uint64{key0, key1})
+}
+
+// sipHash24 is SipHash-2-4 by Aumasson and Bernstein, with the key given as
+// two little-endian halves
+func sipHash24(k0, k1 uint64, data []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	// The last block holds the remaining bytes and the length in its top byte
+	last := uint64(len(data)) << 56
+	for len(data) >= 8 {
+		m := binary.LittleEndian.Uint64(data)
+		data = data[8:]
+
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+
+	for i, b := range data {
+		last |= uint64(b) << (8 * i)
+	}
+
+	v3 ^= last
+	round()
+	round()
+	v0 ^= last
+
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+
+	return v0 ^ v1 ^ v2 ^ v3
+}
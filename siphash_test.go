@@ -0,0 +1,312 @@
+package ssss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// craftItem returns an item starting with prefix whose unkeyed FNV-1a hash
+// has the given low bits, as an attacker who knows the hash function can
+func craftItem(prefix string, low uint64, numBits uint) string {
+	mask := uint64(1)<<numBits - 1
+	for i := 0; ; i++ {
+		item := prefix + strconv.Itoa(i)
+		h := fnv.New64a()
+		h.Write([]byte(item))
+		if h.Sum64()&mask == low {
+			return item
+		}
+	}
+}
+
+func TestKeyedHashing(t *testing.T) {
+	// The key is secret to the attacker, the seeds need not be
+	r := rand.New(rand.NewSource(7))
+	seeds, err := ReadSeeds(r, 4)
+	if err != nil {
+		t.Fatalf("Failed to read seeds: %v", err)
+	}
+	hllSeeds, err := ReadSeeds(r, 8)
+	if err != nil {
+		t.Fatalf("Failed to read seeds: %v", err)
+	}
+	key, err := ReadSeeds(r, 2)
+	if err != nil {
+		t.Fatalf("Failed to read key: %v", err)
+	}
+
+	newConfig := func(t *testing.T, opts ...Option) *Config {
+		opts = append([]Option{WithPrecision(8), WithSeeds(seeds), WithHLLSeeds(hllSeeds)}, opts...)
+		config, err := NewConfigWithOptions(20, opts...)
+		if err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+		return config
+	}
+
+	t.Run("SipHash Test Vectors", func(t *testing.T) {
+		// Vectors of the reference implementation, with the key 00..0f and
+		// the message 00..(n-1)
+		const k0, k1 = 0x0706050403020100, 0x0f0e0d0c0b0a0908
+		vectors := map[int]uint64{
+			0:  0x726fdb47dd0e0e31,
+			7:  0xab0200f58b01d137,
+			8:  0x93f5f5799a932462,
+			15: 0xa129ca6149be45e5,
+		}
+
+		for n, want := range vectors {
+			message := make([]byte, n)
+			for i := range message {
+				message[i] = byte(i)
+			}
+
+			if got := sipHash24(k0, k1, message); got != want {
+				t.Errorf("Expected SipHash of %d bytes to be %x, got %x", n, want, got)
+			}
+		}
+	})
+
+	t.Run("Crafted Items Don't Win Admission", func(t *testing.T) {
+		// Unkeyed, items whose FNV-1a hashes share their low bits have the
+		// same trailing zeros under every seed. Once a probe reveals bits
+		// that win admission, every item crafted with them wins too.
+		const numBits = 12
+		const numCrafted = 100
+
+		attack := func(t *testing.T, config *Config) int {
+			sketch := NewHLLSamplingSpaceSavingSets[string, string](config)
+			for i := 0; i < 50; i++ {
+				for label := 0; label < config.MaxNumCounters; label++ {
+					sketch.Insert(fmt.Sprintf("heavy-%d", label), fmt.Sprintf("item-%d-%d", label, i))
+				}
+			}
+			_, minCardinality := sketch.minCounter()
+
+			// Probe bit patterns until one is admitted, as an attacker
+			// watching the tracked labels would
+			winning := uint64(1 << numBits)
+			for low := uint64(0); low < 1<<numBits; low++ {
+				probe := craftItem(fmt.Sprintf("probe-%d-", low), low, numBits)
+				if sketch.cardinalityEstimate("junk", probe) > minCardinality {
+					winning = low
+					break
+				}
+			}
+			if winning == 1<<numBits {
+				t.Fatal("No probe was admitted")
+			}
+
+			admitted := 0
+			for i := 0; i < numCrafted; i++ {
+				item := craftItem(fmt.Sprintf("junk-%d-", i), winning, numBits)
+				if sketch.cardinalityEstimate("junk", item) > minCardinality {
+					admitted++
+				}
+			}
+			return admitted
+		}
+
+		if admitted := attack(t, newConfig(t)); admitted != numCrafted {
+			t.Errorf("Expected all %d crafted items to win admission without keyed hashing, got %d", numCrafted, admitted)
+		}
+
+		if admitted := attack(t, newConfig(t, WithKeyedHashing(key[0], key[1]))); admitted > numCrafted/10 {
+			t.Errorf("Expected few of %d crafted items to win admission with keyed hashing, got %d", numCrafted, admitted)
+		}
+	})
+
+	t.Run("Keys Change Admission And Sample Decisions", func(t *testing.T) {
+		// decisions returns the admissions of candidates with the same
+		// estimates, which only the hashes of AdmissionCandidate tell apart,
+		// and the pairs kept by the subset sample
+		decisions := func(t *testing.T, policy AdmissionPolicy, opts ...Option) (string, string) {
+			sketch := NewHLLSamplingSpaceSavingSets[string, string](
+				newConfig(t, append([]Option{WithSubsetSample(16)}, opts...)...))
+
+			admitter := policy.NewAdmitter()
+			var admissions []Admission
+			for i := 0; i < 200; i++ {
+				candidate := sketch.admissionCandidate(fmt.Sprintf("label-%d", i), "item", 1, 2)
+				admissions = append(admissions, admitter.Admit(candidate))
+			}
+
+			for i := 0; i < 1000; i++ {
+				sketch.Insert(fmt.Sprintf("label-%d", i%100), fmt.Sprintf("item-%d", i))
+			}
+			return fmt.Sprint(admissions), fmt.Sprint(sketch.sample.pairs)
+		}
+
+		// ProportionalPolicy draws from Hash, and the doorkeeper of 256 bits
+		// lets labels through when the bits of their LabelHash collide
+		policies := []AdmissionPolicy{
+			ProportionalPolicy{},
+			DoorkeeperPolicy{Bits: 256, Next: InheritancePolicy{}},
+		}
+
+		for _, policy := range policies {
+			admissions, sample := decisions(t, policy, WithKeyedHashing(key[0], key[1]))
+			again, sampleAgain := decisions(t, policy, WithKeyedHashing(key[0], key[1]))
+			other, otherSample := decisions(t, policy, WithKeyedHashing(key[0]^1, key[1]))
+			unkeyed, unkeyedSample := decisions(t, policy)
+
+			if admissions != again || sample != sampleAgain {
+				t.Errorf("%T: expected the same decisions with the same key", policy)
+			}
+
+			if admissions == other || admissions == unkeyed {
+				t.Errorf("%T: expected the key to change the admissions", policy)
+			}
+
+			if sample == otherSample || sample == unkeyedSample {
+				t.Errorf("%T: expected the key to change the sampled pairs", policy)
+			}
+		}
+	})
+
+	t.Run("Crafted Items Don't Collide In The Cardinality Sketches", func(t *testing.T) {
+		// Unkeyed, items whose FNV-1a hashes share the low bits that select
+		// the register all land in one register, hiding their cardinality
+		cardinality := func(config *Config) uint64 {
+			hll := NewHyperLogLog[string](config.CardinalitySketchConfig)
+			for i := 0; i < 1000; i++ {
+				hll.Insert(craftItem(fmt.Sprintf("hidden-%d-", i), 0, 8))
+			}
+			return hll.Cardinality()
+		}
+
+		if estimate := cardinality(newConfig(t)); estimate > 10 {
+			t.Errorf("Expected crafted items to collide without keyed hashing, got cardinality %d", estimate)
+		}
+
+		if estimate := cardinality(newConfig(t, WithKeyedHashing(key[0], key[1]))); relativeError(estimate, 1000) > 0.2 {
+			t.Errorf("Expected a cardinality near 1000 with keyed hashing, got %d", estimate)
+		}
+	})
+
+	t.Run("Decoded Sketches Need The Key Again", func(t *testing.T) {
+		config := newConfig(t, WithKeyedHashing(key[0], key[1]))
+		want := SipHasher{Key0: key[0], Key1: key[1]}
+		if config.CardinalitySketchConfig.Hasher != want {
+			t.Fatalf("Expected the cardinality sketches to use %+v, got %+v", want, config.CardinalitySketchConfig.Hasher)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[string, int](config)
+		for i := 0; i < 100; i++ {
+			sketch.Insert("a", i)
+		}
+
+		for name, decoded := range roundTrips(t, sketch) {
+			// The key is never encoded
+			if decoded.config.HashKey != ([2]uint64{}) || decoded.config.CardinalitySketchConfig.Hasher != (SipHasher{}) {
+				t.Errorf("Expected no key after %s round trip, got %v and %+v",
+					name, decoded.config.HashKey, decoded.config.CardinalitySketchConfig.Hasher)
+			}
+			if err := decoded.config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("Expected the config to be invalid without its key after %s round trip, got %v", name, err)
+			}
+
+			if err := decoded.SetHashKey(key[0], key[1]+1); err != nil {
+				t.Fatalf("Failed to set hash key after %s round trip: %v", name, err)
+			}
+			if err := decoded.Merge(sketch); !errors.Is(err, ErrConfigMismatch) {
+				t.Errorf("Expected a mismatch merging with another key after %s round trip, got %v", name, err)
+			}
+			if err := decoded.SetHashKey(key[0], key[1]); err == nil {
+				t.Errorf("Expected an error replacing the key after %s round trip", name)
+			}
+		}
+
+		for name, decoded := range roundTrips(t, sketch) {
+			if err := decoded.SetHashKey(key[0], key[1]); err != nil {
+				t.Fatalf("Failed to set hash key after %s round trip: %v", name, err)
+			}
+			if err := decoded.config.Validate(); err != nil {
+				t.Errorf("Expected a valid config with its key after %s round trip, got %v", name, err)
+			}
+
+			// Items inserted with the key hash like the original's
+			decoded.Insert("a", 0)
+			if decoded.Cardinality("a") != sketch.Cardinality("a") {
+				t.Errorf("Expected cardinality %d after %s round trip, got %d", sketch.Cardinality("a"), name, decoded.Cardinality("a"))
+			}
+
+			if err := decoded.Merge(sketch); err != nil {
+				t.Errorf("Failed to merge with the original after %s round trip: %v", name, err)
+			}
+		}
+
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal sketch: %v", err)
+		}
+		for _, half := range key {
+			var b [8]byte
+			binary.LittleEndian.PutUint64(b[:], half)
+			if bytes.Contains(data, b[:]) {
+				t.Error("Expected the binary encoding not to contain the key")
+			}
+		}
+	})
+
+	t.Run("Decoded HyperLogLog Needs The Key Again", func(t *testing.T) {
+		config := newConfig(t, WithKeyedHashing(key[0], key[1]))
+		hll := NewHyperLogLog[int](config.CardinalitySketchConfig)
+		for i := 0; i < 100; i++ {
+			hll.Insert(i)
+		}
+
+		data, err := hll.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to marshal HLL: %v", err)
+		}
+
+		var decoded HyperLogLog[int]
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Failed to unmarshal HLL: %v", err)
+		}
+		if err := decoded.SetHashKey(0, 0); err == nil {
+			t.Error("Expected an error setting a zero key")
+		}
+		if err := decoded.SetHashKey(key[0], key[1]); err != nil {
+			t.Fatalf("Failed to set hash key: %v", err)
+		}
+
+		decoded.Insert(0)
+		if decoded.Cardinality() != hll.Cardinality() {
+			t.Errorf("Expected cardinality %d with the key, got %d", hll.Cardinality(), decoded.Cardinality())
+		}
+		if err := decoded.Merge(hll); err != nil {
+			t.Errorf("Failed to merge with the original: %v", err)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		if _, err := NewConfigWithOptions(20, WithKeyedHashing(key[0], key[1]), WithHasher(RedisHasher{})); err == nil {
+			t.Error("Expected an error for keyed hashing with a hasher")
+		}
+
+		if _, err := NewConfigWithOptions(20, WithKeyedHashing(0, 0)); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Expected ErrInvalidConfig for keyed hashing with a zero key, got %v", err)
+		}
+
+		// The key does not depend on the seeds
+		if _, err := NewConfigWithOptions(20, WithKeyedHashing(key[0], key[1]), WithSeeds([]uint64{})); err != nil {
+			t.Errorf("Expected keyed hashing to work without seeds, got %v", err)
+		}
+
+		// Keyed and unkeyed sketches cannot be merged
+		keyed := NewHLLSamplingSpaceSavingSets[string, int](newConfig(t, WithKeyedHashing(key[0], key[1])))
+		unkeyed := NewHLLSamplingSpaceSavingSets[string, int](newConfig(t))
+		var mismatch *ConfigMismatchError
+		if err := keyed.Merge(unkeyed); !errors.As(err, &mismatch) || mismatch.Field != "KeyedHashing" {
+			t.Errorf("Expected a KeyedHashing mismatch, got %v", err)
+		}
+	})
+}
@@ -38,3 +38,24 @@ type LabelCount[L comparable] struct {
 	Label L
 	Count uint64
 }
+
+// LabelStats represents a label with its estimated cardinality and, when
+// frequencies are tracked, its event count. Frequency - FrequencyError is a
+// lower bound on the number of events of the label, but Frequency is not an
+// upper bound.
+type LabelStats[L comparable] struct {
+	Label          L
+	Cardinality    uint64
+	Frequency      uint64
+	FrequencyError uint64
+}
+
+// Ranking selects the order of labels returned by TopStats
+type Ranking int
+
+const (
+	// RankByCardinality orders labels by their estimated number of distinct items
+	RankByCardinality Ranking = iota
+	// RankByFrequency orders labels by their number of events
+	RankByFrequency
+)
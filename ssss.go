@@ -1,6 +1,7 @@
 package ssss
 
 import (
+	"container/heap"
 	"errors"
 	"fmt"
 	"hash/fnv"
@@ -14,17 +15,58 @@ type SamplingSpaceSavingSets[L comparable, T comparable] struct {
 	config    *Config
 	counters  map[L]*CachedSketch[T]
 	threshold uint64
+	// items and labels sketch all items and labels when Config.TrackTotals
+	// is set, and are nil otherwise
+	items  *HyperLogLog[T]
+	labels *HyperLogLog[L]
+	// pinned labels always have a counter that is never evicted
+	pinned map[L]struct{}
+	// sample holds the (label, item) pairs of EstimateSubset when
+	// Config.SubsetSampleSize is set, and is nil otherwise
+	sample *pairSample[L]
+	// admitter is created from Config.AdmissionPolicy on first use
+	admitter Admitter
+	// candidates holds the unpinned counters by cardinality when heaped is
+	// set. Admissions build it on first use and keep it up to date; other
+	// changes to the counters drop it.
+	candidates counterHeap[L, T]
+	heaped     bool
+	// admissions counts the take overs, so that ties in the heap evict the
+	// label admitted first
+	admissions uint64
 }
 
 // NewSamplingSpaceSavingSets creates a new SamplingSpaceSavingSets sketch
 func NewSamplingSpaceSavingSets[L comparable, T comparable](
 	config *Config,
 ) *SamplingSpaceSavingSets[L, T] {
-	return &SamplingSpaceSavingSets[L, T]{
+	return newSamplingSpaceSavingSets[L, T](config, config.MaxNumCounters)
+}
+
+// newSamplingSpaceSavingSets creates a sketch with room for numCounters
+// counters. Decoders size it by the counters they decode rather than by
+// MaxNumCounters, which comes from untrusted input.
+func newSamplingSpaceSavingSets[L comparable, T comparable](
+	config *Config,
+	numCounters int,
+) *SamplingSpaceSavingSets[L, T] {
+	s := &SamplingSpaceSavingSets[L, T]{
 		config:    config,
-		counters:  make(map[L]*CachedSketch[T], config.MaxNumCounters),
+		counters:  make(map[L]*CachedSketch[T], numCounters),
 		threshold: 0,
+		pinned:    make(map[L]struct{}),
+	}
+
+	if config.TrackTotals {
+		s.items = NewHyperLogLog[T](config.CardinalitySketchConfig)
+		s.labels = NewHyperLogLog[L](config.CardinalitySketchConfig)
+	}
+
+	if config.SubsetSampleSize > 0 {
+		s.sample = newPairSample[L](config.SubsetSampleSize)
 	}
+
+	return s
 }
 
 // NewHLLSamplingSpaceSavingSets creates a new SamplingSpaceSavingSets sketch with HyperLogLog as the cardinality sketch
@@ -36,37 +78,52 @@ func NewHLLSamplingSpaceSavingSets[L comparable, T comparable](
 
 // Insert adds an item to the set associated with the given label
 func (s *SamplingSpaceSavingSets[L, T]) Insert(label L, item T) {
+	if s.items != nil {
+		s.items.Insert(item)
+		s.labels.Insert(label)
+	}
+
+	if s.sample != nil {
+		s.sample.insert(label, s.pairHash(label, item))
+	}
+
 	// If the counter for the label exists, use it
 	if counter, exists := s.counters[label]; exists {
+		cardinality := counter.Cardinality()
 		counter.Insert(item)
+		s.countEvent(counter)
+		if s.heaped && counter.index >= 0 && counter.Cardinality() != cardinality {
+			heap.Fix(&s.candidates, counter.index)
+		}
 		return
 	}
 
 	// If we have space, create a new counter
 	if len(s.counters) < s.config.MaxNumCounters {
-		hll := NewHyperLogLog[T](s.config.CardinalitySketchConfig)
-		counter := NewCachedSketch[T](hll)
+		counter := s.newCounter()
 		s.counters[label] = counter
 		counter.Insert(item)
+		s.countEvent(counter)
+		if s.heaped {
+			heap.Push(&s.candidates, candidate[L, T]{label: label, counter: counter, admitted: s.admissions})
+		}
 		return
 	}
 
 	// Otherwise, use the sampling strategy
 	cardinalityEstimate := s.cardinalityEstimate(label, item)
 
+	switch s.config.AdmissionPolicy.(type) {
+	case nil, TrailingZerosPolicy:
+	default:
+		s.admit(label, item, cardinalityEstimate)
+		return
+	}
+
 	// Only consider labels with estimated cardinality above the threshold
 	if cardinalityEstimate > s.threshold {
-		// Find the counter with the minimum cardinality
-		var minLabel L
-		var minCardinality uint64 = math.MaxUint64
-
-		for l, c := range s.counters {
-			cardinality := c.Cardinality()
-			if cardinality < minCardinality {
-				minLabel = l
-				minCardinality = cardinality
-			}
-		}
+		// Find the unpinned counter with the minimum cardinality
+		minLabel, minCardinality := s.minCandidate()
 
 		// Set threshold to min cardinality
 		s.threshold = minCardinality
@@ -74,44 +131,128 @@ func (s *SamplingSpaceSavingSets[L, T]) Insert(label L, item T) {
 		// If the estimated cardinality is greater than the minimum cardinality,
 		// replace the minimum counter with a new one for the label
 		if cardinalityEstimate > minCardinality {
-			// Remove the counter with the minimum cardinality
-			minCounter := s.counters[minLabel]
-			delete(s.counters, minLabel)
+			s.takeOver(minLabel, label, item, false)
+		}
+	}
+}
 
-			// Reset the counter
-			minCounter.Clear()
+// admit asks the admission policy of the configuration whether the label
+// takes over the minimum counter
+func (s *SamplingSpaceSavingSets[L, T]) admit(label L, item T, cardinalityEstimate uint64) {
+	minLabel, minCardinality := s.minCandidate()
+	if minCardinality == math.MaxUint64 {
+		// All counters are pinned
+		return
+	}
+	s.threshold = minCardinality
 
-			// Map the counter to the new label
-			s.counters[label] = minCounter
+	if s.admitter == nil {
+		s.admitter = s.config.AdmissionPolicy.NewAdmitter()
+	}
 
-			// Insert the item
-			minCounter.Insert(item)
-		}
+	switch s.admitter.Admit(s.admissionCandidate(label, item, cardinalityEstimate, minCardinality)) {
+	case Replace:
+		s.takeOver(minLabel, label, item, false)
+	case Inherit:
+		s.takeOver(minLabel, label, item, true)
+	}
+}
+
+// admissionCandidate describes an item of an untracked label to the
+// admission policy, with the seed or key of its hashes
+func (s *SamplingSpaceSavingSets[L, T]) admissionCandidate(
+	label L,
+	item T,
+	cardinalityEstimate, minCardinality uint64,
+) *AdmissionCandidate {
+	var seed uint64
+	if len(s.config.Seeds) > 1 {
+		seed = s.config.Seeds[1]
+	}
+
+	return &AdmissionCandidate{
+		Label:          label,
+		Item:           item,
+		Estimate:       cardinalityEstimate,
+		MinCardinality: minCardinality,
+		seed:           seed,
+		key:            s.config.tweakedKey(admissionKeyTweak),
+		keyed:          s.config.KeyedHashing,
+	}
+}
+
+// takeOver evicts the label of the minimum counter and maps the counter to
+// the new label with the item, emptying its set unless inherit is set
+func (s *SamplingSpaceSavingSets[L, T]) takeOver(minLabel L, label L, item T, inherit bool) {
+	// Remove the counter with the minimum cardinality
+	minCounter := s.counters[minLabel]
+	delete(s.counters, minLabel)
+
+	// Reset the counter, keeping its event count as in Space-Saving:
+	// the new label inherits it as both count and error
+	inherited := minCounter.frequency
+	if !inherit {
+		minCounter.Clear()
+	}
+	if s.config.TrackFrequencies {
+		minCounter.frequency = inherited
+		minCounter.frequencyError = inherited
+	}
+
+	// Map the counter to the new label
+	s.counters[label] = minCounter
+
+	// Insert the item
+	minCounter.Insert(item)
+	s.countEvent(minCounter)
+
+	if s.heaped {
+		s.admissions++
+		s.candidates[minCounter.index].label = label
+		s.candidates[minCounter.index].admitted = s.admissions
+		heap.Fix(&s.candidates, minCounter.index)
 	}
 }
 
 // Merge combines this sketch with another sketch of the same type
 func (s *SamplingSpaceSavingSets[L, T]) Merge(other HeavyDistinctHitterSketch[L, T]) error {
 	otherSSS, ok := other.(*SamplingSpaceSavingSets[L, T])
-	if !ok {
-		return errors.New("can only merge with another SamplingSpaceSavingSets")
+	if !ok || otherSSS == nil {
+		return &IncompatibleSketchError{Reason: "can only merge with another SamplingSpaceSavingSets"}
+	}
+	if otherSSS == s {
+		return &IncompatibleSketchError{Reason: "cannot merge a sketch into itself"}
 	}
 
-	// Check if configs match
-	if s.config.MaxNumCounters != otherSSS.config.MaxNumCounters ||
-		len(s.config.Seeds) != len(otherSSS.config.Seeds) {
-		return errors.New("config mismatch")
+	// Check if configs match. The capacities may differ, the result keeps
+	// the capacity of s.
+	if err := s.config.CheckCompatible(otherSSS.config); err != nil {
+		return err
 	}
 
-	for i := range s.config.Seeds {
-		if s.config.Seeds[i] != otherSSS.config.Seeds[i] {
-			return errors.New("config mismatch: different seeds")
+	// Merge the totals
+	if s.items != nil {
+		if err := s.items.Merge(otherSSS.items); err != nil {
+			return err
+		}
+		if err := s.labels.Merge(otherSSS.labels); err != nil {
+			return err
 		}
 	}
 
-	// Check if HLL configs match
-	if s.config.CardinalitySketchConfig.NumRegisters != otherSSS.config.CardinalitySketchConfig.NumRegisters {
-		return errors.New("config mismatch: different HLL register count")
+	if s.sample != nil {
+		s.sample.merge(otherSSS.sample)
+	}
+
+	// As in merging Space-Saving summaries, a label one sketch does not
+	// track may have had up to its minimum event count there, which becomes
+	// error. Take both minimums before the counters change.
+	minFrequency, otherMinFrequency := s.minFrequency(), otherSSS.minFrequency()
+	for label, counter := range s.counters {
+		if _, exists := otherSSS.counters[label]; !exists {
+			counter.frequency += otherMinFrequency
+			counter.frequencyError += otherMinFrequency
+		}
 	}
 
 	// Merge the two sets of counters
@@ -122,60 +263,233 @@ func (s *SamplingSpaceSavingSets[L, T]) Merge(other HeavyDistinctHitterSketch[L,
 			if err != nil {
 				return err
 			}
+			existingCounter.frequency += counter.frequency
+			existingCounter.frequencyError += counter.frequencyError
 		} else {
 			// Otherwise, create a new counter
-			hll := NewHyperLogLog[T](s.config.CardinalitySketchConfig)
-			newCounter := NewCachedSketch[T](hll)
+			newCounter := s.newCounter()
 			err := newCounter.Merge(counter)
 			if err != nil {
 				return err
 			}
+			newCounter.frequency = counter.frequency + minFrequency
+			newCounter.frequencyError = counter.frequencyError + minFrequency
 			s.counters[label] = newCounter
 		}
 	}
 
-	// Only keep the top MaxNumCounters counters
-	if len(s.counters) > s.config.MaxNumCounters {
-		var entries []LabelCount[L]
-		for label, counter := range s.counters {
-			entries = append(entries, LabelCount[L]{
-				Label: label,
-				Count: counter.Cardinality(),
-			})
+	s.trim()
+	s.updateThreshold()
+	return nil
+}
+
+// minFrequency returns the smallest event count of the counters once all are
+// in use, which Space-Saving charges to labels without a counter. It is 0
+// while counters are free or frequencies are not tracked.
+func (s *SamplingSpaceSavingSets[L, T]) minFrequency() uint64 {
+	if !s.config.TrackFrequencies || len(s.counters) < s.config.MaxNumCounters {
+		return 0
+	}
+	first := true
+	var smallest uint64
+	for _, counter := range s.counters {
+		if first || counter.frequency < smallest {
+			smallest = counter.frequency
+			first = false
 		}
+	}
+	return smallest
+}
 
-		// Sort by cardinality in descending order
-		sort.Slice(entries, func(i, j int) bool {
-			return entries[i].Count > entries[j].Count
-		})
+// Resize changes the maximum number of counters of the sketch. Shrinking
+// keeps the pinned labels and the unpinned labels with the highest
+// cardinality; growing keeps all the counters. The sketch gets its own copy
+// of the configuration, so other sketches sharing it are unaffected.
+func (s *SamplingSpaceSavingSets[L, T]) Resize(maxNumCounters int) error {
+	if maxNumCounters <= 0 {
+		return errors.New("max number of counters must be greater than zero")
+	}
 
-		// Keep only the top MaxNumCounters entries
-		for _, entry := range entries[s.config.MaxNumCounters:] {
-			delete(s.counters, entry.Label)
-		}
+	if maxNumCounters < len(s.pinned) {
+		return fmt.Errorf("cannot resize to %d counters with %d pinned labels", maxNumCounters, len(s.pinned))
 	}
 
-	// Update the threshold to the minimum cardinality
-	s.threshold = math.MaxUint64
-	for _, counter := range s.counters {
-		cardinality := counter.Cardinality()
-		if cardinality < s.threshold {
-			s.threshold = cardinality
+	config := *s.config
+	config.MaxNumCounters = maxNumCounters
+	s.config = &config
+
+	s.trim()
+	s.updateThreshold()
+	return nil
+}
+
+// MergeLabel merges a cardinality sketch into the set associated with the
+// given label, for example one imported from another system. An untracked
+// label is added if there is space, and otherwise replaces the counter with
+// the minimum cardinality only if its own cardinality is greater. The sketch
+// carries no events, so the event count of the label is left unchanged.
+func (s *SamplingSpaceSavingSets[L, T]) MergeLabel(label L, sketch CardinalitySketch[T]) error {
+	// Merge into a fresh counter first so that an incompatible sketch leaves
+	// the existing counters untouched
+	counter := s.newCounter()
+	err := counter.Merge(sketch)
+	if err != nil {
+		return err
+	}
+
+	if s.items != nil {
+		if err := s.items.Merge(counter.sketch); err != nil {
+			return err
 		}
+		s.labels.Insert(label)
+	}
+
+	s.heaped = false
+	if existing, exists := s.counters[label]; exists {
+		return existing.Merge(counter)
+	}
+
+	if len(s.counters) < s.config.MaxNumCounters {
+		s.counters[label] = counter
+		return nil
+	}
+
+	minLabel, minCardinality := s.minCounter()
+	s.threshold = minCardinality
+
+	if counter.Cardinality() > minCardinality {
+		delete(s.counters, minLabel)
+		s.counters[label] = counter
+	}
+
+	return nil
+}
+
+// LabelSketch returns a copy of the HyperLogLog sketch of a tracked label, for
+// example to export it to another system
+func (s *SamplingSpaceSavingSets[L, T]) LabelSketch(label L) (*HyperLogLog[T], bool) {
+	counter, exists := s.counters[label]
+	if !exists {
+		return nil, false
+	}
+
+	hll, ok := counter.sketch.(*HyperLogLog[T])
+	if !ok {
+		return nil, false
+	}
+
+	return hll.clone(), true
+}
+
+// Remove drops a tracked label and its counter, for example once it has been
+// mitigated, and unpins it. The totals and the subset sample still include
+// its items. It returns false if the label is not tracked.
+func (s *SamplingSpaceSavingSets[L, T]) Remove(label L) bool {
+	if _, exists := s.counters[label]; !exists {
+		return false
 	}
 
-	// If there are no counters, reset the threshold
-	if len(s.counters) == 0 {
-		s.threshold = 0
+	delete(s.counters, label)
+	delete(s.pinned, label)
+	s.updateThreshold()
+	return true
+}
+
+// ResetLabel empties the set and the event count of a tracked label while
+// keeping its counter. Unless the label is pinned, its empty counter is the
+// first to be evicted. It returns false if the label is not tracked.
+func (s *SamplingSpaceSavingSets[L, T]) ResetLabel(label L) bool {
+	counter, exists := s.counters[label]
+	if !exists {
+		return false
 	}
 
+	counter.Clear()
+	s.updateThreshold()
+	return true
+}
+
+// Pin keeps a label on a watchlist: it gets a counter if it has none, and its
+// counter is never evicted by inserts or merges. Pinned labels use counters
+// of the Config.MaxNumCounters budget but are not eviction candidates, so
+// pinning fails once every counter is pinned. Pins are not encoded.
+func (s *SamplingSpaceSavingSets[L, T]) Pin(label L) error {
+	if _, exists := s.counters[label]; !exists {
+		if len(s.pinned) >= s.config.MaxNumCounters {
+			return errors.New("all counters are pinned")
+		}
+
+		// Make room by evicting the minimum unpinned counter
+		if len(s.counters) >= s.config.MaxNumCounters {
+			minLabel, _ := s.minCounter()
+			delete(s.counters, minLabel)
+		}
+
+		s.counters[label] = s.newCounter()
+	}
+
+	s.pinned[label] = struct{}{}
+	s.updateThreshold()
 	return nil
 }
 
-// Clear resets the sketch to its initial state
+// Unpin makes a pinned label an eviction candidate again
+func (s *SamplingSpaceSavingSets[L, T]) Unpin(label L) {
+	delete(s.pinned, label)
+	s.updateThreshold()
+}
+
+// Pinned reports whether a label is pinned
+func (s *SamplingSpaceSavingSets[L, T]) Pinned(label L) bool {
+	_, pinned := s.pinned[label]
+	return pinned
+}
+
+// Clear resets the sketch to its initial state. Pinned labels stay pinned,
+// with empty counters.
 func (s *SamplingSpaceSavingSets[L, T]) Clear() {
-	s.counters = make(map[L]*CachedSketch[T], s.config.MaxNumCounters)
+	s.counters = make(map[L]*CachedSketch[T], len(s.counters))
 	s.threshold = 0
+
+	for label := range s.pinned {
+		s.counters[label] = s.newCounter()
+	}
+
+	if s.items != nil {
+		s.items.Clear()
+		s.labels.Clear()
+	}
+
+	if s.sample != nil {
+		s.sample.clear()
+	}
+
+	s.admitter = nil
+	s.heaped = false
+}
+
+// TotalDistinctItems returns the estimated number of distinct items inserted
+// across all labels, tracked or not. It is 0 unless Config.TrackTotals is set.
+func (s *SamplingSpaceSavingSets[L, T]) TotalDistinctItems() uint64 {
+	if s.items == nil {
+		return 0
+	}
+	return s.items.Cardinality()
+}
+
+// DistinctLabels returns the estimated number of distinct labels inserted,
+// tracked or not. It is 0 unless Config.TrackTotals is set.
+func (s *SamplingSpaceSavingSets[L, T]) DistinctLabels() uint64 {
+	if s.labels == nil {
+		return 0
+	}
+	return s.labels.Cardinality()
+}
+
+// Config returns the configuration of the sketch, for example to compare the
+// Fingerprint of a decoded sketch. It must not be modified.
+func (s *SamplingSpaceSavingSets[L, T]) Config() *Config {
+	return s.config
 }
 
 // Cardinality returns the estimated cardinality of the set associated with the given label
@@ -184,19 +498,13 @@ func (s *SamplingSpaceSavingSets[L, T]) Cardinality(label L) uint64 {
 		return counter.Cardinality()
 	}
 
-	// If the label doesn't exist, return the minimum cardinality or 0
-	minCardinality := uint64(0)
-	if len(s.counters) > 0 {
-		minCardinality = math.MaxUint64
-		for _, counter := range s.counters {
-			cardinality := counter.Cardinality()
-			if cardinality < minCardinality {
-				minCardinality = cardinality
-			}
-		}
+	// If the label doesn't exist, return the minimum cardinality of the
+	// eviction candidates or 0
+	if _, minCardinality := s.minCounter(); minCardinality != math.MaxUint64 {
+		return minCardinality
 	}
 
-	return minCardinality
+	return 0
 }
 
 // Top returns the k labels with the highest cardinality, along with their estimated cardinalities
@@ -222,12 +530,260 @@ func (s *SamplingSpaceSavingSets[L, T]) Top(k int) []LabelCount[L] {
 	return entries
 }
 
+// Frequency returns the event count of a tracked label and its error. Labels
+// that take over the counter of an evicted label inherit its count as error,
+// as in Space-Saving, so the count minus the error is a lower bound on the
+// events of the label. Unlike in Space-Saving, the count is not an upper
+// bound: events of the label the admission policy rejected while it was
+// untracked are not counted. Both are 0 for untracked labels and unless
+// Config.TrackFrequencies is set.
+func (s *SamplingSpaceSavingSets[L, T]) Frequency(label L) (uint64, uint64) {
+	counter, exists := s.counters[label]
+	if !exists {
+		return 0, 0
+	}
+	return counter.frequency, counter.frequencyError
+}
+
+// TopStats returns the k labels ranked first by the given ranking, along with
+// their estimated cardinalities and event counts. Counters are still evicted
+// by cardinality, so ranking by frequency orders the labels tracked for their
+// cardinality and is not a frequent items sketch. See Frequency for the
+// bounds of the event counts.
+func (s *SamplingSpaceSavingSets[L, T]) TopStats(k int, ranking Ranking) []LabelStats[L] {
+	entries := make([]LabelStats[L], 0, len(s.counters))
+	for label, counter := range s.counters {
+		entries = append(entries, LabelStats[L]{
+			Label:          label,
+			Cardinality:    counter.Cardinality(),
+			Frequency:      counter.frequency,
+			FrequencyError: counter.frequencyError,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if ranking == RankByFrequency && entries[i].Frequency != entries[j].Frequency {
+			return entries[i].Frequency > entries[j].Frequency
+		}
+		return entries[i].Cardinality > entries[j].Cardinality
+	})
+
+	if k < len(entries) {
+		return entries[:k]
+	}
+
+	return entries
+}
+
+// UnionCardinality returns the estimated number of distinct items in the
+// union of the sets of the given labels. The registers of the label sketches
+// are merged, so items shared by several labels are counted once. Untracked
+// labels have no set and are skipped. It fails if a label sketch cannot be
+// merged into a HyperLogLog of the cardinality sketch configuration.
+func (s *SamplingSpaceSavingSets[L, T]) UnionCardinality(labels ...L) (uint64, error) {
+	union := NewHyperLogLog[T](s.config.CardinalitySketchConfig)
+	for _, label := range labels {
+		if counter, exists := s.counters[label]; exists {
+			if err := union.Merge(counter.sketch); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return union.Cardinality(), nil
+}
+
+// IntersectionCardinality returns the estimated number of distinct items in
+// both the set of label a and the set of label b, by inclusion-exclusion over
+// the cardinalities of the two sets and of their union. The estimate is
+// clamped to the smaller set, and it is 0 if either label is untracked. Its
+// error is of the order of the error of the union, so intersections much
+// smaller than the union are unreliable. It fails if the union fails.
+func (s *SamplingSpaceSavingSets[L, T]) IntersectionCardinality(a, b L) (uint64, error) {
+	counterA, existsA := s.counters[a]
+	counterB, existsB := s.counters[b]
+	if !existsA || !existsB {
+		return 0, nil
+	}
+
+	cardinalityA := counterA.Cardinality()
+	cardinalityB := counterB.Cardinality()
+	union, err := s.UnionCardinality(a, b)
+	if err != nil {
+		return 0, err
+	}
+
+	if cardinalityA+cardinalityB <= union {
+		return 0, nil
+	}
+
+	intersection := cardinalityA + cardinalityB - union
+	if intersection > cardinalityA {
+		intersection = cardinalityA
+	}
+	if intersection > cardinalityB {
+		intersection = cardinalityB
+	}
+
+	return intersection, nil
+}
+
+// newCounter creates an empty counter for a label
+func (s *SamplingSpaceSavingSets[L, T]) newCounter() *CachedSketch[T] {
+	hll := NewHyperLogLog[T](s.config.CardinalitySketchConfig)
+	return NewCachedSketch[T](hll)
+}
+
+// countEvent counts an event of the label of a counter
+func (s *SamplingSpaceSavingSets[L, T]) countEvent(counter *CachedSketch[T]) {
+	if s.config.TrackFrequencies {
+		counter.frequency++
+	}
+}
+
+// trim drops the unpinned counters with the lowest cardinality until the
+// counters fit in MaxNumCounters
+func (s *SamplingSpaceSavingSets[L, T]) trim() {
+	if len(s.counters) <= s.config.MaxNumCounters {
+		return
+	}
+
+	var entries []LabelCount[L]
+	for label, counter := range s.counters {
+		if _, pinned := s.pinned[label]; pinned {
+			continue
+		}
+		entries = append(entries, LabelCount[L]{
+			Label: label,
+			Count: counter.Cardinality(),
+		})
+	}
+
+	// Sort by cardinality in descending order
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Count > entries[j].Count
+	})
+
+	// Keep only the top entries that fit next to the pinned labels
+	for _, entry := range entries[s.config.MaxNumCounters-len(s.pinned):] {
+		delete(s.counters, entry.Label)
+	}
+}
+
+// updateThreshold sets the threshold to the minimum cardinality of the
+// eviction candidates, or 0 if there are none, after the counters changed
+// outside of Insert. The heap of eviction candidates is rebuilt on the next
+// admission.
+func (s *SamplingSpaceSavingSets[L, T]) updateThreshold() {
+	s.heaped = false
+	s.threshold = 0
+	if _, minCardinality := s.minCounter(); minCardinality != math.MaxUint64 {
+		s.threshold = minCardinality
+	}
+}
+
+// minCounter returns the unpinned label with the minimum cardinality and its
+// cardinality, or math.MaxUint64 if all counters are pinned
+func (s *SamplingSpaceSavingSets[L, T]) minCounter() (L, uint64) {
+	var minLabel L
+	var minCardinality uint64 = math.MaxUint64
+
+	for l, c := range s.counters {
+		if _, pinned := s.pinned[l]; pinned {
+			continue
+		}
+
+		cardinality := c.Cardinality()
+		if cardinality < minCardinality {
+			minLabel = l
+			minCardinality = cardinality
+		}
+	}
+
+	return minLabel, minCardinality
+}
+
+// minCandidate returns the unpinned label with the minimum cardinality like
+// minCounter, in O(1) from the heap of eviction candidates, which it builds
+// if needed. Unlike minCounter it must not be called from queries.
+func (s *SamplingSpaceSavingSets[L, T]) minCandidate() (L, uint64) {
+	if !s.heaped {
+		s.candidates = s.candidates[:0]
+		for label, counter := range s.counters {
+			if _, pinned := s.pinned[label]; pinned {
+				counter.index = -1
+				continue
+			}
+			counter.index = len(s.candidates)
+			s.candidates = append(s.candidates, candidate[L, T]{label: label, counter: counter})
+		}
+		heap.Init(&s.candidates)
+		s.heaped = true
+	}
+
+	if len(s.candidates) == 0 {
+		var none L
+		return none, math.MaxUint64
+	}
+	return s.candidates[0].label, s.candidates[0].counter.Cardinality()
+}
+
+// candidate is an unpinned counter in the heap of eviction candidates, with
+// the number of admissions when its label took it over
+type candidate[L comparable, T comparable] struct {
+	label    L
+	counter  *CachedSketch[T]
+	admitted uint64
+}
+
+// counterHeap is a min-heap of eviction candidates by cardinality, then by
+// admission, see container/heap. It keeps the index of each counter up to
+// date.
+type counterHeap[L comparable, T comparable] []candidate[L, T]
+
+func (h counterHeap[L, T]) Len() int { return len(h) }
+
+func (h counterHeap[L, T]) Less(i, j int) bool {
+	ci, cj := h[i].counter.Cardinality(), h[j].counter.Cardinality()
+	if ci != cj {
+		return ci < cj
+	}
+	return h[i].admitted < h[j].admitted
+}
+
+func (h counterHeap[L, T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].counter.index = i
+	h[j].counter.index = j
+}
+
+func (h *counterHeap[L, T]) Push(x any) {
+	c := x.(candidate[L, T])
+	c.counter.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap[L, T]) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	c.counter.index = -1
+	*h = old[:len(old)-1]
+	return c
+}
+
 // cardinalityEstimate estimates the cardinality of a set based on the hash of an item
 func (s *SamplingSpaceSavingSets[L, T]) cardinalityEstimate(_ L, item T) uint64 {
 	// Create a hash of the item
-	hasher := fnv.New64a()
-	fmt.Fprintf(hasher, "%v", item)
-	itemHash := hasher.Sum64()
+	var itemHash uint64
+	if s.config.KeyedHashing {
+		// The halves of the key are swapped, so that the estimate is
+		// independent of the register hashes of a SipHasher with the key
+		itemHash = sipHash24(s.config.HashKey[1], s.config.HashKey[0], itemText(item))
+	} else {
+		hasher := fnv.New64a()
+		fmt.Fprintf(hasher, "%v", item)
+		itemHash = hasher.Sum64()
+	}
 
 	// Use all available seeds and average the estimates
 	var totalEstimate uint64
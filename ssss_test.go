@@ -92,6 +92,35 @@ func TestHyperLogLog(t *testing.T) {
 			t.Errorf("Expected zero cardinality after clear, got %d", hll.Cardinality())
 		}
 	})
+
+	t.Run("Register Values", func(t *testing.T) {
+		config, err := NewHLLConfig(16, []uint64{8, 9})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		// The low 4 bits select the register and the value is the position
+		// of the lowest set bit above them, bounded by a sentinel bit
+		hashes := []struct {
+			hash     uint64
+			register int
+			value    uint8
+		}{
+			{hash: 0x13, register: 3, value: 1},
+			{hash: 0x85, register: 5, value: 4},
+			{hash: 1 << 63, register: 0, value: 60},
+			{hash: 0x7, register: 7, value: 61},
+		}
+
+		for _, h := range hashes {
+			hll := NewHyperLogLog[uint64](config)
+			hll.insertHash(h.hash)
+			if hll.registers[h.register] != h.value {
+				t.Errorf("Expected hash %x to set register %d to %d, got %d",
+					h.hash, h.register, h.value, hll.registers[h.register])
+			}
+		}
+	})
 }
 
 func TestHyperLogLogExtended(t *testing.T) {
@@ -1378,3 +1407,682 @@ func TestSamplingSpaceSavingSets(t *testing.T) {
 		}
 	})
 }
+
+func TestSamplingSpaceSavingSetsTotals(t *testing.T) {
+	// spread maps consecutive integers to well distributed items, since the
+	// default hash of consecutive integers is skewed
+	spread := func(i uint64) uint64 {
+		return i * 0x9e3779b97f4a7c15
+	}
+
+	// newSketch creates a sketch that tracks its totals
+	newSketch := func(t *testing.T) *SamplingSpaceSavingSets[int, uint64] {
+		t.Helper()
+
+		hllConfig, err := NewHLLConfig(1024, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(5, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		config.TrackTotals = true
+
+		return NewHLLSamplingSpaceSavingSets[int, uint64](config)
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		sketch := newSketch(t)
+
+		// 200 labels, far more than the 5 counters, sharing 1000 items
+		for i := uint64(0); i < 5000; i++ {
+			sketch.Insert(int(i%200), spread(i%1000))
+		}
+
+		if relativeError(sketch.TotalDistinctItems(), 1000) > 0.1 {
+			t.Errorf("Expected about 1000 distinct items, got %d", sketch.TotalDistinctItems())
+		}
+
+		if relativeError(sketch.DistinctLabels(), 200) > 0.1 {
+			t.Errorf("Expected about 200 distinct labels, got %d", sketch.DistinctLabels())
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		sketch1 := newSketch(t)
+		sketch2 := newSketch(t)
+
+		// Disjoint labels and items
+		for i := uint64(0); i < 1000; i++ {
+			sketch1.Insert(int(i%50), spread(i))
+			sketch2.Insert(int(50+i%50), spread(1000+i))
+		}
+
+		if err := sketch1.Merge(sketch2); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		if relativeError(sketch1.TotalDistinctItems(), 2000) > 0.1 {
+			t.Errorf("Expected about 2000 distinct items, got %d", sketch1.TotalDistinctItems())
+		}
+
+		if relativeError(sketch1.DistinctLabels(), 100) > 0.1 {
+			t.Errorf("Expected about 100 distinct labels, got %d", sketch1.DistinctLabels())
+		}
+	})
+
+	t.Run("Merge Label", func(t *testing.T) {
+		sketch := newSketch(t)
+
+		hll := NewHyperLogLog[uint64](sketch.config.CardinalitySketchConfig)
+		for i := uint64(0); i < 500; i++ {
+			hll.Insert(i)
+		}
+
+		if err := sketch.MergeLabel(7, hll); err != nil {
+			t.Fatalf("Failed to merge label: %v", err)
+		}
+
+		if sketch.TotalDistinctItems() != hll.Cardinality() || sketch.DistinctLabels() != 1 {
+			t.Errorf("Expected totals of %d items and 1 label, got %d and %d",
+				hll.Cardinality(), sketch.TotalDistinctItems(), sketch.DistinctLabels())
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		sketch := newSketch(t)
+		sketch.Insert(1, 1)
+		sketch.Clear()
+
+		if sketch.TotalDistinctItems() != 0 || sketch.DistinctLabels() != 0 {
+			t.Error("Expected totals to be reset")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		sketch := newSketch(t)
+
+		config := *sketch.config
+		config.TrackTotals = false
+		untracked := NewHLLSamplingSpaceSavingSets[int, uint64](&config)
+		untracked.Insert(1, 1)
+
+		if untracked.TotalDistinctItems() != 0 || untracked.DistinctLabels() != 0 {
+			t.Error("Expected zero totals without TrackTotals")
+		}
+
+		// Merging would leave the totals of one side incomplete
+		if err := sketch.Merge(untracked); err == nil {
+			t.Error("Expected error merging sketches with different TrackTotals")
+		}
+	})
+}
+
+func TestSetOperations(t *testing.T) {
+	hllConfig, err := NewHLLConfig(1024, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+	if err != nil {
+		t.Fatalf("Failed to create HLL config: %v", err)
+	}
+
+	config, err := NewConfig(5, hllConfig, []uint64{0, 1, 2, 3})
+	if err != nil {
+		t.Fatalf("Failed to create SSSS config: %v", err)
+	}
+
+	// Label a holds items [0, 3000), b holds [2000, 4000) and c holds [10000, 10500)
+	sketch := NewHLLSamplingSpaceSavingSets[string, uint64](config)
+	for i := uint64(0); i < 3000; i++ {
+		sketch.Insert("a", i*0x9e3779b97f4a7c15)
+	}
+	for i := uint64(2000); i < 4000; i++ {
+		sketch.Insert("b", i*0x9e3779b97f4a7c15)
+	}
+	for i := uint64(10000); i < 10500; i++ {
+		sketch.Insert("c", i*0x9e3779b97f4a7c15)
+	}
+
+	// union and intersection fail the test if the estimate fails
+	union := func(t *testing.T, labels ...string) uint64 {
+		t.Helper()
+
+		cardinality, err := sketch.UnionCardinality(labels...)
+		if err != nil {
+			t.Fatalf("Failed to estimate union: %v", err)
+		}
+		return cardinality
+	}
+	intersection := func(t *testing.T, a, b string) uint64 {
+		t.Helper()
+
+		cardinality, err := sketch.IntersectionCardinality(a, b)
+		if err != nil {
+			t.Fatalf("Failed to estimate intersection: %v", err)
+		}
+		return cardinality
+	}
+
+	t.Run("Union", func(t *testing.T) {
+		if u := union(t, "a", "b"); relativeError(u, 4000) > 0.1 {
+			t.Errorf("Expected union of a and b close to 4000, got %d", u)
+		}
+
+		if u := union(t, "a", "b", "c"); relativeError(u, 4500) > 0.1 {
+			t.Errorf("Expected union of a, b and c close to 4500, got %d", u)
+		}
+
+		// A single label is its own cardinality
+		if union(t, "c") != sketch.Cardinality("c") {
+			t.Errorf("Expected union of c to be %d, got %d", sketch.Cardinality("c"), union(t, "c"))
+		}
+
+		// Untracked labels are skipped
+		if union(t, "a", "missing") != union(t, "a") {
+			t.Error("Expected untracked labels not to change the union")
+		}
+
+		if union(t) != 0 {
+			t.Errorf("Expected empty union, got %d", union(t))
+		}
+	})
+
+	t.Run("Intersection", func(t *testing.T) {
+		if i := intersection(t, "a", "b"); relativeError(i, 1000) > 0.3 {
+			t.Errorf("Expected intersection of a and b close to 1000, got %d", i)
+		}
+
+		// Disjoint sets have a small intersection
+		if i := intersection(t, "a", "c"); i > 250 {
+			t.Errorf("Expected small intersection of a and c, got %d", i)
+		}
+
+		// A set intersected with itself is the set
+		if intersection(t, "c", "c") != sketch.Cardinality("c") {
+			t.Errorf("Expected intersection of c with itself to be %d, got %d",
+				sketch.Cardinality("c"), intersection(t, "c", "c"))
+		}
+
+		if intersection(t, "a", "missing") != 0 {
+			t.Error("Expected zero intersection with an untracked label")
+		}
+	})
+}
+
+func TestSamplingSpaceSavingSetsFrequencies(t *testing.T) {
+	// newSketch creates a sketch that counts the events of its labels
+	newSketch := func(t *testing.T, maxNumCounters int) *SamplingSpaceSavingSets[int, uint64] {
+		t.Helper()
+
+		hllConfig, err := NewHLLConfig(256, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(maxNumCounters, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		config.TrackFrequencies = true
+
+		return NewHLLSamplingSpaceSavingSets[int, uint64](config)
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		sketch := newSketch(t, 5)
+
+		// Label 1 has many events from few items, label 2 few events from
+		// many items
+		for i := uint64(0); i < 1000; i++ {
+			sketch.Insert(1, i%5)
+		}
+		for i := uint64(0); i < 300; i++ {
+			sketch.Insert(2, i*0x9e3779b97f4a7c15)
+		}
+
+		if frequency, frequencyError := sketch.Frequency(1); frequency != 1000 || frequencyError != 0 {
+			t.Errorf("Expected exactly 1000 events for label 1, got %d with error %d", frequency, frequencyError)
+		}
+
+		if frequency, _ := sketch.Frequency(3); frequency != 0 {
+			t.Errorf("Expected no events for an untracked label, got %d", frequency)
+		}
+
+		byCardinality := sketch.TopStats(1, RankByCardinality)
+		if len(byCardinality) != 1 || byCardinality[0].Label != 2 {
+			t.Errorf("Expected label 2 to have the highest cardinality, got %v", byCardinality)
+		}
+
+		byFrequency := sketch.TopStats(2, RankByFrequency)
+		if len(byFrequency) != 2 || byFrequency[0].Label != 1 || byFrequency[1].Frequency != 300 {
+			t.Errorf("Expected label 1 to have the most events, got %v", byFrequency)
+		}
+	})
+
+	t.Run("Eviction Inherits Count", func(t *testing.T) {
+		sketch := newSketch(t, 1)
+
+		// Label 1 holds the only counter with 10 events of a single item
+		for i := 0; i < 10; i++ {
+			sketch.Insert(1, 0)
+		}
+
+		// Label 2 takes the counter over once an item estimates a larger set
+		inserted := uint64(0)
+		for i := uint64(1); i < 1000; i++ {
+			sketch.Insert(2, i*0x9e3779b97f4a7c15)
+			if _, tracked := sketch.counters[2]; tracked {
+				inserted++
+			}
+		}
+
+		frequency, frequencyError := sketch.Frequency(2)
+		if frequencyError != 10 {
+			t.Errorf("Expected the 10 events of the evicted label as error, got %d", frequencyError)
+		}
+
+		if frequency-frequencyError != inserted {
+			t.Errorf("Expected %d events since the take over, got %d", inserted, frequency-frequencyError)
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		sketch1 := newSketch(t, 5)
+		sketch2 := newSketch(t, 5)
+
+		for i := uint64(0); i < 100; i++ {
+			sketch1.Insert(1, i)
+			sketch2.Insert(1, i)
+			sketch2.Insert(2, i)
+		}
+
+		if err := sketch1.Merge(sketch2); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		if frequency, _ := sketch1.Frequency(1); frequency != 200 {
+			t.Errorf("Expected 200 events for label 1 after merge, got %d", frequency)
+		}
+
+		if frequency, _ := sketch1.Frequency(2); frequency != 100 {
+			t.Errorf("Expected 100 events for label 2 after merge, got %d", frequency)
+		}
+	})
+
+	t.Run("Merge Charges Untracked Labels", func(t *testing.T) {
+		// Both sketches use all their counters, the smallest counts are 5
+		// and 7
+		sketch1 := newSketch(t, 3)
+		sketch2 := newSketch(t, 2)
+		insert := func(sketch *SamplingSpaceSavingSets[int, uint64], label int, n uint64) {
+			for i := uint64(0); i < n; i++ {
+				sketch.Insert(label, uint64(label)<<32|i)
+			}
+		}
+		insert(sketch1, 1, 10)
+		insert(sketch1, 2, 5)
+		insert(sketch1, 4, 8)
+		insert(sketch2, 1, 20)
+		insert(sketch2, 3, 7)
+
+		if err := sketch1.Merge(sketch2); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		expected := map[int][2]uint64{
+			1: {30, 0},
+			3: {12, 5},
+			4: {15, 7},
+		}
+		for label, want := range expected {
+			frequency, frequencyError := sketch1.Frequency(label)
+			if frequency != want[0] || frequencyError != want[1] {
+				t.Errorf("Expected %d events with error %d for label %d, got %d with error %d",
+					want[0], want[1], label, frequency, frequencyError)
+			}
+		}
+	})
+
+	t.Run("Self Merge", func(t *testing.T) {
+		sketch := newSketch(t, 5)
+		for i := uint64(0); i < 10; i++ {
+			sketch.Insert(1, i)
+		}
+
+		if err := sketch.Merge(sketch); err == nil {
+			t.Error("Expected error merging a sketch into itself")
+		}
+
+		if frequency, frequencyError := sketch.Frequency(1); frequency != 10 || frequencyError != 0 {
+			t.Errorf("Expected 10 events after a rejected self merge, got %d with error %d", frequency, frequencyError)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		sketch := newSketch(t, 5)
+
+		config := *sketch.config
+		config.TrackFrequencies = false
+		untracked := NewHLLSamplingSpaceSavingSets[int, uint64](&config)
+		untracked.Insert(1, 1)
+
+		if frequency, _ := untracked.Frequency(1); frequency != 0 {
+			t.Errorf("Expected no events without TrackFrequencies, got %d", frequency)
+		}
+
+		if err := sketch.Merge(untracked); err == nil {
+			t.Error("Expected error merging sketches with different TrackFrequencies")
+		}
+	})
+}
+
+func TestRemoveResetAndPin(t *testing.T) {
+	// newSketch creates a sketch with 3 counters
+	newSketch := func(t *testing.T) *SamplingSpaceSavingSets[int, uint64] {
+		t.Helper()
+
+		hllConfig, err := NewHLLConfig(256, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(3, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		return NewHLLSamplingSpaceSavingSets[int, uint64](config)
+	}
+
+	// flood inserts 100 distinct items for each of labels 100 to 119
+	flood := func(sketch *SamplingSpaceSavingSets[int, uint64]) {
+		for i := uint64(0); i < 2000; i++ {
+			sketch.Insert(100+int(i%20), i*0x9e3779b97f4a7c15)
+		}
+	}
+
+	t.Run("Remove", func(t *testing.T) {
+		sketch := newSketch(t)
+		for i := uint64(0); i < 30; i++ {
+			sketch.Insert(int(i%3), i*0x9e3779b97f4a7c15)
+		}
+
+		if !sketch.Remove(1) {
+			t.Fatal("Expected label 1 to be removed")
+		}
+
+		if sketch.Remove(1) {
+			t.Error("Expected removing an untracked label to fail")
+		}
+
+		if len(sketch.counters) != 2 {
+			t.Errorf("Expected 2 counters after removal, got %d", len(sketch.counters))
+		}
+
+		// The freed counter is given to the next new label
+		sketch.Insert(7, 1)
+		if _, tracked := sketch.counters[7]; !tracked {
+			t.Error("Expected a new label to take the freed counter")
+		}
+	})
+
+	t.Run("Reset Label", func(t *testing.T) {
+		sketch := newSketch(t)
+		for i := uint64(0); i < 300; i++ {
+			sketch.Insert(int(i%3), i*0x9e3779b97f4a7c15)
+		}
+
+		if !sketch.ResetLabel(2) {
+			t.Fatal("Expected label 2 to be reset")
+		}
+
+		if _, tracked := sketch.counters[2]; !tracked || sketch.Cardinality(2) != 0 {
+			t.Errorf("Expected label 2 to keep an empty counter, got cardinality %d", sketch.Cardinality(2))
+		}
+
+		if sketch.threshold != 0 {
+			t.Errorf("Expected the threshold to follow the reset counter, got %d", sketch.threshold)
+		}
+
+		if sketch.ResetLabel(5) {
+			t.Error("Expected resetting an untracked label to fail")
+		}
+	})
+
+	t.Run("Pinned Labels Are Not Evicted", func(t *testing.T) {
+		sketch := newSketch(t)
+		sketch.Insert(1, 1)
+		if err := sketch.Pin(1); err != nil {
+			t.Fatalf("Failed to pin label: %v", err)
+		}
+
+		flood(sketch)
+
+		if _, tracked := sketch.counters[1]; !tracked {
+			t.Fatal("Expected pinned label to stay tracked")
+		}
+
+		if len(sketch.counters) != 3 {
+			t.Errorf("Expected pinned labels to use the counter budget, got %d counters", len(sketch.counters))
+		}
+
+		sketch.Unpin(1)
+		flood(sketch)
+
+		if _, tracked := sketch.counters[1]; tracked {
+			t.Error("Expected unpinned label to be evicted")
+		}
+	})
+
+	t.Run("Pin Untracked Label", func(t *testing.T) {
+		sketch := newSketch(t)
+		flood(sketch)
+
+		if err := sketch.Pin(42); err != nil {
+			t.Fatalf("Failed to pin label: %v", err)
+		}
+
+		if _, tracked := sketch.counters[42]; !tracked || len(sketch.counters) != 3 {
+			t.Error("Expected pinned label to replace the minimum counter")
+		}
+
+		sketch.Insert(42, 1)
+		if sketch.Cardinality(42) != 1 {
+			t.Errorf("Expected pinned label to count its items, got %d", sketch.Cardinality(42))
+		}
+	})
+
+	t.Run("All Counters Pinned", func(t *testing.T) {
+		sketch := newSketch(t)
+		for label := 1; label <= 3; label++ {
+			if err := sketch.Pin(label); err != nil {
+				t.Fatalf("Failed to pin label: %v", err)
+			}
+		}
+
+		if err := sketch.Pin(4); err == nil {
+			t.Error("Expected error pinning with every counter pinned")
+		}
+
+		flood(sketch)
+
+		if len(sketch.counters) != 3 || !sketch.Pinned(1) || !sketch.Pinned(2) || !sketch.Pinned(3) {
+			t.Error("Expected only the pinned labels to be tracked")
+		}
+	})
+
+	t.Run("Merge Keeps Pinned Labels", func(t *testing.T) {
+		sketch := newSketch(t)
+		if err := sketch.Pin(1); err != nil {
+			t.Fatalf("Failed to pin label: %v", err)
+		}
+
+		other := newSketch(t)
+		flood(other)
+
+		if err := sketch.Merge(other); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		if _, tracked := sketch.counters[1]; !tracked || len(sketch.counters) != 3 {
+			t.Error("Expected the pinned label to survive the merge")
+		}
+	})
+
+	t.Run("Clear Keeps Pins", func(t *testing.T) {
+		sketch := newSketch(t)
+		if err := sketch.Pin(1); err != nil {
+			t.Fatalf("Failed to pin label: %v", err)
+		}
+		sketch.Insert(1, 1)
+		sketch.Clear()
+
+		if _, tracked := sketch.counters[1]; !tracked || sketch.Cardinality(1) != 0 {
+			t.Error("Expected the pinned label to keep an empty counter")
+		}
+
+		if !sketch.Remove(1) || sketch.Pinned(1) {
+			t.Error("Expected removal to unpin the label")
+		}
+	})
+}
+
+func TestResize(t *testing.T) {
+	// newSketch creates a sketch where label i has 10*(i+1) distinct items
+	newSketch := func(t *testing.T, maxNumCounters int, numLabels int) *SamplingSpaceSavingSets[int, uint64] {
+		t.Helper()
+
+		hllConfig, err := NewHLLConfig(1024, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(maxNumCounters, hllConfig, []uint64{0, 1, 2, 3})
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+
+		sketch := NewHLLSamplingSpaceSavingSets[int, uint64](config)
+		for label := 0; label < numLabels; label++ {
+			for i := 0; i < 10*(label+1); i++ {
+				sketch.Insert(label, uint64(label*1000+i)*0x9e3779b97f4a7c15)
+			}
+		}
+		return sketch
+	}
+
+	t.Run("Shrink", func(t *testing.T) {
+		sketch := newSketch(t, 10, 10)
+		if err := sketch.Resize(3); err != nil {
+			t.Fatalf("Failed to resize sketch: %v", err)
+		}
+
+		if len(sketch.counters) != 3 {
+			t.Fatalf("Expected 3 counters, got %d", len(sketch.counters))
+		}
+
+		for _, label := range []int{7, 8, 9} {
+			if _, tracked := sketch.counters[label]; !tracked {
+				t.Errorf("Expected label %d with one of the 3 highest cardinalities to be kept", label)
+			}
+		}
+
+		_, minCardinality := sketch.minCounter()
+		if sketch.threshold != minCardinality {
+			t.Errorf("Expected threshold %d, got %d", minCardinality, sketch.threshold)
+		}
+	})
+
+	t.Run("Grow", func(t *testing.T) {
+		sketch := newSketch(t, 3, 3)
+		cardinalities := make(map[int]uint64)
+		for label := range sketch.counters {
+			cardinalities[label] = sketch.Cardinality(label)
+		}
+
+		if err := sketch.Resize(5); err != nil {
+			t.Fatalf("Failed to resize sketch: %v", err)
+		}
+
+		for label, cardinality := range cardinalities {
+			if sketch.Cardinality(label) != cardinality {
+				t.Errorf("Expected label %d to keep cardinality %d", label, cardinality)
+			}
+		}
+
+		// New labels get the added counters
+		sketch.Insert(100, 1)
+		sketch.Insert(101, 1)
+		if len(sketch.counters) != 5 {
+			t.Errorf("Expected 5 counters after growing, got %d", len(sketch.counters))
+		}
+	})
+
+	t.Run("Shared Config", func(t *testing.T) {
+		sketch := newSketch(t, 5, 5)
+		other := NewHLLSamplingSpaceSavingSets[int, uint64](sketch.config)
+
+		if err := sketch.Resize(2); err != nil {
+			t.Fatalf("Failed to resize sketch: %v", err)
+		}
+
+		if other.config.MaxNumCounters != 5 {
+			t.Errorf("Expected other sketches to keep 5 counters, got %d", other.config.MaxNumCounters)
+		}
+	})
+
+	t.Run("Pinned Labels", func(t *testing.T) {
+		sketch := newSketch(t, 5, 5)
+		for _, label := range []int{0, 1} {
+			if err := sketch.Pin(label); err != nil {
+				t.Fatalf("Failed to pin label: %v", err)
+			}
+		}
+
+		if err := sketch.Resize(1); err == nil {
+			t.Error("Expected error resizing below the number of pinned labels")
+		}
+
+		if err := sketch.Resize(3); err != nil {
+			t.Fatalf("Failed to resize sketch: %v", err)
+		}
+
+		for _, label := range []int{0, 1, 4} {
+			if _, tracked := sketch.counters[label]; !tracked {
+				t.Errorf("Expected label %d to be kept", label)
+			}
+		}
+
+		if err := sketch.Resize(0); err == nil {
+			t.Error("Expected error resizing to zero counters")
+		}
+	})
+
+	t.Run("Merge Different Capacities", func(t *testing.T) {
+		small := newSketch(t, 2, 2)
+		large := newSketch(t, 8, 8)
+
+		if err := small.Merge(large); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		if len(small.counters) != 2 || small.config.MaxNumCounters != 2 {
+			t.Errorf("Expected the receiver to keep 2 counters, got %d", len(small.counters))
+		}
+
+		for _, label := range []int{6, 7} {
+			if _, tracked := small.counters[label]; !tracked {
+				t.Errorf("Expected label %d to be kept", label)
+			}
+		}
+
+		large = newSketch(t, 8, 8)
+		if err := large.Merge(newSketch(t, 2, 2)); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		if len(large.counters) != 8 {
+			t.Errorf("Expected 8 counters, got %d", len(large.counters))
+		}
+	})
+}
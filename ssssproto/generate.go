@@ -0,0 +1,19 @@
+// Package ssssproto contains the protobuf messages of ssss.proto for
+// exchanging sketches with services in other languages.
+//
+// The message types are generated with protoc-gen-go and implement
+// proto.Message, so they are encoded with proto.Marshal and decoded with
+// proto.Unmarshal. Use the ToProto methods and the FromProto functions of
+// the ssss package to convert sketches.
+//
+// go generate pins the generator versions: protoc must be version 25.3, and
+// protoc-gen-go is built from the google.golang.org/protobuf version required
+// in go.mod. The committed ssss.pb.go was generated with that protoc-gen-go
+// but with a protoc that reports no version, so its header reads
+// "protoc (unknown)". Regenerating it writes "protoc v4.25.3" there instead.
+package ssssproto
+
+//go:generate sh -c "protoc --version | grep -qx 'libprotoc 25.3' || { echo 'ssssproto: protoc 25.3 is required' >&2; exit 1; }"
+//go:generate go build -o protoc-gen-go.bin google.golang.org/protobuf/cmd/protoc-gen-go
+//go:generate protoc --plugin=protoc-gen-go=protoc-gen-go.bin --go_out=. --go_opt=paths=source_relative ssss.proto
+//go:generate rm protoc-gen-go.bin
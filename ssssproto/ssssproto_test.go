@@ -0,0 +1,152 @@
+package ssssproto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// mustHex decodes a hex string with optional spaces
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(string(bytes.ReplaceAll([]byte(s), []byte(" "), nil)))
+	if err != nil {
+		t.Fatalf("Invalid hex fixture: %v", err)
+	}
+	return b
+}
+
+func TestWireFormat(t *testing.T) {
+	// HLLConfig{num_registers: 16, alpha: 0.5, seeds: [1, 2]} as encoded by
+	// protoc-generated code: varint, fixed64 and a packed fixed64 field
+	golden := "08 10" +
+		" 11 000000000000e03f" +
+		" 1a 10 0100000000000000 0200000000000000"
+
+	t.Run("Golden Encoding", func(t *testing.T) {
+		config := &HLLConfig{NumRegisters: 16, Alpha: 0.5, Seeds: []uint64{1, 2}}
+
+		data, err := proto.Marshal(config)
+		if err != nil {
+			t.Fatalf("Failed to marshal: %v", err)
+		}
+
+		if !bytes.Equal(data, mustHex(t, golden)) {
+			t.Errorf("Unexpected encoding: %x", data)
+		}
+
+		var decoded HLLConfig
+		if err := proto.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal: %v", err)
+		}
+
+		if !proto.Equal(&decoded, config) {
+			t.Errorf("Expected %+v after round trip, got %v", config, &decoded)
+		}
+	})
+
+	t.Run("Unpacked Repeated Fields", func(t *testing.T) {
+		// Writers may emit repeated scalars unpacked; both forms must decode
+		data := mustHex(t, "08 10 19 0100000000000000 19 0200000000000000")
+
+		var decoded HLLConfig
+		if err := proto.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal: %v", err)
+		}
+
+		if len(decoded.Seeds) != 2 || decoded.Seeds[0] != 1 || decoded.Seeds[1] != 2 {
+			t.Errorf("Expected seeds [1 2], got %v", decoded.Seeds)
+		}
+	})
+
+	t.Run("Unknown Fields From Newer Versions", func(t *testing.T) {
+		// A newer writer adds a varint field 9, a string field 10, a fixed32
+		// field 11 and a nested message field 12
+		data := mustHex(t, golden+
+			" 48 96 01"+
+			" 52 03 6e6577"+
+			" 5d 01020304"+
+			" 62 02 0801")
+
+		var decoded HLLConfig
+		if err := proto.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal message with unknown fields: %v", err)
+		}
+
+		if decoded.NumRegisters != 16 || decoded.Alpha != 0.5 || len(decoded.Seeds) != 2 {
+			t.Errorf("Known fields not decoded correctly: %v", &decoded)
+		}
+	})
+
+	t.Run("Missing Fields From Older Versions", func(t *testing.T) {
+		// An older writer that did not know about thresholds or cached
+		// cardinalities produces messages without them
+		counter := &Counter{Label: []byte("a"), Registers: []byte{0, 1, 2, 3}}
+		data, err := proto.Marshal(&SamplingSpaceSavingSets{Counters: []*Counter{counter}})
+		if err != nil {
+			t.Fatalf("Failed to marshal: %v", err)
+		}
+
+		var decoded SamplingSpaceSavingSets
+		if err := proto.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal: %v", err)
+		}
+
+		if decoded.GetThreshold() != 0 || decoded.GetConfig() != nil {
+			t.Errorf("Expected zero values for missing fields, got %v", &decoded)
+		}
+
+		if len(decoded.Counters) != 1 || !proto.Equal(decoded.Counters[0], counter) {
+			t.Errorf("Expected counter %v, got %v", counter, decoded.Counters)
+		}
+	})
+
+	t.Run("Labels Need Not Be UTF-8", func(t *testing.T) {
+		// Composite labels of a GroupBySketch prefix values with their length
+		// as a varint, which is not valid UTF-8 from 128 on
+		pair := &SampledPair{Label: []byte{0x80, 0x01, 'a'}, Hash: 7}
+		data, err := proto.Marshal(&SamplingSpaceSavingSets{
+			Counters:     []*Counter{{Label: pair.Label}},
+			SubsetSample: []*SampledPair{pair},
+		})
+		if err != nil {
+			t.Fatalf("Failed to marshal: %v", err)
+		}
+
+		var decoded SamplingSpaceSavingSets
+		if err := proto.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal: %v", err)
+		}
+
+		if !bytes.Equal(decoded.Counters[0].GetLabel(), pair.Label) || !proto.Equal(decoded.SubsetSample[0], pair) {
+			t.Errorf("Expected label %q, got %v", pair.Label, &decoded)
+		}
+	})
+
+	t.Run("Malformed Input", func(t *testing.T) {
+		inputs := map[string]string{
+			"truncated varint":  "08 80",
+			"truncated fixed64": "11 0000",
+			"truncated bytes":   "1a 10 01",
+			"bad packed length": "1a 03 010203",
+			"field number zero": "00 01",
+			"invalid wire type": "0f",
+			"overlong varint":   "08 ffffffffffffffffffff01",
+		}
+
+		for name, input := range inputs {
+			var decoded HLLConfig
+			if err := proto.Unmarshal(mustHex(t, input), &decoded); err == nil {
+				t.Errorf("Expected error for %s", name)
+			}
+		}
+
+		var sketch SamplingSpaceSavingSets
+		if err := proto.Unmarshal(mustHex(t, "0a 02 0880"), &sketch); err == nil {
+			t.Error("Expected error for malformed nested message")
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package ssss
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SubsetEstimate is an estimate of the number of distinct items in the sets
+// of a subset of labels, together with an estimate of its variance
+type SubsetEstimate struct {
+	Estimate float64
+	Variance float64
+}
+
+// StandardError returns the square root of the variance
+func (e SubsetEstimate) StandardError() float64 {
+	return math.Sqrt(e.Variance)
+}
+
+// EstimateSubset estimates the number of distinct (label, item) pairs whose
+// label satisfies pred, summed over tracked and untracked labels alike. It
+// requires Config.SubsetSampleSize.
+//
+// The estimate comes from a sample of pairs kept next to the counters: the
+// pairs whose hash falls below a threshold, which decreases as needed to keep
+// at most SubsetSampleSize of them. Each sampled pair counts for the inverse
+// of the sampling probability, so the estimate is unbiased, and repeated
+// items are not counted twice since a pair always has the same hash. The
+// variance is the Horvitz-Thompson estimate count*(1-p)/p^2 for count pairs
+// sampled with probability p, and is 0 while all pairs fit in the sample.
+//
+// The sample is independent of the counters: it holds its own pairs and
+// labels, and does not use their HyperLogLog sketches. Its memory comes on top
+// of theirs, see SizeInBytes and NewConfigForBudget.
+func (s *SamplingSpaceSavingSets[L, T]) EstimateSubset(pred func(L) bool) (SubsetEstimate, error) {
+	if s.sample == nil {
+		return SubsetEstimate{}, errors.New("subset estimation requires Config.SubsetSampleSize")
+	}
+
+	var count float64
+	for _, label := range s.sample.pairs {
+		if pred(label) {
+			count++
+		}
+	}
+
+	p := s.sample.probability()
+	return SubsetEstimate{
+		Estimate: count / p,
+		Variance: count * (1 - p) / (p * p),
+	}, nil
+}
+
+// pairHash hashes a (label, item) pair for the subset sample, independently
+// of the hashes of the cardinality sketches. With Config.KeyedHashing the
+// hash is keyed, so that pairs cannot be crafted to fill the sample.
+func (s *SamplingSpaceSavingSets[L, T]) pairHash(label L, item T) uint64 {
+	if s.config.KeyedHashing {
+		return keyedPairHash(s.config.tweakedKey(pairKeyTweak), label, item)
+	}
+
+	var seed uint64
+	if len(s.config.Seeds) > 0 {
+		seed = s.config.Seeds[0]
+	}
+	return murmurHash64A(itemText(label), murmurHash64A(itemText(item), seed))
+}
+
+// pairSample keeps the labels of the (label, item) pairs with the smallest
+// hashes: all pairs with a hash up to threshold, and at most size of them.
+// The hashes are also kept in a max-heap, so the largest one is dropped in
+// O(log size) when the sample overflows.
+type pairSample[L comparable] struct {
+	size      int
+	threshold uint64
+	pairs     map[uint64]L
+	hashes    hashHeap
+}
+
+// newPairSample creates an empty sample that keeps every pair until it holds
+// size pairs
+func newPairSample[L comparable](size int) *pairSample[L] {
+	return &pairSample[L]{
+		size:      size,
+		threshold: math.MaxUint64,
+		pairs:     make(map[uint64]L),
+	}
+}
+
+// insert adds the pair with the given hash if it is below the threshold
+func (p *pairSample[L]) insert(label L, hash uint64) {
+	if hash > p.threshold {
+		return
+	}
+	if _, exists := p.pairs[hash]; exists {
+		return
+	}
+
+	p.pairs[hash] = label
+	heap.Push(&p.hashes, hash)
+	p.shrink()
+}
+
+// merge adds the pairs of another sample, keeping the lower threshold of the
+// two so the result samples every pair with the same probability
+func (p *pairSample[L]) merge(other *pairSample[L]) {
+	if other.threshold < p.threshold {
+		p.threshold = other.threshold
+		for len(p.hashes) > 0 && p.hashes[0] > p.threshold {
+			delete(p.pairs, heap.Pop(&p.hashes).(uint64))
+		}
+	}
+
+	for hash, label := range other.pairs {
+		if hash > p.threshold {
+			continue
+		}
+		if _, exists := p.pairs[hash]; !exists {
+			heap.Push(&p.hashes, hash)
+		}
+		p.pairs[hash] = label
+	}
+
+	p.shrink()
+}
+
+// shrink drops the pairs with the largest hashes until the sample fits,
+// lowering the threshold below each dropped hash
+func (p *pairSample[L]) shrink() {
+	for len(p.pairs) > p.size {
+		maxHash := heap.Pop(&p.hashes).(uint64)
+		delete(p.pairs, maxHash)
+		p.threshold = maxHash - 1
+	}
+}
+
+// probability returns the probability that a pair is sampled
+func (p *pairSample[L]) probability() float64 {
+	return (float64(p.threshold) + 1) / (1 << 64)
+}
+
+// entries returns the hashes of the sampled pairs in increasing order with
+// the text form of their labels, see marshalLabel
+func (p *pairSample[L]) entries() ([]uint64, [][]byte, error) {
+	hashes := make([]uint64, 0, len(p.pairs))
+	for hash := range p.pairs {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i] < hashes[j]
+	})
+
+	texts := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		text, err := marshalLabel(p.pairs[hash])
+		if err != nil {
+			return nil, nil, err
+		}
+		texts[i] = text
+	}
+
+	return hashes, texts, nil
+}
+
+// set replaces the sample with decoded pairs, given as hashes and the text
+// form of their labels
+func (p *pairSample[L]) set(threshold uint64, hashes []uint64, texts [][]byte) error {
+	if len(hashes) > p.size {
+		return errors.New("more sampled pairs than SubsetSampleSize")
+	}
+
+	pairs := make(map[uint64]L, len(hashes))
+	for i, hash := range hashes {
+		if hash > threshold {
+			return fmt.Errorf("sampled pair hash %x above threshold %x", hash, threshold)
+		}
+
+		if _, exists := pairs[hash]; exists {
+			return fmt.Errorf("duplicate sampled pair hash %x", hash)
+		}
+
+		label, err := unmarshalLabel[L](texts[i])
+		if err != nil {
+			return err
+		}
+		pairs[hash] = label
+	}
+
+	p.threshold = threshold
+	p.pairs = pairs
+	p.hashes = append(hashHeap(nil), hashes...)
+	heap.Init(&p.hashes)
+	return nil
+}
+
+// clear empties the sample
+func (p *pairSample[L]) clear() {
+	p.threshold = math.MaxUint64
+	p.pairs = make(map[uint64]L)
+	p.hashes = nil
+}
+
+// hashHeap is a max-heap of the hashes of sampled pairs, see container/heap
+type hashHeap []uint64
+
+func (h hashHeap) Len() int           { return len(h) }
+func (h hashHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h hashHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *hashHeap) Push(x any) {
+	*h = append(*h, x.(uint64))
+}
+
+func (h *hashHeap) Pop() any {
+	old := *h
+	hash := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return hash
+}
@@ -0,0 +1,206 @@
+package ssss
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestEstimateSubset(t *testing.T) {
+	newConfig := func(t *testing.T, maxNumCounters, sampleSize int, seed int64) *Config {
+		seeds, err := ReadSeeds(rand.New(rand.NewSource(seed)), 4)
+		if err != nil {
+			t.Fatalf("Failed to read seeds: %v", err)
+		}
+
+		hllConfig, err := NewHLLConfig(64, []uint64{8, 9, 10, 11, 12, 13, 14, 15})
+		if err != nil {
+			t.Fatalf("Failed to create HLL config: %v", err)
+		}
+
+		config, err := NewConfig(maxNumCounters, hllConfig, seeds)
+		if err != nil {
+			t.Fatalf("Failed to create SSSS config: %v", err)
+		}
+		config.SubsetSampleSize = sampleSize
+		return config
+	}
+
+	t.Run("Exact While The Sample Fits", func(t *testing.T) {
+		sketch := NewHLLSamplingSpaceSavingSets[int, int](newConfig(t, 2, 100, 1))
+
+		// 10 labels with 5 items each, inserted twice
+		for repeat := 0; repeat < 2; repeat++ {
+			for i := 0; i < 50; i++ {
+				sketch.Insert(i%10, i)
+			}
+		}
+
+		estimate, err := sketch.EstimateSubset(func(label int) bool { return label < 4 })
+		if err != nil {
+			t.Fatalf("Failed to estimate subset: %v", err)
+		}
+
+		if estimate.Estimate != 20 || estimate.Variance != 0 {
+			t.Errorf("Expected an exact estimate of 20, got %+v", estimate)
+		}
+	})
+
+	t.Run("Unbiased", func(t *testing.T) {
+		// Heavy-tailed label sizes, with every item inserted twice in a
+		// shuffled order, so most labels are untracked for most of the stream
+		type event struct{ label, item int }
+		var events []event
+		sizes := make([]int, 300)
+		for label := range sizes {
+			sizes[label] = 1 + 3000/(label+1)
+			for item := 0; item < sizes[label]; item++ {
+				events = append(events, event{label, item}, event{label, item})
+			}
+		}
+		rand.New(rand.NewSource(1)).Shuffle(len(events), func(i, j int) {
+			events[i], events[j] = events[j], events[i]
+		})
+
+		subsets := map[string]func(int) bool{
+			"even labels":    func(label int) bool { return label%2 == 0 },
+			"tail labels":    func(label int) bool { return label >= 100 },
+			"heaviest label": func(label int) bool { return label == 0 },
+		}
+
+		const runs = 200
+		estimates := make(map[string][]SubsetEstimate)
+		for run := 0; run < runs; run++ {
+			sketch := NewHLLSamplingSpaceSavingSets[int, int](newConfig(t, 20, 200, int64(run)))
+			for _, e := range events {
+				sketch.Insert(e.label, e.label*100000+e.item)
+			}
+
+			for name, pred := range subsets {
+				estimate, err := sketch.EstimateSubset(pred)
+				if err != nil {
+					t.Fatalf("Failed to estimate subset: %v", err)
+				}
+				estimates[name] = append(estimates[name], estimate)
+			}
+		}
+
+		for name, pred := range subsets {
+			var truth float64
+			for label, size := range sizes {
+				if pred(label) {
+					truth += float64(size)
+				}
+			}
+
+			// The mean of the estimates must be within 4 standard errors of
+			// the truth, and the variance estimates must match the spread
+			var mean, meanVariance float64
+			for _, e := range estimates[name] {
+				mean += e.Estimate / runs
+				meanVariance += e.Variance / runs
+			}
+
+			var variance float64
+			covered := 0
+			for _, e := range estimates[name] {
+				variance += (e.Estimate - mean) * (e.Estimate - mean) / (runs - 1)
+				if math.Abs(e.Estimate-truth) <= 2*e.StandardError() {
+					covered++
+				}
+			}
+
+			standardError := math.Sqrt(variance / runs)
+			t.Logf("%s: truth %.0f, mean %.1f ± %.1f, stddev %.1f, estimated stddev %.1f, coverage %d/%d",
+				name, truth, mean, standardError, math.Sqrt(variance), math.Sqrt(meanVariance), covered, runs)
+
+			if math.Abs(mean-truth) > 4*standardError {
+				t.Errorf("%s: mean estimate %.1f is biased, truth %.0f", name, mean, truth)
+			}
+
+			if ratio := meanVariance / variance; ratio < 0.7 || ratio > 1.3 {
+				t.Errorf("%s: estimated variance is %.2f times the observed variance", name, ratio)
+			}
+
+			if covered < runs*88/100 {
+				t.Errorf("%s: only %d of %d estimates within 2 standard errors", name, covered, runs)
+			}
+		}
+	})
+
+	t.Run("Merged Sample", func(t *testing.T) {
+		config := newConfig(t, 5, 50, 1)
+		sketch := NewHLLSamplingSpaceSavingSets[int, int](config)
+		part1 := NewHLLSamplingSpaceSavingSets[int, int](config)
+		part2 := NewHLLSamplingSpaceSavingSets[int, int](config)
+
+		// Overlapping halves of the stream sample the same pairs as the
+		// whole stream
+		for i := 0; i < 5000; i++ {
+			sketch.Insert(i%40, i)
+			if i < 3000 {
+				part1.Insert(i%40, i)
+			}
+			if i >= 2000 {
+				part2.Insert(i%40, i)
+			}
+		}
+
+		if err := part1.Merge(part2); err != nil {
+			t.Fatalf("Failed to merge sketches: %v", err)
+		}
+
+		pred := func(label int) bool { return label < 10 }
+		want, _ := sketch.EstimateSubset(pred)
+		got, _ := part1.EstimateSubset(pred)
+		if got != want {
+			t.Errorf("Expected merged estimate %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("Sample Keeps The Smallest Hashes", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(7))
+		sample := newPairSample[int](100)
+		other := newPairSample[int](100)
+
+		var hashes []uint64
+		for i := 0; i < 5000; i++ {
+			hash := rng.Uint64()
+			hashes = append(hashes, hash)
+			if i%2 == 0 {
+				sample.insert(i, hash)
+			} else {
+				other.insert(i, hash)
+			}
+		}
+		sample.merge(other)
+
+		sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+		if len(sample.pairs) != 100 || len(sample.hashes) != 100 {
+			t.Fatalf("Expected 100 sampled pairs, got %d with %d hashes", len(sample.pairs), len(sample.hashes))
+		}
+		for _, hash := range hashes[:100] {
+			if _, exists := sample.pairs[hash]; !exists {
+				t.Fatalf("Expected hash %x among the 100 smallest to be sampled", hash)
+			}
+		}
+		if sample.threshold != hashes[100]-1 {
+			t.Errorf("Expected threshold %x below the first dropped hash, got %x", hashes[100]-1, sample.threshold)
+		}
+	})
+
+	t.Run("Requires A Sample", func(t *testing.T) {
+		sketch := NewHLLSamplingSpaceSavingSets[int, int](newConfig(t, 5, 0, 1))
+		if _, err := sketch.EstimateSubset(func(int) bool { return true }); err == nil {
+			t.Error("Expected an error without a subset sample")
+		}
+
+		// Sketches with and without a sample cannot be merged
+		other := NewHLLSamplingSpaceSavingSets[int, int](newConfig(t, 5, 10, 1))
+		if err := sketch.Merge(other); !errors.Is(err, ErrConfigMismatch) {
+			t.Errorf("Expected a config mismatch, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+// Writes the DataSketches HLL images the compatibility tests decode, with
+// the datasketches-java version pinned in generate.sh. For every lgK from 4
+// to 21 and every target type it writes the compact and the updatable image
+// of a sketch in each mode the lgK allows, and for small lgK an HLL_4 image
+// with exceptions. manifest.txt lists every image with the number of
+// updates: the sketch is updated with the longs 0 to n-1.
+
+import java.io.FileOutputStream;
+import java.io.IOException;
+import java.io.OutputStream;
+import java.io.PrintWriter;
+import java.nio.file.Path;
+import java.nio.file.Paths;
+import java.util.zip.GZIPOutputStream;
+
+import org.apache.datasketches.hll.HllSketch;
+import org.apache.datasketches.hll.TgtHllType;
+
+public class Generate {
+  private static final String[] MODES = {"list", "set", "hll"};
+
+  public static void main(String[] args) throws IOException {
+    Path dir = Paths.get(args.length > 0 ? args[0] : ".");
+
+    try (PrintWriter manifest = new PrintWriter(dir.resolve("manifest.txt").toFile(), "UTF-8")) {
+      for (int lgK = 4; lgK <= 21; lgK++) {
+        int k = 1 << lgK;
+        for (TgtHllType type : TgtHllType.values()) {
+          write(dir, manifest, lgK, type, "list", 3);
+
+          // Sketches with lgK below 8 go from LIST straight to HLL
+          if (lgK >= 8) {
+            write(dir, manifest, lgK, type, "set", 16 + k / 64);
+          }
+
+          write(dir, manifest, lgK, type, "hll", 4L * k);
+        }
+
+        if (lgK <= 12) {
+          writeAux(dir, manifest, lgK);
+        }
+      }
+    }
+  }
+
+  // write updates a sketch n times and writes its images, checking that it
+  // ended up in the expected mode
+  private static void write(Path dir, PrintWriter manifest, int lgK, TgtHllType type,
+      String mode, long n) throws IOException {
+    HllSketch sketch = new HllSketch(lgK, type);
+    for (long i = 0; i < n; i++) {
+      sketch.update(i);
+    }
+
+    byte[] compact = sketch.toCompactByteArray();
+    if (!MODES[compact[7] & 3].equals(mode)) {
+      throw new IllegalStateException(name(lgK, type, mode, "compact") + " is in mode " + MODES[compact[7] & 3]);
+    }
+
+    writeImage(dir, manifest, name(lgK, type, mode, "compact"), compact, n);
+    writeImage(dir, manifest, name(lgK, type, mode, "updatable"), sketch.toUpdatableByteArray(), n);
+  }
+
+  // writeAux updates an HLL_4 sketch until its image has an exception
+  private static void writeAux(Path dir, PrintWriter manifest, int lgK) throws IOException {
+    HllSketch sketch = new HllSketch(lgK, TgtHllType.HLL_4);
+    long n = 0;
+    while (true) {
+      for (long end = n + 1000; n < end; n++) {
+        sketch.update(n);
+      }
+
+      byte[] compact = sketch.toCompactByteArray();
+      int auxCount = (compact[36] & 0xff) | (compact[37] & 0xff) << 8
+          | (compact[38] & 0xff) << 16 | (compact[39] & 0xff) << 24;
+      if ((compact[7] & 3) == 2 && auxCount > 0) {
+        writeImage(dir, manifest, name(lgK, TgtHllType.HLL_4, "aux", "compact"), compact, n);
+        writeImage(dir, manifest, name(lgK, TgtHllType.HLL_4, "aux", "updatable"),
+            sketch.toUpdatableByteArray(), n);
+        return;
+      }
+    }
+  }
+
+  private static String name(int lgK, TgtHllType type, String mode, String form) {
+    return type.name().toLowerCase().replace("_", "") + "-lgk" + lgK + "-" + mode + "-" + form + ".sk.gz";
+  }
+
+  private static void writeImage(Path dir, PrintWriter manifest, String name, byte[] image, long n)
+      throws IOException {
+    try (OutputStream out = new GZIPOutputStream(new FileOutputStream(dir.resolve(name).toFile()))) {
+      out.write(image);
+    }
+    manifest.println(name + " " + n);
+  }
+}
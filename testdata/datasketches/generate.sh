@@ -0,0 +1,26 @@
+#!/bin/sh
+# Regenerates the DataSketches HLL images in this directory with the pinned
+# datasketches-java release. Needs Java 11 or later and network access to
+# Maven Central. The versions are recorded in VERSION.
+set -eu
+
+java_version=5.0.2
+memory_version=2.2.0
+
+dir=$(cd "$(dirname "$0")" && pwd)
+jars=$(mktemp -d)
+trap 'rm -rf "$jars"' EXIT
+
+central=https://repo1.maven.org/maven2/org/apache/datasketches
+curl -fsSL -o "$jars/java.jar" "$central/datasketches-java/$java_version/datasketches-java-$java_version.jar"
+curl -fsSL -o "$jars/memory.jar" "$central/datasketches-memory/$memory_version/datasketches-memory-$memory_version.jar"
+
+rm -f "$dir"/*.sk.gz
+java -cp "$jars/java.jar:$jars/memory.jar" "$dir/Generate.java" "$dir"
+
+{
+	echo "datasketches-java: $java_version"
+	echo "datasketches-memory: $memory_version"
+	echo "java: $(java -version 2>&1 | head -n 1)"
+	echo "generated: $(date -u +%Y-%m-%d)"
+} >"$dir/VERSION"
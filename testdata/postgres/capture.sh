@@ -0,0 +1,44 @@
+#!/bin/sh
+# Captures the hll values the postgresql-hll compatibility tests decode. Run
+# it against a scratch database where the extension can be created, then
+# commit the files it writes:
+#
+#	docker run --rm -d -p 5432:5432 -e POSTGRES_PASSWORD=postgres \
+#		citusdata/citus:12.1   # PostgreSQL 16 with postgresql-hll 2.18
+#	PGHOST=localhost PGUSER=postgres PGPASSWORD=postgres testdata/postgres/capture.sh
+#
+# values.txt holds one "name hex cardinality" line per value, where the
+# cardinality is hll_cardinality rounded to an integer. The names and the hashed
+# values must match postgresCaptures in postgres_test.go. The zero-* values
+# add raw hashes whose bits above the register index are all zero, which the
+# extension ignores.
+set -eu
+
+dir=$(dirname "$0")
+
+psql -X -q -v ON_ERROR_STOP=1 -At -F ' ' >"$dir/values.txt" <<'SQL'
+CREATE EXTENSION IF NOT EXISTS hll;
+SELECT name, encode(h::bytea, 'hex'), coalesce(round(hll_cardinality(h)), 0)::bigint
+FROM (
+	SELECT 1 AS n, 'empty' AS name, hll_empty() AS h
+	UNION ALL SELECT 2, 'explicit', hll_add_agg(hll_hash_bigint(i))
+		FROM generate_series(1, 10) i
+	UNION ALL SELECT 3, 'sparse', hll_add_agg(hll_hash_bigint(i), 11, 5, 0, 1)
+		FROM generate_series(1, 100) i
+	UNION ALL SELECT 4, 'full-small', hll_add_agg(hll_hash_bigint(i), 11, 5, 0, 0)
+		FROM generate_series(1, 100) i
+	UNION ALL SELECT 5, 'full', hll_add_agg(hll_hash_bigint(i), 11, 5, 0, 1)
+		FROM generate_series(1, 10000) i
+	UNION ALL SELECT 6, 'zero-explicit', hll_add_agg(h::hll_hashval, 11, 5, -1, 1)
+		FROM unnest(ARRAY[5, 2053, 1029]::bigint[]) h
+	UNION ALL SELECT 7, 'zero-full', hll_add_agg(h::hll_hashval, 11, 5, 0, 0)
+		FROM unnest(ARRAY[5, 2053, 1029]::bigint[]) h
+) captures
+ORDER BY n;
+SQL
+
+psql -X -q -v ON_ERROR_STOP=1 -At >"$dir/VERSION" <<'SQL'
+SELECT 'server_version: ' || current_setting('server_version');
+SELECT 'hll: ' || extversion FROM pg_extension WHERE extname = 'hll';
+SELECT 'captured: ' || to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD');
+SQL
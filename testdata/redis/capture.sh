@@ -0,0 +1,46 @@
+#!/bin/sh
+# Captures the HyperLogLog values the Redis compatibility tests decode. Run
+# it against a scratch Redis server, then commit the files it writes:
+#
+#	docker run --rm -d -p 6379:6379 redis:7.2.4
+#	testdata/redis/capture.sh
+#
+# Every value is read with GET right after PFADD or PFMERGE, before any
+# PFCOUNT, so its cached cardinality is still marked invalid. The keys and
+# their elements must match redisCaptures in redis_test.go.
+set -eu
+
+cli="redis-cli ${REDIS_CLI_ARGS:-}"
+dir=$(dirname "$0")
+
+# items prints item:first to item:last, one per line
+items() {
+	seq -f 'item:%.0f' "$1" "$2"
+}
+
+# capture writes the raw string value of a key and appends its PFCOUNT to
+# pfcount.txt. redis-cli --raw ends the value with a newline, which is cut.
+capture() {
+	$cli --raw GET "ssss:$1" | head -c -1 >"$dir/$1.hyll"
+	echo "$1 $($cli PFCOUNT "ssss:$1")" >>"$dir/pfcount.txt"
+}
+
+$cli DEL ssss:empty ssss:abc ssss:sparse ssss:other ssss:dense ssss:merged >/dev/null
+$cli CONFIG SET hll-sparse-max-bytes 3000 >/dev/null
+rm -f "$dir/pfcount.txt"
+
+$cli PFADD ssss:empty >/dev/null
+$cli PFADD ssss:abc a b c >/dev/null
+items 0 199 | xargs $cli PFADD ssss:sparse >/dev/null
+items 100 299 | xargs $cli PFADD ssss:other >/dev/null
+items 0 19999 | xargs $cli PFADD ssss:dense >/dev/null
+$cli PFMERGE ssss:merged ssss:sparse ssss:other >/dev/null
+
+for key in empty abc sparse other dense merged; do
+	capture "$key"
+done
+
+{
+	$cli INFO server | tr -d '\r' | grep '^redis_version:'
+	echo "captured: $(date -u +%Y-%m-%d)"
+} >"$dir/VERSION"